@@ -23,10 +23,16 @@ package gojs
 
 import (
 	"context"
+	"fmt"
+	"path"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/runner-mei/gojs/modules"
+	"github.com/runner-mei/log"
 	"github.com/serenize/snaker"
 )
 
@@ -89,7 +95,8 @@ var methodNameExceptions = map[string]string{
 // lowercased, otherwise it is unaltered.
 func MethodName(t reflect.Type, m reflect.Method) string {
 	// A field with a name beginning with an X is a constructor, and just gets the prefix stripped.
-	// Note: They also get some special treatment from Bridge(), see further down.
+	// Note: They also get some special treatment from ToBindObject(), see RegisterConstructor
+	// for the preferred way to define a constructor.
 	if m.Name[0] == 'X' {
 		return m.Name[1:]
 	}
@@ -114,27 +121,349 @@ func (FieldNameMapper) FieldName(t reflect.Type, f reflect.StructField) string {
 // https://godoc.org/github.com/dop251/goja#FieldNameMapper
 func (FieldNameMapper) MethodName(t reflect.Type, m reflect.Method) string { return MethodName(t, m) }
 
-// Bind the provided value v to the provided runtime
-func (r *Runtime) Bind(name string, v interface{}) {
-	exports := r.ToBindObject(v)
-	r.Runtime.Set(name, exports)
+// BindNames overrides the JS names of the methods and fields of a value bound by a
+// single Bind or ToBindObject call, without changing the global rules of MethodName
+// and FieldName. It maps the Go names to the JS ones, an empty JS name hides the
+// member. The values returned by the methods aren't affected.
+type BindNames map[string]string
+
+// bindName returns the JS name of the member goName in names, the later maps take
+// precedence, or def when it isn't in any of them.
+func bindName(names []BindNames, goName, def string) string {
+	for i := len(names) - 1; i >= 0; i-- {
+		if name, ok := names[i][goName]; ok {
+			return name
+		}
+	}
+	return def
 }
 
-func (r *Runtime) ToBindObject(v interface{}) map[string]interface{} {
-	exports := make(map[string]interface{})
+// Bind the provided value v to the provided runtime, names overrides the JS names
+// of its members, see BindNames.
+func (r *Runtime) Bind(name string, v interface{}, names ...BindNames) {
+	r.checkGlobal(name)
+	exports := r.ToBindObject(v, names...)
+	r.setGlobal(name, exports)
+	r.addGlobal(global{name: name, value: v, bind: true, names: names})
+}
 
+// TryBind is like Bind, but when v has methods which can't be called from the
+// scripts, see ToBindObject, it fails with an error listing all of them instead
+// of skipping them.
+func (r *Runtime) TryBind(name string, v interface{}, names ...BindNames) error {
+	if errs := checkBindable(v, names); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return fmt.Errorf("can't bind '%s': %s", name, strings.Join(msgs, "; "))
+	}
+	r.Bind(name, v, names...)
+	return nil
+}
+
+// checkBindable returns the errors of the methods of v which can't be called from
+// the scripts, the ones hidden by names are ignored.
+func checkBindable(v interface{}, names []BindNames) []error {
+	var errs []error
+	val := methodsOf(v)
+	if !val.IsValid() {
+		return nil
+	}
+	typ := val.Type()
+	for i := 0; i < typ.NumMethod(); i++ {
+		meth := typ.Method(i)
+		if bindName(names, meth.Name, MethodName(typ, meth)) == "" {
+			continue
+		}
+		// The receiver is the first argument of the methods of a type.
+		if err := checkMethod(val.Method(i).Type()); err != nil {
+			errs = append(errs, fmt.Errorf("method %s: %w", meth.Name, err))
+		}
+	}
+	return errs
+}
+
+// methodsOf returns the value whose methods are bound for v, it's the interface
+// when v is a pointer to an interface, or an invalid value when there is nothing
+// to bind, see ToBindObject.
+func methodsOf(v interface{}) reflect.Value {
 	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr && val.Type().Elem().Kind() == reflect.Interface {
+		if val.IsNil() || val.Elem().IsNil() {
+			return reflect.Value{}
+		}
+		val = val.Elem()
+	}
+	return val
+}
+
+// checkMethod returns why a method of the type fnT can't be called from the scripts,
+// or nil when it can.
+func checkMethod(fnT reflect.Type) error {
+	numIn := fnT.NumIn()
+	for i := 0; i < numIn; i++ {
+		T := fnT.In(i)
+		if fnT.IsVariadic() && i == numIn-1 {
+			T = T.Elem()
+		}
+		switch {
+		case T == ctxT && i > 0:
+			return fmt.Errorf("the context.Context is the argument %d, it must be the first one", i+1)
+		case T == fnCallT && i < numIn-1:
+			return fmt.Errorf("the goja.FunctionCall is the argument %d, it must be the last one", i+1)
+		case !isConvertible(T):
+			return fmt.Errorf("the argument %d of type %s can't be converted from a JS value", i+1, T)
+		}
+	}
+	return nil
+}
+
+// isConvertible returns false if the JS values can't be exported to the type t.
+func isConvertible(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Chan, reflect.UnsafePointer:
+		return false
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return isConvertible(t.Elem())
+	case reflect.Map:
+		return isConvertible(t.Key()) && isConvertible(t.Elem())
+	default:
+		return true
+	}
+}
+
+// RegisterConstructor defines a global constructor with the given name, it can
+// be called with or without `new`. The This of the call is the object created by
+// `new`, fn may return it or any other object, a nil return is the same as This.
+func (r *Runtime) RegisterConstructor(name string, fn func(context.Context, goja.ConstructorCall) *goja.Object) {
+	r.checkGlobal(name)
+	r.setGlobal(name, r.newConstructor(fn))
+	r.recordConstructor(name, fn)
+}
+
+// newConstructor returns the JS constructor calling fn, see RegisterConstructor.
+func (r *Runtime) newConstructor(fn func(context.Context, goja.ConstructorCall) *goja.Object) goja.Value {
+	return r.wrapConstructor(func(call goja.FunctionCall) goja.Value {
+		var this *goja.Object
+		if call.This != nil && !goja.IsUndefined(call.This) && !goja.IsNull(call.This) {
+			this = call.This.ToObject(r.Runtime)
+		} else {
+			this = r.Runtime.NewObject()
+		}
+		obj := fn(r.context(), goja.ConstructorCall{This: this, Arguments: call.Arguments})
+		if obj == nil {
+			return this
+		}
+		return obj
+	})
+}
+
+// wrapConstructor wraps the Go function fn in a pure-JS function to allow it to
+// be `new`d, the object created by `new` is passed as the this of fn.
+func (r *Runtime) wrapConstructor(fn interface{}) goja.Value {
+	wrapperV, _ := r.Runtime.RunProgram(constructWrap)
+	wrapper, _ := goja.AssertFunction(wrapperV)
+	v, _ := wrapper(goja.Undefined(), r.Runtime.ToValue(fn))
+	return v
+}
+
+// throwRuntimeNotFound rethrows the ErrRuntimeNotFound panic of MustRuntime as a JS error,
+// it must be deferred.
+func throwRuntimeNotFound(r *Runtime) {
+	if rec := recover(); rec != nil {
+		if err, ok := rec.(error); ok && err == ErrRuntimeNotFound {
+			Throw(r, err)
+			return
+		}
+		panic(rec)
+	}
+}
+
+// ExportToNamed is like goja's ExportTo, but the returned error tells the name
+// of the argument (or field) and the Go type it was expected to be.
+func (r *Runtime) ExportToNamed(v goja.Value, target interface{}, argName string) error {
+	if err := r.Runtime.ExportTo(v, target); err != nil {
+		return fmt.Errorf("invalid %s: expected %s, got %s: %w",
+			argName, reflect.TypeOf(target).Elem(), describeValue(v), err)
+	}
+	return nil
+}
+
+// validator is implemented by the targets of ExportStruct which check their fields.
+type validator interface {
+	Validate() error
+}
+
+// ExportStruct exports the JS object v to the struct target points to, its keys
+// are the JS names of the fields, see FieldName, e.g. max_redirects for
+// MaxRedirects, like the fields of the bound values. The fields missing from v,
+// or undefined, keep their values, so target may hold the defaults; undefined
+// and null v are exported as an empty object.
+//
+// When target has a Validate() error method, it's called once all the fields
+// are exported and its error is returned.
+func (r *Runtime) ExportStruct(v goja.Value, target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("the target must be a non-nil pointer to a struct, got %T", target)
+	}
+	if v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		obj, ok := v.(*goja.Object)
+		if !ok {
+			return fmt.Errorf("invalid %s: expected an object, got %s", val.Elem().Type(), describeValue(v))
+		}
+		if err := r.exportFields(obj, val.Elem()); err != nil {
+			return err
+		}
+	}
+	if t, ok := target.(validator); ok {
+		return t.Validate()
+	}
+	return nil
+}
+
+// exportFields exports the keys of obj to the exported fields of the struct val.
+func (r *Runtime) exportFields(obj *goja.Object, val reflect.Value) error {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name := FieldName(typ, typ.Field(i))
+		if name == "" {
+			continue
+		}
+		fv := obj.Get(name)
+		if fv == nil || goja.IsUndefined(fv) {
+			continue
+		}
+		if err := r.ExportToNamed(fv, val.Field(i).Addr().Interface(), "field "+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// argName returns the name of the idx (zero based) user argument of the JS method.
+func argName(method string, idx int) string {
+	return fmt.Sprintf("argument #%d of %s()", idx+1, method)
+}
+
+// describeValue returns the JS type of the value, used by the error messages.
+func describeValue(v goja.Value) string {
+	switch {
+	case v == nil || goja.IsUndefined(v):
+		return "undefined"
+	case goja.IsNull(v):
+		return "null"
+	}
+	if obj, ok := v.(*goja.Object); ok {
+		return obj.ClassName()
+	}
+	return reflect.TypeOf(v.Export()).String()
+}
+
+// LoadModule binds the module registered with the given name to the runtime,
+// under its conventional global name, see ModuleGlobalName, e.g. "data" for
+// "k6/data", like the modules of RuntimeOptions.Modules.
+func (r *Runtime) LoadModule(name string) error {
+	mod := modules.Get(name)
+	if mod == nil {
+		return fmt.Errorf("unknown module: %s", name)
+	}
+	r.Bind(ModuleGlobalName(name), mod)
+	return nil
+}
+
+// ModuleGlobalName returns the conventional name of the global of the module
+// registered with name, the last element of its name, e.g. "data" for "k6/data".
+func ModuleGlobalName(name string) string {
+	return path.Base(name)
+}
+
+// bindModules binds the modules registered with names under their conventional
+// global names, see RuntimeOptions.Modules. It fails before binding any of them
+// when a name isn't registered.
+func (r *Runtime) bindModules(names []string) error {
+	registered := modules.Enumerate()
+	for _, name := range names {
+		if _, ok := registered[name]; !ok {
+			known := make([]string, 0, len(registered))
+			for name := range registered {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown module: %s, the registered modules are: %s", name, strings.Join(known, ", "))
+		}
+	}
+	for _, name := range names {
+		r.Bind(ModuleGlobalName(name), registered[name])
+	}
+	return nil
+}
+
+// ToBindObject returns the exported methods and fields of v, keyed by their JS
+// names, see MethodName and FieldName.
+//
+// A method may take a context.Context as its first argument, it gets the
+// context of the running script. When the last result of a method is an
+// error, a non-nil error is thrown as a JS exception, an error anywhere else
+// is returned as a plain value. The remaining results are returned as is when
+// there is a single one, and packed into a JS array when there are several,
+// e.g. func() (int, string, error) returns [int, string].
+//
+// The JS names of the members can be overridden with names, see BindNames. The
+// methods which can't be called from the scripts, e.g. taking a channel or a
+// context.Context which isn't their first argument, are skipped with a warning
+// logged to the logger of the runtime, see TryBind to fail instead.
+//
+// The channels the values can be received from, returned by a method or in a field,
+// are iterators with a blocking next() method, see chanIterator. The time.Time
+// results and fields are JS Dates, see RuntimeOptions.ZeroTimeAsNull for the zero time.
+//
+// v may be:
+//   - a struct, or a pointer to it, its methods and fields are bound;
+//   - any other type with methods, e.g. a func or a map type, only its methods
+//     are bound;
+//   - a pointer to an interface, e.g. (*io.Writer)(&w), only the methods of the
+//     interface are bound and they are dispatched to the value held by it, the
+//     other methods and the fields of this value are hidden.
+//
+// The fields holding a pointer to a struct without exported fields, i.e. a
+// namespace of methods like encoding.hex, are bound like v, so their methods get
+// the context too.
+//
+// A nil v, or a pointer to a nil interface, is bound as an empty object.
+func (r *Runtime) ToBindObject(v interface{}, names ...BindNames) map[string]interface{} {
+	exports := make(map[string]interface{})
+
+	val := methodsOf(v)
+	if !val.IsValid() {
+		return exports
+	}
 	typ := val.Type()
 	for i := 0; i < typ.NumMethod(); i++ {
 		meth := typ.Method(i)
-		name := MethodName(typ, meth)
+		name := bindName(names, meth.Name, MethodName(typ, meth))
+		if name == "" {
+			continue
+		}
 		fn := val.Method(i)
+		if err := checkMethod(fn.Type()); err != nil {
+			if r.logger != nil {
+				r.logger.Warn("The method can't be called from the scripts, it isn't bound",
+					log.String("method", typ.String()+"."+meth.Name), log.Error(err))
+			}
+			continue
+		}
 
 		// Figure out if we want to do any wrapping of it.
 		fnT := fn.Type()
 		numIn := fnT.NumIn()
 		numOut := fnT.NumOut()
-		hasError := (numOut > 1 && fnT.Out(1) == errorT)
+		hasError := (numOut > 0 && fnT.Out(numOut-1) == errorT)
+		numResults := numOut
+		if hasError {
+			numResults--
+		}
 		wantsContext := false
 
 		if numIn > 0 {
@@ -143,7 +472,13 @@ func (r *Runtime) ToBindObject(v interface{}) map[string]interface{} {
 				wantsContext = true
 			}
 		}
-		if hasError || wantsContext {
+		convertsResult := false
+		for i := 0; i < numResults; i++ {
+			if out := fnT.Out(i); isRecvChan(out) || out == timeT {
+				convertsResult = true
+			}
+		}
+		if hasError || wantsContext || numResults > 1 || convertsResult {
 			isVariadic := fnT.IsVariadic()
 			realFn := fn
 			fn = reflect.ValueOf(func(call goja.FunctionCall) goja.Value {
@@ -154,8 +489,9 @@ func (r *Runtime) ToBindObject(v interface{}) map[string]interface{} {
 				// Inject any requested parameters, and reserve them to offset user args.
 				reservedArgs := 0
 				if wantsContext {
-					args[0] = reflect.ValueOf(r.ctx)
+					args[0] = reflect.ValueOf(r.context())
 					reservedArgs++
+					defer throwRuntimeNotFound(r)
 				}
 
 				// Copy over arguments.
@@ -184,7 +520,7 @@ func (r *Runtime) ToBindObject(v interface{}) map[string]interface{} {
 						for j := 0; j < varArgsLen; j++ {
 							arg := call.Arguments[i+j-reservedArgs]
 							v := reflect.New(emT)
-							if err := r.Runtime.ExportTo(arg, v.Interface()); err != nil {
+							if err := r.ExportToNamed(arg, v.Interface(), argName(name, i+j-reservedArgs)); err != nil {
 								Throw(r, err)
 							}
 							varArgs.Index(j).Set(v.Elem())
@@ -207,7 +543,7 @@ func (r *Runtime) ToBindObject(v interface{}) map[string]interface{} {
 
 					// Allocate a T* and export the JS value to it.
 					v := reflect.New(T)
-					if err := r.Runtime.ExportTo(arg, v.Interface()); err != nil {
+					if err := r.ExportToNamed(arg, v.Interface(), argName(name, i-reservedArgs)); err != nil {
 						Throw(r, err)
 					}
 					args[i] = v.Elem()
@@ -220,40 +556,77 @@ func (r *Runtime) ToBindObject(v interface{}) map[string]interface{} {
 					ret = realFn.Call(args)
 				}
 
-				if len(ret) > 0 {
-					if hasError && !ret[1].IsNil() {
-						Throw(r, ret[1].Interface().(error))
+				if hasError && !ret[numResults].IsNil() {
+					Throw(r, ret[numResults].Interface().(error))
+				}
+				switch numResults {
+				case 0:
+					return goja.Undefined()
+				case 1:
+					return r.toResultValue(ret[0])
+				default:
+					results := make([]interface{}, numResults)
+					for i := range results {
+						results[i] = r.toResultValue(ret[i])
 					}
-					return r.Runtime.ToValue(ret[0].Interface())
+					return r.Runtime.NewArray(results...)
 				}
-				return goja.Undefined()
 			})
 		}
 
-		// X-Prefixed methods are assumed to be constructors; use a closure to wrap them in a
-		// pure-JS function to allow them to be `new`d. (This is an awful hack...)
+		// X-Prefixed methods are assumed to be constructors, kept for the backward
+		// compatibility, new code should use RegisterConstructor.
 		if meth.Name[0] == 'X' {
-			wrapperV, _ := r.Runtime.RunProgram(constructWrap)
-			wrapper, _ := goja.AssertFunction(wrapperV)
-			v, _ := wrapper(goja.Undefined(), r.Runtime.ToValue(fn.Interface()))
-			exports[name] = v
+			exports[name] = r.wrapConstructor(fn.Interface())
 		} else {
 			exports[name] = fn.Interface()
 		}
 	}
 
-	// If v is a pointer, we need to indirect it to access fields.
+	// The fields are bound for the structs only, and never through an interface.
 	if typ.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return exports
+		}
 		val = val.Elem()
 		typ = val.Type()
 	}
+	if typ.Kind() != reflect.Struct {
+		return exports
+	}
 	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
-		name := FieldName(typ, field)
-		if name != "" {
+		if field.PkgPath != "" {
+			continue
+		}
+		name := bindName(names, field.Name, FieldName(typ, field))
+		if name == "" {
+			continue
+		}
+		if isRecvChan(field.Type) {
+			exports[name] = r.chanIterator(val.Field(i))
+		} else if isNamespace(field.Type) && !val.Field(i).IsNil() {
+			exports[name] = r.ToBindObject(val.Field(i).Interface())
+		} else if field.Type == timeT {
+			exports[name] = r.dateValue(val.Field(i).Interface().(time.Time))
+		} else {
 			exports[name] = val.Field(i).Interface()
 		}
 	}
 
 	return exports
 }
+
+// isNamespace returns true if t is a pointer to a struct without exported fields,
+// which can't refer back to the value binding it, see ToBindObject.
+func isNamespace(t reflect.Type) bool {
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || t.Elem() == timeT {
+		return false
+	}
+	for i := 0; i < t.Elem().NumField(); i++ {
+		if t.Elem().Field(i).PkgPath == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -24,12 +24,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/runner-mei/log/logtest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -136,6 +139,24 @@ func (bridgeTestAddWithErrorType) AddWithError(a, b int) (int, error) {
 	return res, nil
 }
 
+type bridgeTestMultiType struct{}
+
+func (bridgeTestMultiType) Pair() (int, string) { return 1, "a" }
+
+func (bridgeTestMultiType) One(fail bool) (int, error) {
+	if fail {
+		return 0, errors.New("one failed")
+	}
+	return 1, nil
+}
+
+func (bridgeTestMultiType) PairWithError(fail bool) (int, string, error) {
+	if fail {
+		return 0, "", errors.New("pair failed")
+	}
+	return 1, "a", nil
+}
+
 type bridgeTestContextType struct{}
 
 func (bridgeTestContextType) Context(ctx context.Context) {}
@@ -225,6 +246,28 @@ func (bridgeTestConstructorType) XConstructor() bridgeTestConstructorSpawnedType
 	return bridgeTestConstructorSpawnedType{}
 }
 
+type bridgeTestExportType struct{}
+
+type bridgeTestPoint struct{ X, Y int }
+
+func (bridgeTestExportType) Sum(p bridgeTestPoint) (int, error) { return p.X + p.Y, nil }
+
+func TestExportToNamed(t *testing.T) {
+	rt := New()
+	rt.Bind("obj", bridgeTestExportType{})
+
+	ctx := context.Background()
+	v, err := rt.RunString(ctx, `obj.sum({x: 1, y: 2})`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), v.Export())
+	}
+
+	_, err = rt.RunString(ctx, `obj.sum(1)`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid argument #1 of sum(): expected gojs.bridgeTestPoint, got int64")
+	}
+}
+
 func TestFieldNameMapper(t *testing.T) {
 	testdata := []struct {
 		Typ     reflect.Type
@@ -459,6 +502,27 @@ func TestBind(t *testing.T) {
 				assert.Contains(t, err.Error(), "GoError: answer is negative")
 			})
 		}},
+		{"Multi", bridgeTestMultiType{}, func(t *testing.T, ctx context.Context, obj interface{}, rt *Runtime) {
+			v, err := rt.RunString(ctx, `obj.pair()`)
+			if assert.NoError(t, err) {
+				assert.Equal(t, []interface{}{int64(1), "a"}, v.Export())
+			}
+			v, err = rt.RunString(ctx, `obj.one(false)`)
+			if assert.NoError(t, err) {
+				assert.Equal(t, int64(1), v.Export())
+			}
+			v, err = rt.RunString(ctx, `obj.pairWithError(false)`)
+			if assert.NoError(t, err) {
+				assert.Equal(t, []interface{}{int64(1), "a"}, v.Export())
+			}
+
+			t.Run("Error", func(t *testing.T) {
+				_, err := rt.RunString(ctx, `obj.one(true)`)
+				assert.Contains(t, err.Error(), "GoError: one failed")
+				_, err = rt.RunString(ctx, `obj.pairWithError(true)`)
+				assert.Contains(t, err.Error(), "GoError: pair failed")
+			})
+		}},
 		{"Context", bridgeTestContextType{}, func(t *testing.T, ctx context.Context, obj interface{}, rt *Runtime) {
 			// _, err := rt.RunString(ctx, `obj.context()`)
 			// assert.Contains(t, err.Error(), "GoError: context() can only be called from within default()")
@@ -632,6 +696,44 @@ func TestBind(t *testing.T) {
 	}
 }
 
+func TestRegisterConstructor(t *testing.T) {
+	type ctxKey struct{}
+
+	rt := New()
+	rt.RegisterConstructor("Point", func(ctx context.Context, call goja.ConstructorCall) *goja.Object {
+		_ = call.This.Set("x", call.Argument(0))
+		_ = call.This.Set("tag", ctx.Value(ctxKey{}))
+		return nil
+	})
+	rt.RegisterConstructor("Other", func(ctx context.Context, call goja.ConstructorCall) *goja.Object {
+		obj := rt.Runtime.NewObject()
+		_ = obj.Set("other", true)
+		return obj
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "a")
+	v, err := rt.RunString(ctx, `var p = new Point(1); [p.x, p.tag, p instanceof Point]`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []interface{}{int64(1), "a", true}, v.Export())
+	}
+	v, err = rt.RunString(ctx, `Point(2).x`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), v.Export())
+	}
+	v, err = rt.RunString(ctx, `new Other().other`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, true, v.Export())
+	}
+
+	clone, err := rt.Clone()
+	if assert.NoError(t, err) {
+		v, err = clone.RunString(ctx, `new Point(3).x`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(3), v.Export())
+		}
+	}
+}
+
 func BenchmarkProxy(b *testing.B) {
 	types := []struct {
 		Name, FnName string
@@ -779,3 +881,252 @@ func BenchmarkProxy(b *testing.B) {
 		})
 	}
 }
+
+type bridgeTestChanType struct {
+	Events <-chan string
+}
+
+func (bridgeTestChanType) Numbers(n int) <-chan int {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := 0; i < n; i++ {
+			ch <- i
+		}
+	}()
+	return ch
+}
+
+func (bridgeTestChanType) Never() <-chan int {
+	return make(chan int)
+}
+
+func TestBindChan(t *testing.T) {
+	events := make(chan string, 2)
+	events <- "a"
+	events <- "b"
+	close(events)
+
+	vm, err := NewWith(&RuntimeOptions{CompatibilityMode: "extended"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	vm.Bind("stream", bridgeTestChanType{Events: events})
+
+	ctx := context.Background()
+	ret, err := vm.RunString(ctx, `
+		var values = [];
+		for (var r = stream.events.next(); !r.done; r = stream.events.next()) {
+			values.push(r.value);
+		}
+		values.push(stream.events.next().done);
+		for (const n of stream.numbers(3)) {
+			values.push(n);
+		}
+		values.join(",")`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a,b,true,0,1,2", ret.String())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = vm.RunString(ctx, `stream.never().next()`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "context deadline exceeded")
+	}
+}
+
+type bridgeTestNamesType struct {
+	ID       string
+	UserName string
+	Secret   string
+	internal string
+}
+
+func (bridgeTestNamesType) GetID() string { return "method" }
+
+func TestBindNames(t *testing.T) {
+	v := bridgeTestNamesType{ID: "a", UserName: "b", Secret: "c", internal: "d"}
+	names := BindNames{"ID": "ID", "GetID": "getID", "Secret": "", "internal": "internal"}
+
+	vm := New()
+	vm.Bind("obj", v, names)
+	vm.Bind("def", v)
+	check := func(vm *Runtime) {
+		ret, err := vm.RunString(context.Background(), `[
+			obj.ID, obj.user_name, typeof obj.id, typeof obj.secret, typeof obj.internal,
+			obj.getID(), typeof obj.getId,
+			def.id, typeof def.ID, def.secret, def.getId()
+		].join()`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "a,b,undefined,undefined,undefined,method,undefined,a,undefined,c,method", ret.String())
+		}
+	}
+	check(vm)
+
+	// The names are kept by the clones.
+	clone, err := vm.Clone()
+	if assert.NoError(t, err) {
+		check(clone)
+	}
+
+	// The later names take precedence.
+	exports := vm.ToBindObject(v, names, BindNames{"ID": "identifier"})
+	assert.Contains(t, exports, "identifier")
+	assert.NotContains(t, exports, "ID")
+	assert.Contains(t, exports, "getID")
+}
+
+type bridgeTestUnbindableType struct{}
+
+func (bridgeTestUnbindableType) Ok(ctx context.Context, a int) int { return a }
+
+func (bridgeTestUnbindableType) Chan(ch chan int) {}
+
+func (bridgeTestUnbindableType) LateContext(a int, ctx context.Context) {}
+
+func (bridgeTestUnbindableType) LateCall(call goja.FunctionCall, a int) {}
+
+func TestBindUnbindable(t *testing.T) {
+	logger, observedLogs := logtest.NewObservedLogger()
+	vm := New()
+	vm.SetLogger(logger)
+
+	err := vm.TryBind("obj", bridgeTestUnbindableType{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "method Chan: the argument 1 of type chan int can't be converted from a JS value")
+		assert.Contains(t, err.Error(), "method LateContext: the context.Context is the argument 2")
+		assert.Contains(t, err.Error(), "method LateCall: the goja.FunctionCall is the argument 1")
+	}
+	assert.Nil(t, vm.Get("obj"))
+	assert.Empty(t, observedLogs.All())
+
+	// The hidden methods aren't checked.
+	hidden := BindNames{"Chan": "", "LateContext": "", "LateCall": ""}
+	assert.NoError(t, vm.TryBind("obj", bridgeTestUnbindableType{}, hidden))
+
+	// Bind skips them with a warning.
+	vm.Bind("skipped", bridgeTestUnbindableType{})
+	ret, err := vm.RunString(context.Background(), `[obj.ok(1), typeof skipped.chan, typeof skipped.lateContext, skipped.ok(2)].join()`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1,undefined,undefined,2", ret.String())
+	}
+	var methods []string
+	for _, entry := range observedLogs.All() {
+		methods = append(methods, entry.ContextMap()["method"].(string))
+	}
+	assert.ElementsMatch(t, []string{
+		"gojs.bridgeTestUnbindableType.Chan",
+		"gojs.bridgeTestUnbindableType.LateContext",
+		"gojs.bridgeTestUnbindableType.LateCall",
+	}, methods)
+}
+
+type bridgeTestFuncType func() string
+
+func (f bridgeTestFuncType) Call() string { return f() }
+
+func TestBindInterface(t *testing.T) {
+	var sb strings.Builder
+	var w io.StringWriter = &sb
+
+	vm := New()
+	vm.Bind("w", &w)
+	ret, err := vm.RunString(context.Background(), `[w.writeString("abc"), w.writeString("de"), typeof w.len, typeof w.string].join()`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3,2,undefined,undefined", ret.String())
+	}
+	assert.Equal(t, "abcde", sb.String())
+
+	// The values which aren't structs have no fields.
+	vm.Bind("fn", bridgeTestFuncType(func() string { return "called" }))
+	ret, err = vm.RunString(context.Background(), `fn.call()`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "called", ret.String())
+	}
+
+	var nilWriter io.StringWriter
+	assert.Empty(t, vm.ToBindObject(&nilWriter))
+	assert.Empty(t, vm.ToBindObject(nil))
+	assert.NoError(t, vm.TryBind("nilWriter", &nilWriter))
+}
+
+type bridgeTestNamespace struct{ prefix string }
+
+func (n *bridgeTestNamespace) Iteration(ctx context.Context) string {
+	return n.prefix + strconv.FormatInt(GetIteration(ctx), 10)
+}
+
+type bridgeTestModule struct {
+	Sub *bridgeTestNamespace
+}
+
+func TestBindNamespaceField(t *testing.T) {
+	vm := New()
+	vm.Bind("mod", &bridgeTestModule{Sub: &bridgeTestNamespace{prefix: "iter "}})
+	ret, err := vm.RunString(WithIteration(context.Background(), 3), `mod.sub.iteration()`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "iter 3", ret.String())
+	}
+}
+
+type bridgeTestOptions struct {
+	MaxRedirects int
+	UserAgent    string
+	Timeout      time.Duration `js:"timeout_ms"`
+	Tags         map[string]string
+	Origin       bridgeTestPoint
+	Ignored      string `js:"-"`
+	hidden       string
+}
+
+func (o *bridgeTestOptions) Validate() error {
+	if o.MaxRedirects < 0 {
+		return errors.New("max_redirects can't be negative")
+	}
+	return nil
+}
+
+func TestExportStruct(t *testing.T) {
+	vm := New()
+	export := func(src string, opts *bridgeTestOptions) error {
+		v, err := vm.RunString(context.Background(), "("+src+")")
+		if !assert.NoError(t, err) {
+			return err
+		}
+		return vm.ExportStruct(v, opts)
+	}
+
+	opts := bridgeTestOptions{UserAgent: "default", Ignored: "kept"}
+	if assert.NoError(t, export(`{max_redirects: 3, timeout_ms: 5, tags: {a: "b"}, origin: {x: 1, y: 2}, ignored: "x", hidden: "x", MaxRedirects: 4}`, &opts)) {
+		assert.Equal(t, bridgeTestOptions{
+			MaxRedirects: 3,
+			UserAgent:    "default",
+			Timeout:      5,
+			Tags:         map[string]string{"a": "b"},
+			Origin:       bridgeTestPoint{X: 1, Y: 2},
+			Ignored:      "kept",
+		}, opts)
+	}
+
+	opts = bridgeTestOptions{UserAgent: "default"}
+	assert.NoError(t, export(`undefined`, &opts))
+	assert.Equal(t, bridgeTestOptions{UserAgent: "default"}, opts)
+
+	err := export(`{max_redirects: -1}`, &opts)
+	if assert.Error(t, err) {
+		assert.Equal(t, "max_redirects can't be negative", err.Error())
+	}
+
+	err = export(`{origin: 1}`, &opts)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid field origin: expected gojs.bridgeTestPoint, got int64")
+	}
+
+	err = export(`"str"`, &opts)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expected an object, got string")
+	}
+
+	assert.Error(t, vm.ExportStruct(goja.Undefined(), opts))
+}
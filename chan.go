@@ -0,0 +1,91 @@
+package gojs
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// chanIteratorWrap makes an iterator object from its next function, see chanIterator.
+var chanIteratorWrap = goja.MustCompile(
+	"__chan_iterator__",
+	`(function(next) {
+		var it = {next: next};
+		if (typeof Symbol === "function" && Symbol.iterator) {
+			it[Symbol.iterator] = function() { return this; };
+		}
+		return it;
+	})`,
+	true,
+)
+
+// isRecvChan returns true if t is a channel the values can be received from.
+func isRecvChan(t reflect.Type) bool {
+	return t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0
+}
+
+// toResultValue converts a value returned to the scripts by a bound method or field,
+// the channels are converted with chanIterator and the times with dateValue.
+func (r *Runtime) toResultValue(v reflect.Value) goja.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.IsValid() && isRecvChan(v.Type()) {
+		return r.chanIterator(v)
+	}
+	if v.IsValid() && v.Type() == timeT {
+		return r.dateValue(v.Interface().(time.Time))
+	}
+	return r.Runtime.ToValue(v.Interface())
+}
+
+// chanIterator exposes the channel ch to the scripts as an iterator, with a next()
+// method returning {value, done} like the JS iterators, so it also works with
+// for...of in the extended compatibility mode.
+//
+// next() blocks the script until the next value is received, and returns done once
+// the channel is closed, a nil channel is done from the start. Nothing is read ahead,
+// so a producer writing to an unbuffered channel blocks until the script asks for
+// the value, which is the backpressure. When the context of the script is done
+// while waiting, next() throws.
+func (r *Runtime) chanIterator(ch reflect.Value) goja.Value {
+	next := func(call goja.FunctionCall) goja.Value {
+		result := r.Runtime.NewObject()
+		if ch.IsNil() {
+			_ = result.Set("value", goja.Undefined())
+			_ = result.Set("done", true)
+			return result
+		}
+
+		ctx := r.context()
+		chosen, v, ok := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: ch},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		})
+		if chosen == 1 {
+			Throw(r, fmt.Errorf("waiting for the next value of the channel: %w", ctx.Err()))
+			return nil
+		}
+		if !ok {
+			_ = result.Set("value", goja.Undefined())
+			_ = result.Set("done", true)
+			return result
+		}
+		_ = result.Set("value", r.toResultValue(v))
+		_ = result.Set("done", false)
+		return result
+	}
+
+	wrap, err := r.Runtime.RunProgram(chanIteratorWrap)
+	if err != nil {
+		panic(err)
+	}
+	fn, _ := goja.AssertFunction(wrap)
+	it, err := fn(goja.Undefined(), r.Runtime.ToValue(next))
+	if err != nil {
+		panic(err)
+	}
+	return it
+}
@@ -23,7 +23,11 @@ package gojs
 
 import (
 	"context"
+	"math"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/runner-mei/log"
@@ -32,49 +36,335 @@ import (
 // console represents a JS console implemented as a log.Logger.
 type console struct {
 	logger log.Logger
+	buffer *ConsoleBuffer
+	state  *consoleState
+}
+
+// consoleState are the counters of console.count() and the timers of console.time(),
+// by label.
+type consoleState struct {
+	mu     sync.Mutex
+	counts map[string]int
+	timers map[string]time.Time
+}
+
+func newConsoleState() *consoleState {
+	return &consoleState{counts: map[string]int{}, timers: map[string]time.Time{}}
 }
 
 // Creates a console with the standard log logger.
 func newConsole(logger log.Logger) *console {
-	return &console{logger: logger}
+	return &console{logger: logger, state: newConsoleState()}
 }
 
-func (c console) Log(ctx context.Context, msg goja.Value, args ...goja.Value) {
-	fields := make([]log.Field, 0, len(args))
-	for i, arg := range args {
-		fields = append(fields, log.Stringer(strconv.Itoa(i), arg))
+// cloneForRuntime returns the console bound to the clones of a runtime, it writes
+// to the same logger and buffer but has its own counters and timers, so the
+// concurrent runtimes don't share them.
+func (c *console) cloneForRuntime() interface{} {
+	return &console{logger: c.logger, buffer: c.buffer, state: newConsoleState()}
+}
+
+// NewConsole returns a console to be bound with Bind, it writes to logger, or to
+// the logger of the context of the scripts when it's nil. When buffer isn't nil,
+// the messages are also kept in it.
+func NewConsole(logger log.Logger, buffer *ConsoleBuffer) interface{} {
+	return &console{logger: logger, buffer: buffer, state: newConsoleState()}
+}
+
+// ConsoleEntry is a message written to a console, see ConsoleBuffer.
+type ConsoleEntry struct {
+	Time  time.Time
+	Level log.Level
+	// Message is the message and the arguments of the call, separated by spaces.
+	Message string
+}
+
+// ConsoleBuffer keeps the last messages written to the consoles using it, the
+// oldest ones are evicted once it's full. It's safe for concurrent use.
+type ConsoleBuffer struct {
+	mu      sync.Mutex
+	entries []ConsoleEntry
+	next    int // the index of the next entry, and of the oldest one once full
+	full    bool
+}
+
+// NewConsoleBuffer returns a ConsoleBuffer keeping up to size entries.
+func NewConsoleBuffer(size int) *ConsoleBuffer {
+	if size < 1 {
+		size = 1
 	}
-	c.logger.Info(msg.String(), fields...)
+	return &ConsoleBuffer{entries: make([]ConsoleEntry, size)}
 }
 
-func (c console) Debug(ctx context.Context, msg goja.Value, args ...goja.Value) {
-	fields := make([]log.Field, 0, len(args))
-	for i, arg := range args {
-		fields = append(fields, log.Stringer(strconv.Itoa(i), arg))
+// Entries returns a copy of the entries of the buffer, from the oldest one.
+func (b *ConsoleBuffer) Entries() []ConsoleEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]ConsoleEntry(nil), b.entries[:b.next]...)
 	}
-	c.logger.Debug(msg.String(), fields...)
+	entries := make([]ConsoleEntry, 0, len(b.entries))
+	entries = append(entries, b.entries[b.next:]...)
+	return append(entries, b.entries[:b.next]...)
 }
 
-func (c console) Info(ctx context.Context, msg goja.Value, args ...goja.Value) {
-	fields := make([]log.Field, 0, len(args))
-	for i, arg := range args {
-		fields = append(fields, log.Stringer(strconv.Itoa(i), arg))
+func (b *ConsoleBuffer) add(entry ConsoleEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = entry
+	b.next++
+	if b.next == len(b.entries) {
+		b.next = 0
+		b.full = true
 	}
-	c.logger.Info(msg.String(), fields...)
 }
 
-func (c console) Warn(ctx context.Context, msg goja.Value, args ...goja.Value) {
-	fields := make([]log.Field, 0, len(args))
-	for i, arg := range args {
-		fields = append(fields, log.Stringer(strconv.Itoa(i), arg))
+// record adds the message to the buffer of the console, if it has one.
+func (c *console) record(level log.Level, msg string, args []goja.Value) {
+	if c.buffer == nil {
+		return
+	}
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, msg)
+	for _, arg := range args {
+		parts = append(parts, arg.String())
+	}
+	c.buffer.add(ConsoleEntry{Time: time.Now(), Level: level, Message: strings.Join(parts, " ")})
+}
+
+// getLogger returns the logger of the console, or the one attached to ctx
+// when the console has none.
+func (c *console) getLogger(ctx context.Context) log.Logger {
+	if c.logger != nil {
+		return c.logger
 	}
-	c.logger.Warn(msg.String(), fields...)
+	return GetLogger(ctx)
 }
 
-func (c console) Error(ctx context.Context, msg goja.Value, args ...goja.Value) {
+// write logs the message of a console call at level, msg is formatted with the
+// args when it has format specifiers, see formatConsole, otherwise the args are
+// logged as fields.
+func (c *console) write(ctx context.Context, level log.Level, msg goja.Value, args []goja.Value) {
+	text, args := formatConsole(ctx, msg, args)
 	fields := make([]log.Field, 0, len(args))
 	for i, arg := range args {
 		fields = append(fields, log.Stringer(strconv.Itoa(i), arg))
 	}
-	c.logger.Error(msg.String(), fields...)
+
+	logger := c.getLogger(ctx)
+	switch level {
+	case log.DebugLevel:
+		logger.Debug(text, fields...)
+	case log.WarnLevel:
+		logger.Warn(text, fields...)
+	case log.ErrorLevel:
+		logger.Error(text, fields...)
+	default:
+		logger.Info(text, fields...)
+	}
+	c.record(level, text, args)
+}
+
+// formatConsole substitutes the printf-style format specifiers of msg, when it's a
+// string, with the args like the browsers and Node.js do, the args left are
+// appended to it separated by spaces:
+//
+//	%s        the string of the argument
+//	%d, %i    the integer part of the number of the argument
+//	%f        the number of the argument
+//	%o, %O %j the JSON of the argument, or its string when it can't be stringified
+//	%c        nothing, it's the CSS of the browsers
+//	%%        a percent sign
+//
+// A specifier without an argument is left as is. When msg has no specifier, its
+// string and the args are returned unchanged.
+func formatConsole(ctx context.Context, msg goja.Value, args []goja.Value) (string, []goja.Value) {
+	format, ok := msg.Export().(string)
+	if !ok || !hasConsoleSpecifier(format) {
+		return msg.String(), args
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		ch := format[i]
+		if ch != '%' || i+1 == len(format) {
+			sb.WriteByte(ch)
+			continue
+		}
+		verb := format[i+1]
+		if verb == '%' {
+			sb.WriteByte('%')
+			i++
+			continue
+		}
+		if !strings.ContainsRune(consoleSpecifiers, rune(verb)) || len(args) == 0 {
+			sb.WriteByte(ch)
+			continue
+		}
+
+		arg := args[0]
+		args = args[1:]
+		i++
+		switch verb {
+		case 's':
+			sb.WriteString(arg.String())
+		case 'd', 'i':
+			sb.WriteString(formatConsoleInt(arg.ToFloat()))
+		case 'f':
+			sb.WriteString(arg.ToNumber().String())
+		case 'o', 'O', 'j':
+			sb.WriteString(consoleJSON(ctx, arg))
+		case 'c':
+			// The CSS of the browsers has no meaning here.
+		}
+	}
+	for _, arg := range args {
+		sb.WriteByte(' ')
+		sb.WriteString(arg.String())
+	}
+	return sb.String(), nil
+}
+
+const consoleSpecifiers = "sdifoOjc"
+
+// formatConsoleInt returns the integer part of f formatted like a JS number.
+func formatConsoleInt(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	f = math.Trunc(f)
+	if f == 0 {
+		return "0" // and not -0
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// hasConsoleSpecifier returns true if format has a format specifier of formatConsole.
+func hasConsoleSpecifier(format string) bool {
+	for i := 0; i+1 < len(format); i++ {
+		if format[i] == '%' && strings.ContainsRune(consoleSpecifiers+"%", rune(format[i+1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// consoleJSON returns the JSON of v, or its string when it can't be stringified.
+func consoleJSON(ctx context.Context, v goja.Value) string {
+	rt := GetRuntime(ctx)
+	if rt == nil {
+		return v.String()
+	}
+	stringify, ok := goja.AssertFunction(rt.Runtime.Get("JSON").ToObject(rt.Runtime).Get("stringify"))
+	if !ok {
+		return v.String()
+	}
+	ret, err := stringify(goja.Undefined(), v)
+	if err != nil || goja.IsUndefined(ret) {
+		return v.String()
+	}
+	return ret.String()
+}
+
+func (c *console) Log(ctx context.Context, msg goja.Value, args ...goja.Value) {
+	c.write(ctx, log.InfoLevel, msg, args)
+}
+
+func (c *console) Debug(ctx context.Context, msg goja.Value, args ...goja.Value) {
+	c.write(ctx, log.DebugLevel, msg, args)
+}
+
+func (c *console) Info(ctx context.Context, msg goja.Value, args ...goja.Value) {
+	c.write(ctx, log.InfoLevel, msg, args)
+}
+
+func (c *console) Warn(ctx context.Context, msg goja.Value, args ...goja.Value) {
+	c.write(ctx, log.WarnLevel, msg, args)
+}
+
+func (c *console) Error(ctx context.Context, msg goja.Value, args ...goja.Value) {
+	c.write(ctx, log.ErrorLevel, msg, args)
+}
+
+// consoleLabel returns the label of console.count() or console.time(), "default"
+// when it's missing.
+func consoleLabel(label goja.Value) string {
+	if label == nil || goja.IsUndefined(label) {
+		return "default"
+	}
+	return label.String()
+}
+
+// Count increments the counter of the label, "default" when it's missing, and
+// logs "label: N". The counters are kept by the console, the clones of a runtime
+// get a console with counters of their own, see cloneForRuntime.
+func (c *console) Count(ctx context.Context, label goja.Value) {
+	state := c.state
+	name := consoleLabel(label)
+	state.mu.Lock()
+	state.counts[name]++
+	n := state.counts[name]
+	state.mu.Unlock()
+
+	msg := name + ": " + strconv.Itoa(n)
+	c.getLogger(ctx).Info(msg)
+	c.record(log.InfoLevel, msg, nil)
+}
+
+// CountReset resets the counter of the label, nothing is done for an unknown label.
+func (c *console) CountReset(label goja.Value) {
+	state := c.state
+	state.mu.Lock()
+	delete(state.counts, consoleLabel(label))
+	state.mu.Unlock()
+}
+
+// Time starts the timer of the label, "default" when it's missing, a running timer
+// isn't restarted and a warning is logged. The timers are kept like the counters
+// of Count.
+func (c *console) Time(ctx context.Context, label goja.Value) {
+	state := c.state
+	name := consoleLabel(label)
+	state.mu.Lock()
+	_, exists := state.timers[name]
+	if !exists {
+		state.timers[name] = time.Now()
+	}
+	state.mu.Unlock()
+
+	if exists {
+		msg := "Label '" + name + "' already exists for console.time()"
+		c.getLogger(ctx).Warn(msg)
+		c.record(log.WarnLevel, msg, nil)
+	}
+}
+
+// TimeEnd stops the timer of the label and logs "label: <elapsed>ms", a warning is
+// logged when there is no such timer. The elapsed time is measured with the
+// monotonic clock.
+func (c *console) TimeEnd(ctx context.Context, label goja.Value) {
+	state := c.state
+	name := consoleLabel(label)
+	state.mu.Lock()
+	start, exists := state.timers[name]
+	delete(state.timers, name)
+	state.mu.Unlock()
+
+	if !exists {
+		msg := "No such label '" + name + "' for console.timeEnd()"
+		c.getLogger(ctx).Warn(msg)
+		c.record(log.WarnLevel, msg, nil)
+		return
+	}
+	elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+	msg := name + ": " + strconv.FormatFloat(elapsed, 'f', 3, 64) + "ms"
+	c.getLogger(ctx).Info(msg)
+	c.record(log.InfoLevel, msg, nil)
 }
@@ -25,7 +25,10 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/runner-mei/log"
 	"github.com/runner-mei/log/logtest"
@@ -36,7 +39,7 @@ func TestConsoleContext(t *testing.T) {
 	rt.SetFieldNameMapper(FieldNameMapper{})
 
 	logger, logEntries := logtest.NewObservedLogger()
-	rt.Bind("console", &console{logger})
+	rt.Bind("console", newConsole(logger))
 
 	ctx := context.Background()
 	_, err := rt.RunString(ctx, `console.log("a")`)
@@ -79,7 +82,7 @@ func TestConsole(t *testing.T) {
 					rt.SetFieldNameMapper(FieldNameMapper{})
 
 					logger, logEntries := logtest.NewObservedLogger()
-					rt.Bind("console", &console{logger})
+					rt.Bind("console", newConsole(logger))
 
 					ctx := context.Background()
 
@@ -119,3 +122,229 @@ func TestConsole(t *testing.T) {
 		})
 	}
 }
+
+func TestConsoleRuntimeLogger(t *testing.T) {
+	rt := New()
+	rt.SetFieldNameMapper(FieldNameMapper{})
+
+	logger, logEntries := logtest.NewObservedLogger()
+	rt.SetLogger(logger)
+	rt.Bind("console", newConsole(nil))
+
+	_, err := rt.RunString(context.Background(), `console.log("a")`)
+	if err != nil {
+		t.Error(err)
+	}
+	if exists, entry := logtest.LastEntry(logEntries); exists {
+		if "a" != entry.Message {
+			t.Error("excepted a got", entry.Message)
+		}
+	} else {
+		t.Error("nothing logged")
+	}
+
+	// the logger attached to the context takes precedence
+	ctxLogger, ctxLogEntries := logtest.NewObservedLogger()
+	_, err = rt.RunString(WithLogger(context.Background(), ctxLogger), `console.log("b")`)
+	if err != nil {
+		t.Error(err)
+	}
+	if exists, entry := logtest.LastEntry(ctxLogEntries); !exists || "b" != entry.Message {
+		t.Error("excepted b got", entry.Message)
+	}
+	if _, entry := logtest.LastEntry(logEntries); "a" != entry.Message {
+		t.Error("excepted a got", entry.Message)
+	}
+}
+
+func TestGetLoggerDefault(t *testing.T) {
+	if GetLogger(context.Background()) == nil {
+		t.Fatal("excepted a no-op logger")
+	}
+}
+
+func TestConsoleBuffer(t *testing.T) {
+	rt := New()
+	logger, logEntries := logtest.NewObservedLogger()
+	buffer := NewConsoleBuffer(3)
+	rt.Bind("console", NewConsole(logger, buffer))
+
+	before := time.Now()
+	_, err := rt.RunString(context.Background(), `
+		console.log("a", 1);
+		console.debug("b");
+		console.info("c");
+		console.warn("d", "x", "y");
+		console.error("e");`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The logger still gets all the messages.
+	if n := len(logEntries.All()); n != 5 {
+		t.Fatal("excepted 5 log entries got", n)
+	}
+
+	entries := buffer.Entries()
+	if len(entries) != 3 {
+		t.Fatal(entries)
+	}
+	for i, excepted := range []ConsoleEntry{
+		{Level: log.InfoLevel, Message: "c"},
+		{Level: log.WarnLevel, Message: "d x y"},
+		{Level: log.ErrorLevel, Message: "e"},
+	} {
+		if entries[i].Level != excepted.Level || entries[i].Message != excepted.Message {
+			t.Error("excepted", excepted, "got", entries[i])
+		}
+		if entries[i].Time.Before(before) {
+			t.Error("bad time", entries[i].Time)
+		}
+	}
+
+	buffer = NewConsoleBuffer(3)
+	buffer.add(ConsoleEntry{Message: "a"})
+	if entries := buffer.Entries(); len(entries) != 1 || entries[0].Message != "a" {
+		t.Fatal(entries)
+	}
+}
+
+func TestConsoleCount(t *testing.T) {
+	rt := New()
+	logger, logEntries := logtest.NewObservedLogger()
+	rt.Bind("console", newConsole(logger))
+
+	_, err := rt.RunString(context.Background(), `
+		console.count();
+		console.count("a");
+		console.count("default");
+		console.count("a");
+		console.countReset("a");
+		console.countReset("unknown");
+		console.count("a");
+		console.count(undefined);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	for _, entry := range logEntries.All() {
+		if entry.Level != log.InfoLevel {
+			t.Error("excepted info got", entry.Level)
+		}
+		messages = append(messages, entry.Message)
+	}
+	excepted := []string{"default: 1", "a: 1", "default: 2", "a: 2", "a: 1", "default: 3"}
+	if !reflect.DeepEqual(excepted, messages) {
+		t.Error("excepted", excepted, "got", messages)
+	}
+
+	// The clones of the runtime have their own counters.
+	clone, err := rt.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clone.RunString(context.Background(), `console.count()`); err != nil {
+		t.Fatal(err)
+	}
+	if exists, entry := logtest.LastEntry(logEntries); !exists || entry.Message != "default: 1" {
+		t.Error("excepted default: 1 got", entry.Message)
+	}
+
+	// Another console has its own counters.
+	rt = New()
+	logger, logEntries = logtest.NewObservedLogger()
+	rt.Bind("console", newConsole(logger))
+	if _, err := rt.RunString(context.Background(), `console.count("a")`); err != nil {
+		t.Fatal(err)
+	}
+	if exists, entry := logtest.LastEntry(logEntries); !exists || entry.Message != "a: 1" {
+		t.Error("excepted a: 1 got", entry.Message)
+	}
+}
+
+func TestConsoleTime(t *testing.T) {
+	rt := New()
+	logger, logEntries := logtest.NewObservedLogger()
+	rt.Bind("console", newConsole(logger))
+
+	_, err := rt.RunString(context.Background(), `
+		console.time("a");
+		console.time("a");
+		console.time();
+		console.timeEnd("a");
+		console.timeEnd();
+		console.timeEnd("a");`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logEntries.All()
+	if len(entries) != 4 {
+		t.Fatal(entries)
+	}
+	if entries[0].Level != log.WarnLevel || entries[0].Message != "Label 'a' already exists for console.time()" {
+		t.Error("bad warning", entries[0].Level, entries[0].Message)
+	}
+	for i, label := range []string{"a", "default"} {
+		entry := entries[i+1]
+		if entry.Level != log.InfoLevel || !strings.HasPrefix(entry.Message, label+": ") || !strings.HasSuffix(entry.Message, "ms") {
+			t.Error("bad message", entry.Level, entry.Message)
+			continue
+		}
+		elapsed, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimPrefix(entry.Message, label+": "), "ms"), 64)
+		if err != nil || elapsed < 0 {
+			t.Error("bad elapsed time", entry.Message, err)
+		}
+	}
+	if entries[3].Level != log.WarnLevel || entries[3].Message != "No such label 'a' for console.timeEnd()" {
+		t.Error("bad warning", entries[3].Level, entries[3].Message)
+	}
+}
+
+func TestConsoleFormat(t *testing.T) {
+	cases := map[string]struct {
+		message string
+		fields  int
+	}{
+		`"x=%s", "a"`:           {"x=a", 0},
+		`"x=%d", 5.7`:           {"x=5", 0},
+		`"x=%i", -5.7`:          {"x=-5", 0},
+		`"x=%d", "abc"`:         {"x=NaN", 0},
+		`"x=%f", "1.5"`:         {"x=1.5", 0},
+		`"x=%o", {a: [1, "b"]}`: {`x={"a":[1,"b"]}`, 0},
+		`"x=%O", "s"`:           {`x="s"`, 0},
+		`"x=%j", undefined`:     {"x=undefined", 0},
+		`"%j", (function() { var o = {}; o.o = o; return o; })()`: {"[object Object]", 0},
+		`"%cstyled", "color: red"`:                                {"styled", 0},
+		`"100%% %s", "done"`:                                      {"100% done", 0},
+		`"%s and %s", "a"`:                                        {"a and %s", 0},
+		`"%s", "a", "b", 3`:                                       {"a b 3", 0},
+		`"%x %s", "a"`:                                            {"%x a", 0},
+		`"no specifier", "a", "b"`:                                {"no specifier", 2},
+		`"trailing %", "a"`:                                       {"trailing %", 1},
+	}
+
+	for args, excepted := range cases {
+		rt := New()
+		logger, logEntries := logtest.NewObservedLogger()
+		buffer := NewConsoleBuffer(1)
+		rt.Bind("console", NewConsole(logger, buffer))
+
+		if _, err := rt.RunString(context.Background(), "console.log("+args+")"); err != nil {
+			t.Error(args, err)
+			continue
+		}
+		exists, entry := logtest.LastEntry(logEntries)
+		if !exists {
+			t.Error(args, "nothing logged")
+			continue
+		}
+		if entry.Message != excepted.message || len(entry.Context) != excepted.fields {
+			t.Error(args, "excepted", excepted.message, excepted.fields, "got", entry.Message, len(entry.Context))
+		}
+		if excepted.fields == 0 && buffer.Entries()[0].Message != excepted.message {
+			t.Error(args, "excepted", excepted.message, "got", buffer.Entries()[0].Message)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package gojs
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+var timeT = reflect.TypeOf(time.Time{})
+
+// dateValue converts t to a JS Date with the millisecond precision of the Dates.
+// The zero time is the Unix epoch, or null with RuntimeOptions.ZeroTimeAsNull.
+func (r *Runtime) dateValue(t time.Time) goja.Value {
+	if t.IsZero() {
+		if r.opts.ZeroTimeAsNull {
+			return goja.Null()
+		}
+		t = time.Unix(0, 0)
+	}
+
+	ms := t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+	date, err := r.Runtime.New(r.Runtime.Get("Date"), r.Runtime.ToValue(ms))
+	if err != nil {
+		panic(err)
+	}
+	return date
+}
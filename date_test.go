@@ -0,0 +1,69 @@
+package gojs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type dateTestService struct {
+	Created time.Time
+	Zero    time.Time
+}
+
+func (s *dateTestService) Now() time.Time { return s.Created }
+
+func (s *dateTestService) Later(ctx context.Context, d int64) (time.Time, error) {
+	return s.Created.Add(time.Duration(d) * time.Millisecond), nil
+}
+
+func TestDateValue(t *testing.T) {
+	created := time.Date(2021, 3, 4, 5, 6, 7, 891234567, time.UTC)
+	ms := created.UnixNano() / int64(time.Millisecond)
+
+	vm := New()
+	vm.Bind("svc", &dateTestService{Created: created})
+	vm.Set("created", created)
+
+	for code, want := range map[string]int64{
+		`svc.now().getTime()`:       ms,
+		`svc.later(1000).getTime()`: ms + 1000,
+		`svc.created.getTime()`:     ms,
+		`created.getTime()`:         ms,
+		`svc.zero.getTime()`:        0,
+	} {
+		ret, err := vm.RunString(context.Background(), code)
+		if err != nil {
+			t.Fatal(code, err)
+		}
+		if ret.ToInteger() != want {
+			t.Fatal(code, ret, "want", want)
+		}
+	}
+
+	ret, err := vm.RunString(context.Background(),
+		`[typeof svc.now(), svc.now() instanceof Date, typeof created, created instanceof Date].join()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "object,true,object,true" {
+		t.Fatal(ret)
+	}
+}
+
+func TestZeroTimeAsNull(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{ZeroTimeAsNull: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Bind("svc", &dateTestService{})
+	vm.Set("zero", time.Time{})
+
+	ret, err := vm.RunString(context.Background(), `[svc.now(), svc.zero, zero].map(function(v) { return v === null; }).join()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "true,true,true" {
+		t.Fatal(ret)
+	}
+}
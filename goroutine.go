@@ -0,0 +1,61 @@
+package gojs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// runSeq numbers the runs of all the runtimes, so the token of a run of a
+// runtime is never the one of a run of another.
+var runSeq int64
+
+type runTokenKey struct{}
+
+// inRun returns true if ctx is the context of the running script of the runtime,
+// i.e. the one passed to the Go functions it calls.
+func (r *Runtime) inRun(ctx context.Context) bool {
+	token, _ := ctx.Value(runTokenKey{}).(int64)
+	return token != 0 && atomic.LoadInt64(&r.runOwner) == token
+}
+
+// ErrConcurrentRun is the panic of a run of a script while the runtime is already
+// running one on another goroutine, see RuntimeOptions.SerializeRuns.
+var ErrConcurrentRun = errors.New("gojs: the runtime is not concurrency-safe, it's already running a script on another goroutine")
+
+// enterRun marks the runtime as running a script until the returned function is
+// called, with a token of the run put into the returned context. When another
+// run is going on, it panics with ErrConcurrentRun, or waits for it with
+// RuntimeOptions.SerializeRuns, unless RuntimeOptions.DisableRunCheck is set.
+//
+// The nested runs, e.g. from the Go functions called by a script, are let through
+// when they're given the context the functions are called with, any other context
+// is taken for a concurrent run. The release function restores the context and
+// the VU of the outer run.
+func (r *Runtime) enterRun(ctx context.Context) (context.Context, func()) {
+	prevCtx, prevVU, prevIter := r.ctx, r.vuID, r.iter
+	restore := func() {
+		r.ctx, r.vuID, r.iter = prevCtx, prevVU, prevIter
+	}
+	if r.inRun(ctx) {
+		return ctx, restore
+	}
+
+	token := atomic.AddInt64(&runSeq, 1)
+	switch {
+	case r.opts.DisableRunCheck:
+		atomic.StoreInt64(&r.runOwner, token)
+	case r.opts.SerializeRuns:
+		r.runMutex.Lock()
+		atomic.StoreInt64(&r.runOwner, token)
+	case !atomic.CompareAndSwapInt64(&r.runOwner, 0, token):
+		panic(ErrConcurrentRun)
+	}
+	return context.WithValue(ctx, runTokenKey{}, token), func() {
+		restore()
+		atomic.CompareAndSwapInt64(&r.runOwner, token, 0)
+		if r.opts.SerializeRuns && !r.opts.DisableRunCheck {
+			r.runMutex.Unlock()
+		}
+	}
+}
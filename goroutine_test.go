@@ -0,0 +1,103 @@
+package gojs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// startBlocked runs a script blocked until release is closed, on another goroutine.
+func startBlocked(t *testing.T, vm *Runtime) (release chan struct{}, done chan struct{}) {
+	started := make(chan struct{})
+	release, done = make(chan struct{}), make(chan struct{})
+	vm.Set("block", func() {
+		close(started)
+		<-release
+	})
+	go func() {
+		defer close(done)
+		if _, err := vm.RunString(context.Background(), `block()`); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-started
+	return release, done
+}
+
+func TestConcurrentRun(t *testing.T) {
+	vm := New()
+	release, done := startBlocked(t, vm)
+
+	func() {
+		defer func() {
+			if r := recover(); r != ErrConcurrentRun {
+				t.Error("excepted", ErrConcurrentRun, "got", r)
+			}
+		}()
+		vm.RunString(context.Background(), `1`)
+		t.Error("RunString must panic")
+	}()
+
+	close(release)
+	<-done
+
+	// The runs can start again once the other one is over, and be nested.
+	vm.Set("nested", func(ctx context.Context, _ goja.FunctionCall) goja.Value {
+		return vm.MustRunString(ctx, `1 + 1`)
+	})
+	if ret := vm.MustRunString(context.Background(), `nested() + 1`); ret.ToInteger() != 3 {
+		t.Error(ret)
+	}
+}
+
+func TestSerializeRuns(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{SerializeRuns: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, done := startBlocked(t, vm)
+
+	result := make(chan int64)
+	go func() {
+		result <- vm.MustRunString(context.Background(), `1 + 2`).ToInteger()
+	}()
+	select {
+	case <-result:
+		t.Fatal("the run must wait for the other one")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-done
+	if ret := <-result; ret != 3 {
+		t.Error(ret)
+	}
+}
+
+func TestDisableRunCheck(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{DisableRunCheck: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, release := vm.enterRun(context.Background())
+	_, release2 := vm.enterRun(context.Background()) // must not panic
+	release2()
+	release()
+}
+
+func TestNestedRunRestoresContext(t *testing.T) {
+	vm := New()
+	vm.Set("nested", func(ctx context.Context, _ goja.FunctionCall) goja.Value {
+		return vm.MustRunString(WithIteration(WithVUID(ctx, 7), 9), `__VU * 100 + __ITER`)
+	})
+	vm.Set("iteration", func(ctx context.Context, _ goja.FunctionCall) goja.Value {
+		return vm.ToValue(GetIteration(ctx))
+	})
+	ctx := WithIteration(WithVUID(context.Background(), 2), 5)
+	ret := vm.MustRunString(ctx, `[nested(), __VU, __ITER, iteration()].join()`)
+	if ret.String() != "709,2,5,5" {
+		t.Error(ret)
+	}
+}
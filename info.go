@@ -0,0 +1,40 @@
+package gojs
+
+import (
+	"github.com/dop251/goja"
+	"github.com/runner-mei/gojs/js/compiler"
+	"github.com/runner-mei/gojs/lib/consts"
+)
+
+// InfoGlobal is the name of the read-only global describing the runtime to
+// the scripts, so shared libraries can detect the available features.
+const InfoGlobal = "__GOJS"
+
+// setInfo defines the frozen InfoGlobal object, e.g.
+//
+//	{
+//		version: "0.29.0",
+//		compatibilityMode: "extended",
+//		features: {extended: true, dynamicCode: true, memoryLimit: false, stepLimit: false, strictGlobals: false}
+//	}
+func (r *Runtime) setInfo() {
+	features := r.Runtime.NewObject()
+	_ = features.Set("extended", r.CompatibilityMode == compiler.CompatibilityModeExtended)
+	_ = features.Set("dynamicCode", !r.opts.DisableDynamicCode)
+	_ = features.Set("memoryLimit", r.opts.MemoryLimitBytes > 0)
+	_ = features.Set("stepLimit", r.opts.MaxSteps > 0)
+	_ = features.Set("strictGlobals", r.strictGlobals)
+
+	info := r.Runtime.NewObject()
+	_ = info.Set("version", consts.Version)
+	_ = info.Set("compatibilityMode", r.CompatibilityMode.String())
+	_ = info.Set("features", features)
+
+	freeze, _ := goja.AssertFunction(r.Runtime.Get("Object").ToObject(r.Runtime).Get("freeze"))
+	for _, obj := range []*goja.Object{features, info} {
+		if _, err := freeze(goja.Undefined(), obj); err != nil {
+			panic(err)
+		}
+	}
+	_ = r.Runtime.GlobalObject().DefineDataProperty(InfoGlobal, info, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+}
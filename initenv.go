@@ -22,8 +22,13 @@ package gojs
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"net/url"
+	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/spf13/afero"
@@ -62,6 +67,11 @@ type InitEnvironment struct {
 	// ideally, we should leave this as the only data structure necessary for
 	// executing the init context for all JS modules
 	SharedObjects *SharedObjects
+
+	// FileSystem is the file system read by Open, its root is the CWD: the file
+	// CWD/dir/name is dir/name in it. The OS file system under CWD is used when
+	// it's nil.
+	FileSystem fs.FS
 }
 
 // GetAbsFilePath should be used to access the FileSystems, since afero has a
@@ -86,6 +96,42 @@ func (ie *InitEnvironment) GetAbsFilePath(filename string) string {
 	return filename
 }
 
+// ResolvePath returns the name of filename in FileSystem. A relative filename
+// is resolved against the CWD and an absolute one must be inside of it, the
+// filenames ending up outside of the CWD, with '..' for example, are rejected.
+func (ie *InitEnvironment) ResolvePath(filename string) (string, error) {
+	if ie.CWD == nil {
+		return "", errors.New("missing the working directory of the init environment")
+	}
+	if filename == "" {
+		return "", errors.New("empty filename")
+	}
+
+	rel, err := filepath.Rel(filepath.Clean(ie.CWD.Path), ie.GetAbsFilePath(filename))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file '%s' is outside of the root '%s'", filename, ie.CWD.Path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Open opens filename for reading from FileSystem, following the rules of
+// ResolvePath. The modules reading files should use it rather than the os
+// package, so they are sandboxed and can be tested with an in-memory file
+// system. The symbolic links of the OS file system aren't resolved, so they
+// must not point outside of the CWD.
+func (ie *InitEnvironment) Open(filename string) (fs.File, error) {
+	name, err := ie.ResolvePath(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := ie.FileSystem
+	if fsys == nil {
+		fsys = os.DirFS(filepath.FromSlash(ie.CWD.Path))
+	}
+	return fsys.Open(name)
+}
+
 // SharedObjects is a collection of general store for objects to be shared. It is mostly a wrapper
 // around map[string]interface with a lock and stuff.
 // The reason behind not just using sync.Map is that it still needs a lock when we want to only call
@@ -116,3 +162,20 @@ func (so *SharedObjects) GetOrCreateShare(name string, createCallback func() int
 
 	return value
 }
+
+// TryGetOrCreateShare is GetOrCreateShare with a createCallback which can fail,
+// its error is returned and nothing is stored, so the next call tries again.
+func (so *SharedObjects) TryGetOrCreateShare(name string, createCallback func() (interface{}, error)) (interface{}, error) {
+	so.l.Lock()
+	defer so.l.Unlock()
+
+	if value, ok := so.data[name]; ok {
+		return value, nil
+	}
+	value, err := createCallback()
+	if err != nil {
+		return nil, err
+	}
+	so.data[name] = value
+	return value, nil
+}
@@ -0,0 +1,100 @@
+package gojs
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInitEnvironmentOpen(t *testing.T) {
+	initEnv := &InitEnvironment{
+		CWD: &url.URL{Path: "/path/to"},
+		FileSystem: fstest.MapFS{
+			"a.txt":     &fstest.MapFile{Data: []byte("a")},
+			"dir/b.txt": &fstest.MapFile{Data: []byte("b")},
+		},
+	}
+
+	for filename, want := range map[string]string{
+		"a.txt":              "a",
+		"./dir/b.txt":        "b",
+		"dir/../a.txt":       "a",
+		"/path/to/dir/b.txt": "b",
+	} {
+		f, err := initEnv.Open(filename)
+		if err != nil {
+			t.Fatal(filename, err)
+		}
+		bs, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(filename, err)
+		}
+		if string(bs) != want {
+			t.Fatal(filename, string(bs))
+		}
+	}
+
+	for _, filename := range []string{"../a.txt", "/path/a.txt", "dir/../../to/a.txt", ""} {
+		if _, err := initEnv.Open(filename); err == nil {
+			t.Fatal(filename, "must be rejected")
+		} else if filename != "" && !strings.Contains(err.Error(), "is outside of the root") {
+			t.Fatal(filename, err)
+		}
+	}
+
+	if _, err := initEnv.Open("missing.txt"); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestInitEnvironmentOpenOS(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	initEnv := &InitEnvironment{CWD: &url.URL{Path: filepath.ToSlash(dir)}}
+	f, err := initEnv.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bs, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "a" {
+		t.Fatal(string(bs))
+	}
+}
+
+func TestTryGetOrCreateShare(t *testing.T) {
+	so := NewSharedObjects()
+	calls := 0
+	create := func(fail bool) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			calls++
+			if fail {
+				return nil, errors.New("failed")
+			}
+			return calls, nil
+		}
+	}
+
+	if _, err := so.TryGetOrCreateShare("a", create(true)); err == nil || err.Error() != "failed" {
+		t.Fatal(err)
+	}
+	// The failures aren't stored.
+	if v, err := so.TryGetOrCreateShare("a", create(false)); err != nil || v != 2 {
+		t.Fatal(v, err)
+	}
+	if v, err := so.TryGetOrCreateShare("a", create(true)); err != nil || v != 2 || calls != 2 {
+		t.Fatal(v, err, calls)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package compiler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/runner-mei/gojs/lib/consts"
+)
+
+// cacheFormatVersion is bumped whenever the format of the cache or the output
+// of Babel changes.
+const cacheFormatVersion = 1
+
+// ErrStaleCache is returned by LoadCache when the cache was written by another version.
+var ErrStaleCache = errors.New("the compiler cache was written by another version")
+
+// CacheVersion returns the version stamp of the caches written by this version.
+func CacheVersion() string {
+	return fmt.Sprintf("%d/%s", cacheFormatVersion, consts.Version)
+}
+
+// Cache holds the sources transformed by Babel, keyed by the hash of the original
+// source, so a Compiler using it only transforms a source once. goja can't serialize
+// compiled programs, but parsing the transformed sources is cheap compared to Babel.
+//
+// The cache can be saved with Bytes and loaded with LoadCache, e.g. to share it
+// between processes:
+//
+//	c := compiler.New()
+//	c.Cache, err = compiler.LoadCache(data)
+//	if err != nil { // stale or corrupted, start again.
+//		c.Cache = compiler.NewCache()
+//	}
+//	...
+//	data, err = c.Cache.Bytes()
+type Cache struct {
+	mutex   sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	Code string     `json:"code"`
+	Map  *SourceMap `json:"map,omitempty"`
+}
+
+type cacheFile struct {
+	Version string                `json:"version"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{entries: map[string]cacheEntry{}}
+}
+
+// LoadCache loads a cache saved with Bytes, it returns ErrStaleCache when the cache
+// was saved by another version.
+func LoadCache(data []byte) (*Cache, error) {
+	var f cacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid compiler cache: %w", err)
+	}
+	if f.Version != CacheVersion() {
+		return nil, ErrStaleCache
+	}
+	if f.Entries == nil {
+		f.Entries = map[string]cacheEntry{}
+	}
+	return &Cache{entries: f.Entries}, nil
+}
+
+// Bytes serializes the cache with its version stamp.
+func (c *Cache) Bytes() ([]byte, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return json.Marshal(cacheFile{Version: CacheVersion(), Entries: c.entries})
+}
+
+// Len returns the number of cached sources.
+func (c *Cache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.entries)
+}
+
+func (c *Cache) get(src string) (cacheEntry, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	e, ok := c.entries[cacheKey(src)]
+	return e, ok
+}
+
+func (c *Cache) put(src string, e cacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[cacheKey(src)] = e
+}
+
+func cacheKey(src string) string {
+	sum := sha256.Sum256([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,75 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package compiler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	c := New()
+	c.Cache = NewCache()
+	_, code, err := c.Compile(`1+(()=>2)()`, "script.js", "", "", true, CompatibilityModeExtended)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Cache.Len())
+
+	data, err := c.Cache.Bytes()
+	require.NoError(t, err)
+
+	t.Run("Load", func(t *testing.T) {
+		cache, err := LoadCache(data)
+		require.NoError(t, err)
+		assert.Equal(t, 1, cache.Len())
+		e, ok := cache.get(`1+(()=>2)()`)
+		require.True(t, ok)
+		assert.Equal(t, code, e.Code)
+	})
+
+	t.Run("SkipsBabel", func(t *testing.T) {
+		c := New()
+		c.Cache = NewCache()
+		c.Cache.put(`(()=>2)()`, cacheEntry{Code: `3`})
+		pgm, code, err := c.Compile(`(()=>2)()`, "script.js", "", "", true, CompatibilityModeExtended)
+		require.NoError(t, err)
+		assert.Equal(t, "3", code)
+		v, err := goja.New().RunProgram(pgm)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), v.Export())
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		var f cacheFile
+		require.NoError(t, json.Unmarshal(data, &f))
+		f.Version = "0/0.0.0"
+		stale, err := json.Marshal(f)
+		require.NoError(t, err)
+		_, err = LoadCache(stale)
+		assert.Equal(t, ErrStaleCache, err)
+
+		_, err = LoadCache([]byte("{"))
+		assert.Error(t, err)
+	})
+}
@@ -25,6 +25,7 @@ package compiler
 import (
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -63,6 +64,27 @@ func ValidateCompatibilityMode(val string) (cm CompatibilityMode, err error) {
 	return
 }
 
+// es6Syntax matches the ES6+ syntax the base mode doesn't parse, see
+// DetectCompatibilityMode.
+var es6Syntax = regexp.MustCompile("=>|`|\\.\\.\\.|\\b(let|const|class|import|export|async|await)\\b") // nolint:gochecknoglobals
+
+// DetectCompatibilityMode returns the compatibility mode src should be compiled
+// in. It's a heuristic: the extended mode is returned when src can't be parsed
+// in the base mode and it looks like ES6+, e.g. it has arrow functions, let or
+// const, template literals or modules. The base mode is returned otherwise,
+// including for the sources which are plainly invalid, so they fail with the
+// error of the base parser. The ES6+ syntax in the comments or the strings of an
+// invalid source is enough to recommend the extended mode.
+func DetectCompatibilityMode(src string) CompatibilityMode {
+	if _, err := parser.ParseFile(nil, "", src, 0, parser.WithDisableSourceMaps); err == nil {
+		return CompatibilityModeBase
+	}
+	if es6Syntax.MatchString(src) {
+		return CompatibilityModeExtended
+	}
+	return CompatibilityModeBase
+}
+
 var (
 	DefaultOpts = map[string]interface{}{
 		"presets":       []string{"latest"},
@@ -79,7 +101,10 @@ var (
 )
 
 // A Compiler compiles JavaScript source code (ES5.1 or ES6) into a goja.Program
-type Compiler struct{}
+type Compiler struct {
+	// Cache, when set, keeps the sources transformed by Babel.
+	Cache *Cache
+}
 
 // New returns a new Compiler
 func New() *Compiler {
@@ -88,15 +113,27 @@ func New() *Compiler {
 
 // Transform the given code into ES5
 func (c *Compiler) Transform(src, filename string) (code string, srcmap *SourceMap, err error) {
+	if c.Cache != nil {
+		if e, ok := c.Cache.get(src); ok {
+			return e.Code, e.Map, nil
+		}
+	}
+
 	var b *babel
 	if b, err = newBabel(); err != nil {
 		return
 	}
 
-	return b.Transform(src, filename)
+	code, srcmap, err = b.Transform(src, filename)
+	if err == nil && c.Cache != nil {
+		c.Cache.put(src, cacheEntry{Code: code, Map: srcmap})
+	}
+	return code, srcmap, err
 }
 
-// Compile the program in the given CompatibilityMode, wrapping it between pre and post code
+// Compile the program in the given CompatibilityMode, wrapping it between pre and post code.
+// The returned string is the code goja compiled, i.e. the wrapped source, transformed
+// by Babel in the extended mode when goja can't parse the original one.
 func (c *Compiler) Compile(src, filename, pre, post string,
 	strict bool, compatMode CompatibilityMode) (*goja.Program, string, error) {
 	code := pre + src + post
@@ -110,10 +147,27 @@ func (c *Compiler) Compile(src, filename, pre, post string,
 			// the compatibility mode "decreases" here as we shouldn't transform twice
 			return c.Compile(code, filename, pre, post, strict, CompatibilityModeBase)
 		}
-		return nil, code, err
+		return nil, code, compileError(err, filename, pre)
 	}
 	pgm, err := goja.CompileAST(ast, strict)
-	return pgm, code, err
+	if err != nil {
+		return nil, code, compileError(err, filename, pre)
+	}
+	return pgm, code, nil
+}
+
+// Validate compiles src in the given CompatibilityMode and discards the program, it
+// returns nil when src compiles, else the *CompileError of the failure. It needs no
+// runtime, e.g. to check the scripts in a CI pipeline without running them.
+func Validate(src, filename string, compatMode CompatibilityMode) error {
+	_, _, err := New().Compile(src, filename, "", "", false, compatMode)
+	return err
+}
+
+func compileError(err error, filename, pre string) error {
+	ce := newGojaCompileError(err, filename)
+	ce.shift(pre)
+	return ce
 }
 
 type babel struct {
@@ -161,7 +215,7 @@ func (b *babel) Transform(src, filename string) (string, *SourceMap, error) {
 	startTime := time.Now()
 	v, err := b.transform(b.this, b.vm.ToValue(src), b.vm.ToValue(opts))
 	if err != nil {
-		return "", nil, err
+		return "", nil, newBabelCompileError(b.vm, err, filename)
 	}
 	log.Println("Babel: Transformed", time.Since(startTime))
 
@@ -20,6 +20,7 @@
 package compiler
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -106,9 +107,17 @@ func TestCompile(t *testing.T) {
 		t.Run("Invalid", func(t *testing.T) {
 			src := `1+(function() { return 2; )()`
 			_, _, err := c.Compile(src, "script.js", "", "", true, CompatibilityModeExtended)
-			assert.IsType(t, &goja.Exception{}, err)
+			var exc *goja.Exception
+			assert.True(t, errors.As(err, &exc))
 			assert.Contains(t, err.Error(), `SyntaxError: script.js: Unexpected token (1:26)
 > 1 | 1+(function() { return 2; )()`)
+			var ce *CompileError
+			if assert.True(t, errors.As(err, &ce)) {
+				assert.Equal(t, "script.js", ce.Filename)
+				assert.Equal(t, 1, ce.Line)
+				assert.Equal(t, 27, ce.Column)
+				assert.Equal(t, "Unexpected token", ce.Message)
+			}
 		})
 	})
 	t.Run("ES6", func(t *testing.T) {
@@ -145,7 +154,8 @@ func TestCompile(t *testing.T) {
 
 		t.Run("Invalid", func(t *testing.T) {
 			_, _, err := c.Compile(`1+(=>2)()`, "script.js", "", "", true, CompatibilityModeExtended)
-			assert.IsType(t, &goja.Exception{}, err)
+			var exc *goja.Exception
+			assert.True(t, errors.As(err, &exc))
 			assert.Contains(t, err.Error(), `SyntaxError: script.js: Unexpected token (1:3)
 > 1 | 1+(=>2)()`)
 		})
@@ -159,7 +169,8 @@ func TestCompile(t *testing.T) {
 				// the important part is that goja won't parse it but babel will transform it but still
 				// goja won't be able to parse the result it is actually "\<U+2029>"
 				_, _, err := c.Compile(string([]byte{0x22, 0x5c, 0xe2, 0x80, 0xa9, 0x22}), "script.js", "", "", true, CompatibilityModeExtended)
-				assert.IsType(t, parser.ErrorList{}, err)
+				var errs parser.ErrorList
+				assert.True(t, errors.As(err, &errs))
 				assert.Contains(t, err.Error(), ` Unexpected token ILLEGAL`)
 			}()
 
@@ -173,3 +184,74 @@ func TestCompile(t *testing.T) {
 		})
 	})
 }
+
+func TestCompileWrappedErrorPosition(t *testing.T) {
+	_, _, err := New().Compile("fn(1);\nvar b = ;", "script.js", "(function(fn){\n  ", "\n})", true, CompatibilityModeBase)
+	var ce *CompileError
+	if assert.True(t, errors.As(err, &ce)) {
+		assert.Equal(t, 2, ce.Line)
+		assert.Equal(t, 9, ce.Column)
+	}
+}
+
+func TestDetectCompatibilityMode(t *testing.T) {
+	for src, want := range map[string]CompatibilityMode{
+		`var a = 1; function f() { return a + 1; }`:     CompatibilityModeBase,
+		`1+(function() { return 2; })()`:                CompatibilityModeBase,
+		`var a = ;`:                                     CompatibilityModeBase,
+		`1+(()=>2)()`:                                   CompatibilityModeExtended,
+		"var s = `a ${1 + 2}`;":                         CompatibilityModeExtended,
+		`let a = 1; const b = 2;`:                       CompatibilityModeExtended,
+		`import http from "k6/http"; export default 1;`: CompatibilityModeExtended,
+		`function f(...args) { return args; }`:          CompatibilityModeExtended,
+	} {
+		assert.Equal(t, want, DetectCompatibilityMode(src), src)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(`var a = [1, 2].map(function(v) { return v * 2; });`, "es5.js", CompatibilityModeBase))
+	assert.NoError(t, Validate("class A { get b() { return `${1 + 2}`; } }\nlet f = (...args) => new A().b;",
+		"es6.js", CompatibilityModeExtended))
+
+	for _, mode := range []CompatibilityMode{CompatibilityModeBase, CompatibilityModeExtended} {
+		err := Validate("var a = 1;\nvar b = ;", "invalid.js", mode)
+		var ce *CompileError
+		if assert.True(t, errors.As(err, &ce), mode.String()) {
+			assert.Equal(t, "invalid.js", ce.Filename)
+			assert.Equal(t, 2, ce.Line)
+		}
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	c := New()
+	t.Run("Parse", func(t *testing.T) {
+		_, _, err := c.Compile("var a = 1;\nvar b = ;", "script.js", "", "", true, CompatibilityModeBase)
+		var errs parser.ErrorList
+		assert.True(t, errors.As(err, &errs))
+		var ce *CompileError
+		if assert.True(t, errors.As(err, &ce)) {
+			assert.Equal(t, "script.js", ce.Filename)
+			assert.Equal(t, 2, ce.Line)
+			assert.Equal(t, 9, ce.Column)
+			assert.Contains(t, ce.Message, "Unexpected token")
+		}
+	})
+	t.Run("Wrapped", func(t *testing.T) {
+		_, _, err := c.Compile("var b = ;", "script.js", "(function(){", "})", true, CompatibilityModeBase)
+		var ce *CompileError
+		if assert.True(t, errors.As(err, &ce)) {
+			assert.Equal(t, 1, ce.Line)
+			assert.Equal(t, 9, ce.Column)
+		}
+	})
+	t.Run("Compile", func(t *testing.T) {
+		_, _, err := c.Compile("'use strict';\nvar eval = 1;", "script.js", "", "", false, CompatibilityModeBase)
+		var ce *CompileError
+		if assert.True(t, errors.As(err, &ce)) {
+			assert.Equal(t, 2, ce.Line)
+			assert.NotZero(t, ce.Column)
+		}
+	})
+}
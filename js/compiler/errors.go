@@ -0,0 +1,115 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package compiler
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+)
+
+// CompileError is returned by Compile and Transform when the source can't be compiled,
+// it tells where the error is in the source, whether it was found by Babel or by goja.
+// The original error is kept, so errors.As still works with the goja and parser errors.
+type CompileError struct {
+	Filename string
+	// Line and Column are 1-based, and relative to the source without the pre and post code
+	// of Compile. They are 0 when the position is unknown.
+	Line   int
+	Column int
+	// Message is the message of the error, without the position.
+	Message string
+	Err     error
+}
+
+func (e *CompileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// newGojaCompileError converts the errors of goja's parser and compiler, which have
+// 1-based columns.
+func newGojaCompileError(err error, filename string) *CompileError {
+	ce := &CompileError{Filename: filename, Message: err.Error(), Err: err}
+	switch e := err.(type) {
+	case parser.ErrorList:
+		if len(e) > 0 {
+			ce.Line, ce.Column, ce.Message = e[0].Position.Line, e[0].Position.Column, e[0].Message
+		}
+	case *parser.Error:
+		ce.Line, ce.Column, ce.Message = e.Position.Line, e.Position.Column, e.Message
+	case *goja.CompilerSyntaxError:
+		ce.Message = e.Message
+		if e.File != nil {
+			p := e.File.Position(e.Offset)
+			ce.Line, ce.Column = p.Line, p.Column
+		}
+	}
+	return ce
+}
+
+var babelPositionSuffix = regexp.MustCompile(` \(\d+:\d+\)$`)
+
+// newBabelCompileError converts the exceptions of Babel, which have 0-based columns
+// in the loc property, and the position in the message.
+func newBabelCompileError(vm *goja.Runtime, err error, filename string) *CompileError {
+	ce := &CompileError{Filename: filename, Message: err.Error(), Err: err}
+	exc, ok := err.(*goja.Exception)
+	if !ok {
+		return ce
+	}
+	obj, ok := exc.Value().(*goja.Object)
+	if !ok {
+		return ce
+	}
+	if loc, ok := obj.Get("loc").(*goja.Object); ok {
+		ce.Line = int(loc.Get("line").ToInteger())
+		ce.Column = int(loc.Get("column").ToInteger()) + 1
+	}
+	if msg := obj.Get("message"); msg != nil && !goja.IsUndefined(msg) {
+		s := strings.SplitN(msg.String(), "\n", 2)[0]
+		s = strings.TrimPrefix(s, filename+": ")
+		ce.Message = babelPositionSuffix.ReplaceAllString(s, "")
+	}
+	return ce
+}
+
+// shift moves the position of the error from the code wrapped between pre and the post
+// code to the source.
+func (e *CompileError) shift(pre string) {
+	if e.Line == 0 || pre == "" {
+		return
+	}
+	lines := strings.Count(pre, "\n")
+	if e.Line == lines+1 {
+		e.Column -= len(pre) - strings.LastIndex(pre, "\n") - 1
+	}
+	e.Line -= lines
+	if e.Line < 1 || e.Column < 1 {
+		// The error is in pre.
+		e.Line, e.Column = 0, 0
+	}
+}
@@ -283,6 +283,10 @@ func (ctx *tc39TestCtx) runTC39Test(t testing.TB, name, src string, meta *tc39Me
 	}
 	var errType string
 
+	var compileErr *compiler.CompileError
+	if errors.As(err, &compileErr) {
+		err = compileErr.Err
+	}
 	switch err := err.(type) {
 	case *goja.Exception:
 		if o, ok := err.Value().(*goja.Object); ok { //nolint:nestif
@@ -32,10 +32,15 @@ var (
 	ctxKeyState = &ctxKey{}
 )
 
+// WithState attaches the VU state to the context. The state is only attached once the
+// VU runs its iterations, so the modules tell the init context from it, e.g. the
+// metrics and SharedArray can only be created without a state.
 func WithState(ctx context.Context, state *State) context.Context {
 	return context.WithValue(ctx, ctxKeyState, state)
 }
 
+// GetState retrieves the attached VU state from the given context, or nil in the
+// init context.
 func GetState(ctx context.Context) *State {
 	v := ctx.Value(ctxKeyState)
 	if v == nil {
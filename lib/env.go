@@ -0,0 +1,34 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import "github.com/runner-mei/gojs/lib/types"
+
+// OptionsFromEnv returns the options set by the K6_* environment variables named by the
+// `envconfig` tags of Options, see types.LoadEnv for how the values are decoded.
+// getenv is usually os.Getenv.
+func OptionsFromEnv(getenv func(string) string) (Options, error) {
+	var opts Options
+	if err := types.LoadEnv(&opts, getenv); err != nil {
+		return Options{}, err
+	}
+	return opts, nil
+}
@@ -0,0 +1,152 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+
+	uuid "github.com/nu7hatch/gouuid"
+	"github.com/runner-mei/log"
+)
+
+// DefaultHTTPDebugBodyLimit is the default of Options.HTTPDebugBodyLimit.
+const DefaultHTTPDebugBodyLimit = 64 * 1024
+
+// httpDebugLoggedKey marks the context of the requests which were already logged
+// by a httpDebugTransport, so the inner ones let them through.
+type httpDebugLoggedKey struct{}
+
+// NewHTTPDebugTransport wraps rt in a transport logging the requests and the
+// responses with logger when opts.HTTPDebug is "headers" or "full", it returns
+// rt otherwise. A request is only logged by the outermost of the debug transports
+// it goes through, so MakeRequest can log its requests with their tags while the
+// transport of NewState still logs the requests made directly with it.
+func NewHTTPDebugTransport(rt http.RoundTripper, opts Options, logger log.Logger) http.RoundTripper {
+	debug := opts.HTTPDebug.String
+	if debug != "headers" && debug != "full" {
+		return rt
+	}
+	bodyLimit := int64(DefaultHTTPDebugBodyLimit)
+	if opts.HTTPDebugBodyLimit.Valid {
+		bodyLimit = opts.HTTPDebugBodyLimit.Int64
+	}
+	return httpDebugTransport{
+		originalTransport: rt,
+		httpDebugOption:   debug,
+		bodyLimit:         bodyLimit,
+		logger:            logger,
+	}
+}
+
+type httpDebugTransport struct {
+	originalTransport http.RoundTripper
+	httpDebugOption   string
+	bodyLimit         int64
+	logger            log.Logger
+}
+
+// RoundTrip prints passing HTTP requests and received responses
+//
+// TODO: massively improve this, because the printed information can be wrong:
+//   - https://github.com/loadimpact/k6/issues/986
+//   - https://github.com/loadimpact/k6/issues/1042
+//   - https://github.com/loadimpact/k6/issues/774
+func (t httpDebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(httpDebugLoggedKey{}) != nil {
+		return t.originalTransport.RoundTrip(req)
+	}
+	// A RoundTripper mustn't modify the request, so the dump and the capture of
+	// the body work on a clone, which is the one passed to the original transport.
+	req = req.Clone(context.WithValue(req.Context(), httpDebugLoggedKey{}, true))
+
+	id, _ := uuid.NewV4()
+	t.debugRequest(req, id.String())
+	resp, err := t.originalTransport.RoundTrip(req)
+	t.debugResponse(resp, id.String())
+	return resp, err
+}
+
+func (t httpDebugTransport) debugRequest(req *http.Request, requestID string) {
+	dump, err := httputil.DumpRequestOut(req, false)
+	if err != nil {
+		t.logger.Error("dump request fail", log.Error(err))
+	}
+	if t.httpDebugOption == "full" {
+		body, err := t.captureBody(req)
+		if err != nil {
+			t.logger.Error("dump request body fail", log.Error(err))
+		}
+		dump = append(dump, body...)
+	}
+	t.logger.Info("Request:\n%s\n",
+		log.Stringer("body", log.StringerFunc(func() string {
+			return string(bytes.ReplaceAll(dump, []byte("\r\n"), []byte{'\n'}))
+		})),
+		log.String("request_id", requestID),
+	)
+}
+
+// captureBody returns the first bodyLimit bytes of the body of req for the dump,
+// followed by a notice when the body is longer. The captured bytes are put back in
+// front of the rest of the body, which isn't read, so the transport still sends the
+// whole body, whether it can be read again or not. It replaces req.Body, so req must
+// be the clone made by RoundTrip and not the request of the caller.
+func (t httpDebugTransport) captureBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(req.Body, t.bodyLimit+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(buf)) > t.bodyLimit {
+		buf = append(buf[:t.bodyLimit:t.bodyLimit], fmt.Sprintf(
+			"\n[body truncated to %d bytes, see the httpDebugBodyLimit option]", t.bodyLimit)...)
+	}
+	return buf, nil
+}
+
+func (t httpDebugTransport) debugResponse(res *http.Response, requestID string) {
+	if res != nil {
+		dump, err := httputil.DumpResponse(res, t.httpDebugOption == "full")
+		if err != nil {
+			t.logger.Error("dump response fail", log.Error(err))
+		}
+
+		t.logger.Info("Response:\n%s\n",
+			log.Stringer("body", log.StringerFunc(func() string {
+				return string(bytes.ReplaceAll(dump, []byte("\r\n"), []byte{'\n'}))
+			})),
+			log.String("request_id", requestID),
+		)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/runner-mei/gojs/stats"
+)
+
+// DefaultJSONLinesFlushInterval is the default of JSONLinesOutput.FlushInterval.
+const DefaultJSONLinesFlushInterval = time.Second
+
+// JSONLinesOutput writes the samples pushed to a samples channel, e.g. State.Samples,
+// as JSON lines, one line per sample:
+//
+//	{"metric":"http_reqs","time":"2020-01-01T00:00:00Z","value":1,"tags":{"method":"GET"}}
+type JSONLinesOutput struct {
+	// FlushInterval is how often the buffered lines are written, they are also
+	// written when Run returns. DefaultJSONLinesFlushInterval is used when it's
+	// not positive.
+	FlushInterval time.Duration
+
+	w *bufio.Writer
+}
+
+type jsonLinesSample struct {
+	Metric string            `json:"metric"`
+	Time   time.Time         `json:"time"`
+	Value  float64           `json:"value"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// NewJSONLinesOutput returns a JSONLinesOutput writing to w.
+func NewJSONLinesOutput(w io.Writer) *JSONLinesOutput {
+	return &JSONLinesOutput{
+		FlushInterval: DefaultJSONLinesFlushInterval,
+		w:             bufio.NewWriter(w),
+	}
+}
+
+// Run writes the samples received from samples until the channel is closed or ctx
+// is done, whichever comes first, it returns the first write error. It's not
+// meant to be called concurrently.
+func (o *JSONLinesOutput) Run(ctx context.Context, samples <-chan stats.SampleContainer) error {
+	flushInterval := o.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = DefaultJSONLinesFlushInterval
+	}
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	enc := json.NewEncoder(o.w)
+	for {
+		select {
+		case container, ok := <-samples:
+			if !ok {
+				return o.w.Flush()
+			}
+			for _, sample := range container.GetSamples() {
+				line := jsonLinesSample{Time: sample.Time, Value: sample.Value}
+				if sample.Metric != nil {
+					line.Metric = sample.Metric.Name
+				}
+				if sample.Tags != nil {
+					line.Tags = sample.Tags.CloneTags()
+				}
+				if err := enc.Encode(line); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := o.w.Flush(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return o.w.Flush()
+		}
+	}
+}
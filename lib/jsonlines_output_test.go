@@ -0,0 +1,85 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runner-mei/gojs/lib/metrics"
+	"github.com/runner-mei/gojs/stats"
+)
+
+func TestJSONLinesOutput(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tags := stats.NewSampleTags(map[string]string{"method": "GET"})
+
+	t.Run("Closed", func(t *testing.T) {
+		var buf bytes.Buffer
+		samples := make(chan stats.SampleContainer, 2)
+		samples <- stats.Samples{
+			{Metric: metrics.HTTPReqs, Time: now, Value: 1, Tags: tags},
+			{Metric: metrics.DataSent, Time: now, Value: 512},
+		}
+		samples <- stats.Sample{Metric: metrics.Checks, Time: now, Value: 0, Tags: tags}
+		close(samples)
+
+		output := NewJSONLinesOutput(&buf)
+		output.FlushInterval = 0 // the default is used instead
+		require.NoError(t, output.Run(context.Background(), samples))
+
+		var lines []jsonLinesSample
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var line jsonLinesSample
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+		require.Len(t, lines, 3)
+		assert.Equal(t, jsonLinesSample{Metric: "http_reqs", Time: now, Value: 1, Tags: map[string]string{"method": "GET"}}, lines[0])
+		assert.Equal(t, jsonLinesSample{Metric: "data_sent", Time: now, Value: 512}, lines[1])
+		assert.Equal(t, "checks", lines[2].Metric)
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		var buf bytes.Buffer
+		samples := make(chan stats.SampleContainer, 1)
+		samples <- stats.Sample{Metric: metrics.HTTPReqs, Time: now, Value: 1}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- NewJSONLinesOutput(&buf).Run(ctx, samples)
+		}()
+		for len(samples) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+		require.NoError(t, <-done)
+		assert.Contains(t, buf.String(), `"metric":"http_reqs"`)
+	})
+}
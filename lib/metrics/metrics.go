@@ -59,4 +59,10 @@ var (
 	// Network-related; used for future protocols as well.
 	DataSent     = stats.New("data_sent", stats.Counter, stats.Data)
 	DataReceived = stats.New("data_received", stats.Counter, stats.Data)
+
+	ConnectionsOpened = stats.New("connections_opened", stats.Counter)
+	ConnectionMaxAge  = stats.New("connection_max_age", stats.Gauge, stats.Time)
+	// BlockedDials counts the dials denied by the blacklist, the blocked hostnames or
+	// the blocked ports, tagged with the reason and the target.
+	BlockedDials = stats.New("blocked_dials", stats.Counter)
 )
@@ -0,0 +1,100 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"net"
+)
+
+// ipNetTrie is a binary trie of IP ranges, it finds the range containing an IP
+// in O(prefix length), whatever the number of ranges is.
+type ipNetTrie struct {
+	v4, v6 *ipNetTrieNode
+	others []*IPNet // ranges with an unusual mask, which are checked one by one
+}
+
+type ipNetTrieNode struct {
+	children [2]*ipNetTrieNode
+	ipnet    *IPNet
+}
+
+func newIPNetTrie(ipnets []*IPNet) *ipNetTrie {
+	t := &ipNetTrie{v4: &ipNetTrieNode{}, v6: &ipNetTrieNode{}}
+	for _, ipnet := range ipnets {
+		t.insert(ipnet)
+	}
+	return t
+}
+
+func (t *ipNetTrie) root(ip net.IP) (*ipNetTrieNode, net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return t.v4, ip4
+	}
+	return t.v6, ip.To16()
+}
+
+func (t *ipNetTrie) insert(ipnet *IPNet) {
+	ones, bits := ipnet.Mask.Size()
+	node, ip := t.root(ipnet.IP)
+	if bits == 8*net.IPv6len && len(ip) == net.IPv4len {
+		ones -= 8 * (net.IPv6len - net.IPv4len) // an IPv4-mapped IPv6 range
+		bits = 8 * net.IPv4len
+	}
+	if bits != 8*len(ip) || ones < 0 { // a non canonical mask
+		t.others = append(t.others, ipnet)
+		return
+	}
+
+	for i := 0; i < ones; i++ {
+		bit := ip[i/8] >> (7 - uint(i%8)) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &ipNetTrieNode{}
+		}
+		node = node.children[bit]
+	}
+	if node.ipnet == nil {
+		node.ipnet = ipnet
+	}
+}
+
+// contains returns the widest range containing the ip, if there is one.
+func (t *ipNetTrie) contains(ip net.IP) (*IPNet, bool) {
+	for _, ipnet := range t.others {
+		if ipnet.Contains(ip) {
+			return ipnet, true
+		}
+	}
+
+	node, ip := t.root(ip)
+	if ip == nil {
+		return nil, false
+	}
+	for i := 0; node != nil; i++ {
+		if node.ipnet != nil {
+			return node.ipnet, true
+		}
+		if i == len(ip)*8 {
+			break
+		}
+		node = node.children[ip[i/8]>>(7-uint(i%8))&1]
+	}
+	return nil, false
+}
@@ -0,0 +1,102 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPNetTrie(t *testing.T) {
+	var ipnets []*IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "10.1.0.0/16", "192.168.1.1/32", "fc00::/7", "2001:db8::68/128", "::ffff:172.16.0.0/108"} {
+		ipnet, err := ParseCIDR(cidr)
+		require.NoError(t, err)
+		ipnets = append(ipnets, ipnet)
+	}
+	trie := newIPNetTrie(ipnets)
+
+	testCases := []struct {
+		ip, match string
+	}{
+		{"10.1.2.3", "10.0.0.0/8"},
+		{"10.255.255.255", "10.0.0.0/8"},
+		{"11.0.0.0", ""},
+		{"192.168.1.1", "192.168.1.1/32"},
+		{"192.168.1.2", ""},
+		{"::ffff:10.0.0.1", "10.0.0.0/8"},
+		{"172.16.5.4", "172.16.0.0/12"},
+		{"172.32.0.1", ""},
+		{"fd00::1", "fc00::/7"},
+		{"2001:db8::68", "2001:db8::68/128"},
+		{"2001:db8::69", ""},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.ip, func(t *testing.T) {
+			ipnet, ok := trie.contains(net.ParseIP(tc.ip))
+			if tc.match == "" {
+				assert.False(t, ok)
+				return
+			}
+			if assert.True(t, ok) {
+				assert.Equal(t, tc.match, ipnet.String())
+			}
+		})
+	}
+}
+
+func TestDialerBlacklistChanges(t *testing.T) {
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	ipNet, err := ParseCIDR("1.2.3.0/24")
+	require.NoError(t, err)
+
+	_, err = dialer.ResolveAddr("1.2.3.4:80")
+	require.NoError(t, err)
+
+	dialer.Blacklist = []*IPNet{ipNet}
+	_, err = dialer.ResolveAddr("1.2.3.4:80")
+	require.EqualError(t, err, "IP (1.2.3.4) is in a blacklisted range (1.2.3.0/24)")
+
+	dialer.Blacklist = nil
+	_, err = dialer.ResolveAddr("1.2.3.4:80")
+	require.NoError(t, err)
+}
+
+func BenchmarkDialerBlacklist(b *testing.B) {
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	for i := 0; i < 5000; i++ {
+		ipnet, err := ParseCIDR(fmt.Sprintf("%d.%d.%d.0/24", 10+i/65536, (i/256)%256, i%256))
+		require.NoError(b, err)
+		dialer.Blacklist = append(dialer.Blacklist, ipnet)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := dialer.ResolveAddr("1.2.3.4:80"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -23,9 +23,12 @@ package netext
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net"
 	"strconv"
+	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
@@ -51,6 +54,14 @@ func (ipnet *IPNet) UnmarshalText(b []byte) error {
 	return nil
 }
 
+// MarshalText returns the CIDR notation of the IPNet, e.g. "192.168.0.0/24"
+func (ipnet *IPNet) MarshalText() ([]byte, error) {
+	if ipnet == nil {
+		return []byte(""), nil
+	}
+	return []byte(ipnet.String()), nil
+}
+
 // ParseCIDR creates an IPNet out of a CIDR string
 func ParseCIDR(s string) (*IPNet, error) {
 	_, ipnet, err := net.ParseCIDR(s)
@@ -110,7 +121,7 @@ func (h *HostAddress) UnmarshalText(text []byte) error {
 		return &net.ParseError{Type: "IP address", Text: "<nil>"}
 	}
 
-	ip, port, err := splitHostPort(text)
+	ip, zone, port, err := splitHostPort(text)
 	if err != nil {
 		return err
 	}
@@ -119,13 +130,14 @@ func (h *HostAddress) UnmarshalText(text []byte) error {
 	if err != nil {
 		return err
 	}
+	nh.Zone = zone
 
 	*h = *nh
 
 	return nil
 }
 
-func splitHostPort(text []byte) (net.IP, string, error) {
+func splitHostPort(text []byte) (net.IP, string, string, error) {
 	host, port, err := net.SplitHostPort(string(text))
 	if err != nil {
 		// This error means that there is no port.
@@ -133,12 +145,18 @@ func splitHostPort(text []byte) (net.IP, string, error) {
 		host = string(text)
 	}
 
+	// net.ParseIP doesn't accept the zone of the IPv6 addresses, e.g. fe80::1%eth0
+	var zone string
+	if i := strings.LastIndexByte(host, '%'); i > 0 {
+		host, zone = host[:i], host[i+1:]
+	}
+
 	ip := net.ParseIP(host)
-	if ip == nil {
-		return nil, "", &net.ParseError{Type: "IP address", Text: host}
+	if ip == nil || (zone != "" && ip.To4() != nil) {
+		return nil, "", "", &net.ParseError{Type: "IP address", Text: string(text)}
 	}
 
-	return ip, port, nil
+	return ip, zone, port, nil
 }
 
 // Dialer wraps net.Dialer and provides k6 specific functionality -
@@ -149,10 +167,29 @@ type Dialer struct {
 	Resolver         Resolver
 	Blacklist        []*IPNet
 	BlockedHostnames *types.HostnameTrie
+	BlockedPorts     []int // checked against the port to dial, after the Hosts
 	Hosts            map[string]*HostAddress
+	RetryPolicy      *RetryPolicy
+	// LocalIPs, when set, is the pool of the source IPs of the connections, the
+	// IP with the index LocalIPIndex of the family of the remote address is used.
+	// The connections to a family which isn't in the pool aren't bound.
+	LocalIPs     *types.IPPool
+	LocalIPIndex uint64
+	// Samples, when set, receives a metrics.BlockedDials sample for every dial
+	// denied by the Blacklist, the BlockedHostnames or the BlockedPorts, tagged
+	// with the reason, e.g. "blocked_port".
+	Samples chan<- stats.SampleContainer
 
 	BytesRead    int64
 	BytesWritten int64
+
+	dials, dialErrors, openConns int64
+	trailDials                   int64 // dials since the last GetTrail()
+	trailMaxConnAge              int64 // nanoseconds, of the connections closed since the last GetTrail()
+
+	dial func(ctx context.Context, network, addr string) (net.Conn, error) // replaces Dialer.DialContext in the tests
+
+	blacklistIndex atomic.Value // *blacklistIndex, see blacklist()
 }
 
 // NewDialer constructs a new Dialer with the given DNS resolver.
@@ -183,29 +220,233 @@ func (b BlockedHostError) Error() string {
 	return fmt.Sprintf("hostname (%s) is in a blocked pattern (%s)", b.hostname, b.match)
 }
 
+// BlockedPortError is returned when the port to dial is blocked
+type BlockedPortError struct {
+	port int
+	addr string
+}
+
+func (b BlockedPortError) Error() string {
+	return fmt.Sprintf("port (%d) of %s is blocked", b.port, b.addr)
+}
+
 // DialContext wraps the net.Dialer.DialContext and handles the k6 specifics
 func (d *Dialer) DialContext(ctx context.Context, proto, addr string) (net.Conn, error) {
-	dialAddr, err := d.getDialAddr(addr)
+	atomic.AddInt64(&d.dials, 1)
+	atomic.AddInt64(&d.trailDials, 1)
+
+	for attempt := 1; ; attempt++ {
+		conn, err := d.dialOnce(ctx, proto, addr)
+		if err == nil {
+			atomic.AddInt64(&d.openConns, 1)
+			return newConn(conn, d), nil
+		}
+
+		if d.RetryPolicy == nil || attempt >= d.RetryPolicy.MaxAttempts || !isRetryableDialError(err) {
+			atomic.AddInt64(&d.dialErrors, 1)
+			if d.Samples != nil {
+				d.pushBlocked(ctx, addr, err)
+			}
+			return nil, err
+		}
+
+		timer := time.NewTimer(d.RetryPolicy.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			atomic.AddInt64(&d.dialErrors, 1)
+			return nil, err
+		}
+	}
+}
+
+// pushBlocked pushes a metrics.BlockedDials sample when err denied the dial.
+func (d *Dialer) pushBlocked(ctx context.Context, addr string, err error) {
+	var reason string
+	switch err.(type) {
+	case BlackListedIPError:
+		reason = "blacklisted_ip"
+	case BlockedHostError:
+		reason = "blocked_hostname"
+	case BlockedPortError:
+		reason = "blocked_port"
+	default:
+		return
+	}
+	stats.PushIfNotDone(ctx, d.Samples, stats.Sample{
+		Time:   time.Now(),
+		Metric: metrics.BlockedDials,
+		Value:  1,
+		Tags:   stats.NewSampleTags(map[string]string{"reason": reason, "target": addr}),
+	})
+}
+
+// dialOnce resolves the address again on every call, so the retries pick up the DNS changes.
+func (d *Dialer) dialOnce(ctx context.Context, proto, addr string) (net.Conn, error) {
+	dialAddr, err := d.ResolveAddr(addr)
 	if err != nil {
 		return nil, err
 	}
-	conn, err := d.Dialer.DialContext(ctx, proto, dialAddr)
+	if d.dial != nil {
+		return d.dial(ctx, proto, dialAddr)
+	}
+	if d.LocalIPs != nil {
+		dialer := d.Dialer
+		dialer.LocalAddr = d.localAddr(proto, dialAddr)
+		return dialer.DialContext(ctx, proto, dialAddr)
+	}
+	return d.Dialer.DialContext(ctx, proto, dialAddr)
+}
+
+// localAddr returns the address of LocalIPs to bind the connection to the resolved
+// dialAddr to, or the LocalAddr of the net.Dialer when the pool has no IP of its family.
+func (d *Dialer) localAddr(proto, dialAddr string) net.Addr {
+	host, _, err := net.SplitHostPort(dialAddr)
 	if err != nil {
-		return nil, err
+		return d.Dialer.LocalAddr
+	}
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host = host[:i] // the zone of a link-local IPv6
 	}
-	conn = &Conn{conn, &d.BytesRead, &d.BytesWritten}
-	return conn, err
+	remote := net.ParseIP(host)
+	if remote == nil {
+		return d.Dialer.LocalAddr
+	}
+	ip, zone := d.LocalIPs.GetIPOfFamily(d.LocalIPIndex, remote.To4() == nil)
+	if ip == nil {
+		return d.Dialer.LocalAddr
+	}
+	if strings.HasPrefix(proto, "udp") {
+		return &net.UDPAddr{IP: ip, Zone: zone}
+	}
+	return &net.TCPAddr{IP: ip, Zone: zone}
+}
+
+// RetryPolicy specifies how the failed dials are retried, only temporary DNS
+// errors and refused connections are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of dials, including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it's doubled on every retry.
+	BaseDelay time.Duration
+	// Jitter is the fraction of the delay that is randomized, between 0 and 1.
+	Jitter float64
+}
+
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * rand.Float64() * float64(delay)) // nolint: gosec
+	}
+	return delay
+}
+
+func isRetryableDialError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.Temporary() || dnsErr.Timeout()
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// DialerStats are the connection counters of a Dialer.
+type DialerStats struct {
+	// Dials is the number of calls to DialContext.
+	Dials int64
+	// DialErrors is the number of dials that failed.
+	DialErrors int64
+	// OpenConns is the number of connections which were dialed and aren't closed yet.
+	OpenConns int64
+}
+
+// Stats returns the current connection counters of the Dialer.
+func (d *Dialer) Stats() DialerStats {
+	return DialerStats{
+		Dials:      atomic.LoadInt64(&d.dials),
+		DialErrors: atomic.LoadInt64(&d.dialErrors),
+		OpenConns:  atomic.LoadInt64(&d.openConns),
+	}
+}
+
+// BytesSnapshot is a mark of the byte counters of a Conn, see Conn.Snapshot.
+type BytesSnapshot struct {
+	conn          *Conn
+	read, written int64
+}
+
+// Snapshot marks the byte counters of the connection, so the bytes exchanged on
+// it since then can be known with Delta, e.g. around a single request. They're
+// only the bytes of this connection, so the concurrent requests on the other
+// connections of the Dialer aren't counted, and they aren't reset by GetTrail.
+//
+// A reused connection counts the bytes exchanged between the marks, e.g. the
+// remains of a previous response read late, and an HTTP/2 connection counts the
+// ones of all the requests multiplexed on it. They also include the protocol
+// overhead, such as the headers and the TLS records.
+func (c *Conn) Snapshot() BytesSnapshot {
+	return BytesSnapshot{
+		conn:    c,
+		read:    atomic.LoadInt64(&c.read),
+		written: atomic.LoadInt64(&c.written),
+	}
+}
+
+// Delta returns the bytes read and written on the connection since the snapshot.
+func (s BytesSnapshot) Delta() (read, written int64) {
+	if s.conn == nil {
+		return 0, 0
+	}
+	return atomic.LoadInt64(&s.conn.read) - s.read, atomic.LoadInt64(&s.conn.written) - s.written
+}
+
+// UnwrapConn returns the Conn of a connection of a Dialer, e.g. the one under the
+// *tls.Conn of an HTTPS request, as given by httptrace.GotConnInfo.
+func UnwrapConn(conn net.Conn) (*Conn, bool) {
+	for {
+		switch c := conn.(type) {
+		case *Conn:
+			return c, true
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// ReadBytes returns the bytes read by the connections of the Dialer since the
+// counters were last reset by ResetBytes or GetTrail, without resetting them.
+func (d *Dialer) ReadBytes() int64 {
+	return atomic.LoadInt64(&d.BytesRead)
+}
+
+// WrittenBytes returns the bytes written by the connections of the Dialer since
+// the counters were last reset by ResetBytes or GetTrail, without resetting them.
+func (d *Dialer) WrittenBytes() int64 {
+	return atomic.LoadInt64(&d.BytesWritten)
+}
+
+// ResetBytes resets the counters of ReadBytes and WrittenBytes, and returns the
+// bytes they had counted. Each counter is reset atomically, but not both at once.
+func (d *Dialer) ResetBytes() (read, written int64) {
+	written = atomic.SwapInt64(&d.BytesWritten, 0)
+	read = atomic.SwapInt64(&d.BytesRead, 0)
+	return read, written
 }
 
 // GetTrail creates a new NetTrail instance with the Dialer
-// sent and received data metrics and the supplied times and tags.
+// sent and received data metrics and the supplied times and tags. The
+// connections_opened sample is only added when connections were dialed since
+// the last call, so the trails of the iterations reusing them don't add zeros.
 // TODO: Refactor this according to
 // https://github.com/loadimpact/k6/pull/1203#discussion_r337938370
 func (d *Dialer) GetTrail(
 	startTime, endTime time.Time, tags *stats.SampleTags,
 ) *NetTrail {
-	bytesWritten := atomic.SwapInt64(&d.BytesWritten, 0)
-	bytesRead := atomic.SwapInt64(&d.BytesRead, 0)
+	bytesRead, bytesWritten := d.ResetBytes()
+	dials := atomic.SwapInt64(&d.trailDials, 0)
+	maxConnAge := atomic.SwapInt64(&d.trailMaxConnAge, 0)
 	samples := []stats.Sample{
 		{
 			Time:   endTime,
@@ -220,6 +461,22 @@ func (d *Dialer) GetTrail(
 			Tags:   tags,
 		},
 	}
+	if dials > 0 {
+		samples = append(samples, stats.Sample{
+			Time:   endTime,
+			Metric: metrics.ConnectionsOpened,
+			Value:  float64(dials),
+			Tags:   tags,
+		})
+	}
+	if maxConnAge > 0 {
+		samples = append(samples, stats.Sample{
+			Time:   endTime,
+			Metric: metrics.ConnectionMaxAge,
+			Value:  stats.D(time.Duration(maxConnAge)),
+			Tags:   tags,
+		})
+	}
 	// if fullIteration {
 	// 	samples = append(samples, stats.Sample{
 	// 		Time:   endTime,
@@ -248,21 +505,49 @@ func (d *Dialer) GetTrail(
 	}
 }
 
-func (d *Dialer) getDialAddr(addr string) (string, error) {
+// ResolveAddr resolves the host of addr and enforces the Hosts, BlockedHostnames,
+// Blacklist and BlockedPorts rules, like DialContext does. It returns the "ip:port" address
+// to dial, so transports which dial by themselves can follow the same rules.
+func (d *Dialer) ResolveAddr(addr string) (string, error) {
 	remote, err := d.findRemote(addr)
 	if err != nil {
 		return "", err
 	}
 
-	for _, ipnet := range d.Blacklist {
-		if ipnet.Contains(remote.IP) {
+	if len(d.Blacklist) > 0 {
+		if ipnet, ok := d.blacklist().contains(remote.IP); ok {
 			return "", BlackListedIPError{ip: remote.IP, net: ipnet}
 		}
 	}
 
+	for _, port := range d.BlockedPorts {
+		if remote.Port == port {
+			return "", BlockedPortError{port: port, addr: addr}
+		}
+	}
+
 	return remote.String(), nil
 }
 
+// blacklistIndex is the index of a Blacklist, with the slice it was built from.
+type blacklistIndex struct {
+	trie    *ipNetTrie
+	indexed []*IPNet
+}
+
+// blacklist returns the index of the Blacklist, it's rebuilt when the
+// Blacklist is replaced or its length changes. The dials load it without
+// locking, two dials rebuilding it at the same time build the same index.
+func (d *Dialer) blacklist() *ipNetTrie {
+	index, _ := d.blacklistIndex.Load().(*blacklistIndex)
+	if index == nil || len(index.indexed) != len(d.Blacklist) ||
+		&index.indexed[0] != &d.Blacklist[0] {
+		index = &blacklistIndex{trie: newIPNetTrie(d.Blacklist), indexed: d.Blacklist}
+		d.blacklistIndex.Store(index)
+	}
+	return index.trie
+}
+
 func (d *Dialer) findRemote(addr string) (*HostAddress, error) {
 	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
@@ -353,15 +638,80 @@ func (ntr *NetTrail) GetTime() time.Time {
 
 // Conn wraps net.Conn and keeps track of sent and received data size
 type Conn struct {
+	// read and written are the bytes of this connection, see Snapshot. They're
+	// accessed atomically, so they're first to be 64-bit aligned on 32-bit platforms.
+	read, written int64
+
 	net.Conn
 
 	BytesRead, BytesWritten *int64
+
+	openConns *int64
+	maxAge    *int64
+	closed    int32
+
+	dialTime time.Time
+	lastIO   int64 // unix nanoseconds
+}
+
+func newConn(conn net.Conn, d *Dialer) *Conn {
+	now := time.Now()
+	return &Conn{
+		Conn:         conn,
+		BytesRead:    &d.BytesRead,
+		BytesWritten: &d.BytesWritten,
+		openConns:    &d.openConns,
+		maxAge:       &d.trailMaxConnAge,
+		dialTime:     now,
+		lastIO:       now.UnixNano(),
+	}
+}
+
+// DialTime returns when the connection was established.
+func (c *Conn) DialTime() time.Time {
+	return c.dialTime
+}
+
+// LastIO returns when data was last read from or written to the connection.
+func (c *Conn) LastIO() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastIO))
+}
+
+// Age returns for how long the connection has been established.
+func (c *Conn) Age() time.Duration {
+	return time.Since(c.dialTime)
+}
+
+// IdleTime returns for how long the connection hasn't been read or written.
+func (c *Conn) IdleTime() time.Duration {
+	return time.Since(c.LastIO())
+}
+
+// Close closes the connection, the first call updates the counters of the Dialer.
+func (c *Conn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		if c.openConns != nil {
+			atomic.AddInt64(c.openConns, -1)
+		}
+		if c.maxAge != nil {
+			age := int64(c.Age())
+			for {
+				max := atomic.LoadInt64(c.maxAge)
+				if age <= max || atomic.CompareAndSwapInt64(c.maxAge, max, age) {
+					break
+				}
+			}
+		}
+	}
+	return c.Conn.Close()
 }
 
 func (c *Conn) Read(b []byte) (int, error) {
 	n, err := c.Conn.Read(b)
 	if n > 0 {
 		atomic.AddInt64(c.BytesRead, int64(n))
+		atomic.AddInt64(&c.read, int64(n))
+		atomic.StoreInt64(&c.lastIO, time.Now().UnixNano())
 	}
 	return n, err
 }
@@ -370,6 +720,8 @@ func (c *Conn) Write(b []byte) (int, error) {
 	n, err := c.Conn.Write(b)
 	if n > 0 {
 		atomic.AddInt64(c.BytesWritten, int64(n))
+		atomic.AddInt64(&c.written, int64(n))
+		atomic.StoreInt64(&c.lastIO, time.Now().UnixNano())
 	}
 	return n, err
 }
@@ -21,19 +21,26 @@
 package netext
 
 import (
+	"context"
+	"crypto/tls"
+	"io"
 	"net"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
-	"github.com/runner-mei/gojs/lib"
+	"github.com/runner-mei/gojs/lib/metrics"
 	"github.com/runner-mei/gojs/lib/testutils/mockresolver"
 	"github.com/runner-mei/gojs/lib/types"
+	"github.com/runner-mei/gojs/stats"
 )
 
 func TestDialerAddr(t *testing.T) {
 	dialer := NewDialer(net.Dialer{}, newResolver())
-	dialer.Hosts = map[string]*lib.HostAddress{
+	dialer.Hosts = map[string]*HostAddress{
 		"example.com":                {IP: net.ParseIP("3.4.5.6")},
 		"example.com:443":            {IP: net.ParseIP("3.4.5.6"), Port: 8443},
 		"example.com:8080":           {IP: net.ParseIP("3.4.5.6"), Port: 9090},
@@ -43,13 +50,13 @@ func TestDialerAddr(t *testing.T) {
 		"example-ipv6-deny-host.com": {IP: net.ParseIP("::1")},
 	}
 
-	ipNet, err := lib.ParseCIDR("8.9.10.0/24")
+	ipNet, err := ParseCIDR("8.9.10.0/24")
 	require.NoError(t, err)
 
-	ipV6Net, err := lib.ParseCIDR("::1/24")
+	ipV6Net, err := ParseCIDR("::1/24")
 	require.NoError(t, err)
 
-	dialer.Blacklist = []*lib.IPNet{ipNet, ipV6Net}
+	dialer.Blacklist = []*IPNet{ipNet, ipV6Net}
 
 	testCases := []struct {
 		address, expAddress, expErr string
@@ -78,7 +85,7 @@ func TestDialerAddr(t *testing.T) {
 		tc := tc
 
 		t.Run(tc.address, func(t *testing.T) {
-			addr, err := dialer.getDialAddr(tc.address)
+			addr, err := dialer.ResolveAddr(tc.address)
 
 			if tc.expErr != "" {
 				require.EqualError(t, err, tc.expErr)
@@ -92,7 +99,7 @@ func TestDialerAddr(t *testing.T) {
 
 func TestDialerAddrBlockHostnamesStar(t *testing.T) {
 	dialer := NewDialer(net.Dialer{}, newResolver())
-	dialer.Hosts = map[string]*lib.HostAddress{
+	dialer.Hosts = map[string]*HostAddress{
 		"example.com": {IP: net.ParseIP("3.4.5.6")},
 	}
 
@@ -113,7 +120,7 @@ func TestDialerAddrBlockHostnamesStar(t *testing.T) {
 		tc := tc
 
 		t.Run(tc.address, func(t *testing.T) {
-			addr, err := dialer.getDialAddr(tc.address)
+			addr, err := dialer.ResolveAddr(tc.address)
 
 			if tc.expErr != "" {
 				require.EqualError(t, err, tc.expErr)
@@ -125,6 +132,368 @@ func TestDialerAddrBlockHostnamesStar(t *testing.T) {
 	}
 }
 
+func TestDialerAddrBlockHostnamesGlobs(t *testing.T) {
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	dialer.Hosts = map[string]*HostAddress{
+		"db.internal.corp": {IP: net.ParseIP("3.4.5.6")},
+		"api-v1.corp":      {IP: net.ParseIP("3.4.5.7")},
+		"www.corp":         {IP: net.ParseIP("3.4.5.8")},
+	}
+
+	blocked, err := types.NewHostnameTrie([]string{"*.internal.*", "api-*.corp"})
+	require.NoError(t, err)
+	dialer.BlockedHostnames = blocked
+
+	_, err = dialer.ResolveAddr("db.internal.corp:5432")
+	require.EqualError(t, err, "hostname (db.internal.corp) is in a blocked pattern (*.internal.*)")
+	_, err = dialer.ResolveAddr("API-v1.corp:443")
+	require.EqualError(t, err, "hostname (API-v1.corp) is in a blocked pattern (api-*.corp)")
+	addr, err := dialer.ResolveAddr("www.corp:443")
+	require.NoError(t, err)
+	require.Equal(t, "3.4.5.8:443", addr)
+}
+
+func TestDialerAddrBlockedPorts(t *testing.T) {
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	dialer.Hosts = map[string]*HostAddress{
+		"example.com":      {IP: net.ParseIP("3.4.5.6")},
+		"example.com:443":  {IP: net.ParseIP("3.4.5.6"), Port: 22},
+		"example.com:3306": {IP: net.ParseIP("3.4.5.6"), Port: 8443},
+	}
+	dialer.BlockedPorts = []int{22, 3306}
+
+	testCases := []struct {
+		address, expAddress, expErr string
+	}{
+		{"example-resolver.com:80", "1.2.3.4:80", ""},
+		{"example-resolver.com:22", "", "port (22) of example-resolver.com:22 is blocked"},
+		{"1.2.3.4:3306", "", "port (3306) of 1.2.3.4:3306 is blocked"},
+		{"[2001:db8:aaaa:1::100]:22", "", "port (22) of [2001:db8:aaaa:1::100]:22 is blocked"},
+		{"example.com:80", "3.4.5.6:80", ""},
+		{"example.com:22", "", "port (22) of example.com:22 is blocked"},
+		// The port of the Hosts entry is checked, not the requested one.
+		{"example.com:443", "", "port (22) of example.com:443 is blocked"},
+		{"example.com:3306", "3.4.5.6:8443", ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.address, func(t *testing.T) {
+			addr, err := dialer.ResolveAddr(tc.address)
+
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expAddress, addr)
+			}
+		})
+	}
+}
+
+func TestDialerStats(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	ctx := context.Background()
+
+	conn1, err := dialer.DialContext(ctx, "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	conn2, err := dialer.DialContext(ctx, "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	_, err = dialer.DialContext(ctx, "tcp", "no-such-host.com:80")
+	require.Error(t, err)
+	require.Equal(t, DialerStats{Dials: 3, DialErrors: 1, OpenConns: 2}, dialer.Stats())
+
+	require.NoError(t, conn1.Close())
+	_ = conn1.Close()
+	require.Equal(t, DialerStats{Dials: 3, DialErrors: 1, OpenConns: 1}, dialer.Stats())
+	require.NoError(t, conn2.Close())
+	require.Equal(t, int64(0), dialer.Stats().OpenConns)
+
+	trail := dialer.GetTrail(time.Now(), time.Now(), nil)
+	require.Equal(t, 3.0, trail.Samples[2].Value)
+	require.Len(t, dialer.GetTrail(time.Now(), time.Now(), nil).Samples, 2)
+}
+
+func TestDialerBlockedSamples(t *testing.T) {
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	ipNet, err := ParseCIDR("8.9.10.0/24")
+	require.NoError(t, err)
+	dialer.Blacklist = []*IPNet{ipNet}
+	dialer.BlockedHostnames, err = types.NewHostnameTrie([]string{"*.blocked.com"})
+	require.NoError(t, err)
+	dialer.BlockedPorts = []int{22}
+	samples := make(chan stats.SampleContainer, 10)
+	dialer.Samples = samples
+	ctx := context.Background()
+
+	testCases := []struct {
+		address, reason string
+	}{
+		{"8.9.10.11:80", "blacklisted_ip"},
+		{"example-deny-resolver.com:80", "blacklisted_ip"},
+		{"www.blocked.com:443", "blocked_hostname"},
+		{"1.2.3.4:22", "blocked_port"},
+	}
+	for _, tc := range testCases {
+		_, err := dialer.DialContext(ctx, "tcp", tc.address)
+		require.Error(t, err)
+		require.Len(t, samples, 1)
+		sample := (<-samples).(stats.Sample)
+		require.Equal(t, metrics.BlockedDials, sample.Metric)
+		require.Equal(t, 1.0, sample.Value)
+		require.Equal(t, map[string]string{"reason": tc.reason, "target": tc.address}, sample.Tags.CloneTags())
+	}
+
+	_, err = dialer.DialContext(ctx, "tcp", "no-such-host.com:80")
+	require.Error(t, err)
+	require.Len(t, samples, 0)
+}
+
+func TestConnTimes(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+
+	c := conn.(*Conn)
+	require.Equal(t, c.DialTime(), c.LastIO())
+
+	time.Sleep(10 * time.Millisecond)
+	require.True(t, c.IdleTime() >= 10*time.Millisecond)
+	_, err = c.Write([]byte("a"))
+	require.NoError(t, err)
+	require.True(t, c.LastIO().After(c.DialTime()))
+	require.True(t, c.IdleTime() < c.Age())
+
+	require.NoError(t, c.Close())
+	trail := dialer.GetTrail(time.Now(), time.Now(), nil)
+	require.Len(t, trail.Samples, 4)
+	require.True(t, trail.Samples[3].Value >= 10)
+	require.Len(t, dialer.GetTrail(time.Now(), time.Now(), nil).Samples, 2)
+}
+
+func TestConnSnapshot(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("hello"))
+			_ = conn.Close()
+		}
+	}()
+
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	netConn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = netConn.Close() }()
+	conn, ok := UnwrapConn(tls.Client(netConn, &tls.Config{}))
+	require.True(t, ok)
+	require.Same(t, netConn, conn)
+
+	_, err = conn.Write([]byte("a"))
+	require.NoError(t, err)
+	snapshot := conn.Snapshot()
+
+	// The bytes of the other connections of the dialer aren't counted.
+	other, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = other.Close() }()
+	_, err = other.Write([]byte("other"))
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("bcd"))
+	require.NoError(t, err)
+	// GetTrail resets the counters of the trails, not the ones of the snapshots.
+	dialer.GetTrail(time.Now(), time.Now(), nil)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+
+	read, written := snapshot.Delta()
+	require.Equal(t, int64(5), read)
+	require.Equal(t, int64(3), written)
+	require.Equal(t, int64(5), atomic.LoadInt64(&dialer.BytesRead))
+
+	read, written = BytesSnapshot{}.Delta()
+	require.Zero(t, read)
+	require.Zero(t, written)
+
+	_, ok = UnwrapConn(&net.TCPConn{})
+	require.False(t, ok)
+}
+
+func TestDialerBytes(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("hello"))
+		_ = conn.Close()
+	}()
+
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte("abc"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(conn, make([]byte, 5))
+	require.NoError(t, err)
+
+	// Reading the counters doesn't reset them.
+	for i := 0; i < 2; i++ {
+		require.Equal(t, int64(5), dialer.ReadBytes())
+		require.Equal(t, int64(3), dialer.WrittenBytes())
+	}
+
+	read, written := dialer.ResetBytes()
+	require.Equal(t, int64(5), read)
+	require.Equal(t, int64(3), written)
+	require.Zero(t, dialer.ReadBytes())
+	require.Zero(t, dialer.WrittenBytes())
+
+	// GetTrail still resets them.
+	_, err = conn.Write([]byte("de"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), dialer.WrittenBytes())
+	trail := dialer.GetTrail(time.Now(), time.Now(), nil)
+	require.Equal(t, int64(2), trail.BytesWritten)
+	require.Zero(t, trail.BytesRead)
+	require.Zero(t, dialer.WrittenBytes())
+}
+
+func TestDialerLocalIPs(t *testing.T) {
+	pool, err := types.NewIPPool("::1,127.0.0.1")
+	require.NoError(t, err)
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	dialer.LocalIPs = pool
+
+	for _, network := range []string{"127.0.0.1:0", "[::1]:0"} {
+		listener, err := net.Listen("tcp", network)
+		if err != nil {
+			t.Logf("can't listen on %s: %s", network, err)
+			continue
+		}
+		conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+		require.NoError(t, err)
+		local := conn.LocalAddr().(*net.TCPAddr)
+		remote := listener.Addr().(*net.TCPAddr)
+		require.True(t, local.IP.Equal(remote.IP), "%s from %s", remote, local)
+		_ = conn.Close()
+		_ = listener.Close()
+	}
+
+	// The family of the remote address isn't in the pool.
+	pool, err = types.NewIPPool("::1")
+	require.NoError(t, err)
+	dialer.LocalIPs = pool
+	require.Nil(t, dialer.localAddr("tcp", "127.0.0.1:80"))
+	addr := dialer.localAddr("tcp", "[fe80::1]:80").(*net.TCPAddr)
+	require.Equal(t, "::1", addr.IP.String())
+
+	pool, err = types.NewIPPool("fe80::/127%lo")
+	require.NoError(t, err)
+	dialer.LocalIPs = pool
+	dialer.LocalIPIndex = 1
+	udpAddr := dialer.localAddr("udp", "[fe80::2%lo]:53").(*net.UDPAddr)
+	require.Equal(t, "fe80::1", udpAddr.IP.String())
+	require.Equal(t, "lo", udpAddr.Zone)
+}
+
+func TestDialerRetryPolicy(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	newFlakyDialer := func(failures int) (*Dialer, *[]string) {
+		dialer := NewDialer(net.Dialer{}, newResolver())
+		dialer.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5}
+		var addrs []string
+		dialer.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			addrs = append(addrs, addr)
+			if len(addrs) <= failures {
+				return nil, refused
+			}
+			conn, _ := net.Pipe()
+			return conn, nil
+		}
+		return dialer, &addrs
+	}
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		dialer, addrs := newFlakyDialer(2)
+		resolver := dialer.Resolver.(*mockresolver.MockResolver)
+		dial := dialer.dial
+		dialer.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			resolver.Set("example-resolver.com", "5.6.7.8")
+			return dial(ctx, network, addr)
+		}
+
+		conn, err := dialer.DialContext(context.Background(), "tcp", "example-resolver.com:80")
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+		require.Equal(t, []string{"1.2.3.4:80", "5.6.7.8:80", "5.6.7.8:80"}, *addrs)
+		require.Equal(t, DialerStats{Dials: 1}, dialer.Stats())
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		dialer, addrs := newFlakyDialer(3)
+		_, err := dialer.DialContext(context.Background(), "tcp", "1.2.3.4:80")
+		require.Equal(t, refused, err)
+		require.Len(t, *addrs, 3)
+		require.Equal(t, DialerStats{Dials: 1, DialErrors: 1}, dialer.Stats())
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		dialer, addrs := newFlakyDialer(3)
+		dialer.RetryPolicy.BaseDelay = time.Hour
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := dialer.DialContext(ctx, "tcp", "1.2.3.4:80")
+		require.Equal(t, refused, err)
+		require.Len(t, *addrs, 1)
+	})
+
+	t.Run("non-retryable errors fail immediately", func(t *testing.T) {
+		dialer, addrs := newFlakyDialer(0)
+		ipNet, err := ParseCIDR("8.9.10.0/24")
+		require.NoError(t, err)
+		dialer.Blacklist = []*IPNet{ipNet}
+		blocked, err := types.NewHostnameTrie([]string{"*.blocked.com"})
+		require.NoError(t, err)
+		dialer.BlockedHostnames = blocked
+
+		_, err = dialer.DialContext(context.Background(), "tcp", "example-deny-resolver.com:80")
+		require.IsType(t, BlackListedIPError{}, err)
+		_, err = dialer.DialContext(context.Background(), "tcp", "www.blocked.com:80")
+		require.IsType(t, BlockedHostError{}, err)
+		require.Empty(t, *addrs)
+		require.Equal(t, DialerStats{Dials: 2, DialErrors: 2}, dialer.Stats())
+	})
+
+	t.Run("retryable errors", func(t *testing.T) {
+		require.True(t, isRetryableDialError(refused))
+		require.True(t, isRetryableDialError(&net.DNSError{Err: "server misbehaving", IsTemporary: true}))
+		require.False(t, isRetryableDialError(&net.DNSError{Err: "no such host", IsNotFound: true}))
+		require.False(t, isRetryableDialError(BlockedHostError{}))
+	})
+}
+
 func newResolver() *mockresolver.MockResolver {
 	return mockresolver.New(
 		map[string][]net.IP{
@@ -0,0 +1,184 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/runner-mei/gojs/lib/types"
+)
+
+const dohMediaType = "application/dns-message"
+
+// DoHLookup resolves hosts with DNS-over-HTTPS (RFC 8484) queries to Endpoint,
+// its LookupIPs method is a MultiResolver.
+type DoHLookup struct {
+	// Endpoint is the URL of the DoH server, e.g. https://cloudflare-dns.com/dns-query
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewDoHLookup returns a DoHLookup querying the endpoint. Since the host of
+// the endpoint can't be resolved with DoH itself, it's dialed to the bootstrap
+// IPs if any are given, the system resolver is used otherwise.
+func NewDoHLookup(endpoint string, bootstrap []net.IP) (*DoHLookup, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid DoH endpoint '%s', an http(s) URL is expected", endpoint)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: dialer.DialContext,
+	}
+	if len(bootstrap) > 0 {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil || host != u.Hostname() {
+				return dialer.DialContext(ctx, network, addr)
+			}
+			for _, ip := range bootstrap {
+				var conn net.Conn
+				if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		}
+	}
+
+	return &DoHLookup{
+		Endpoint: endpoint,
+		Client:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// NewDoHResolver returns a Resolver using DNS-over-HTTPS, with the same
+// semantic for ttl, sel and pol as the resolver returned by NewResolver.
+func NewDoHResolver(
+	endpoint string, bootstrap []net.IP, ttl time.Duration, sel types.DNSSelect, pol types.DNSPolicy,
+) (Resolver, error) {
+	lookup, err := NewDoHLookup(endpoint, bootstrap)
+	if err != nil {
+		return nil, err
+	}
+	return NewResolver(lookup.LookupIPs, ttl, sel, pol), nil
+}
+
+// LookupIPs returns the IPv4 and IPv6 addresses of the host.
+func (d *DoHLookup) LookupIPs(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	var ips []net.IP
+	var lastErr error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := d.query(host, qtype)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ips = append(ips, answers...)
+	}
+	if len(ips) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return ips, nil
+}
+
+func (d *DoHLookup) query(host string, qtype dnsmessage.Type) ([]net.IP, error) {
+	if !strings.HasSuffix(host, ".") {
+		host += "."
+	}
+	name, err := dnsmessage.NewName(host)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ID should be 0 to be cache friendly, see RFC 8484 section 4.1.
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.Endpoint, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lookup %s: DoH server returned %s", host, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, fmt.Errorf("lookup %s: invalid DoH response: %w", host, err)
+	}
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+	default:
+		return nil, fmt.Errorf("lookup %s: DoH server returned %s", host, answer.RCode)
+	}
+
+	var ips []net.IP
+	for _, resource := range answer.Answers {
+		switch body := resource.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(append([]byte(nil), body.A[:]...)))
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(append([]byte(nil), body.AAAA[:]...)))
+		}
+	}
+	return ips, nil
+}
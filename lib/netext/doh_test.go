@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/runner-mei/gojs/lib/types"
+)
+
+func newDoHServer(t *testing.T, records map[string][]net.IP) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, dohMediaType, r.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var msg dnsmessage.Message
+		require.NoError(t, msg.Unpack(body))
+		q := msg.Questions[0]
+
+		msg.Header.Response = true
+		for _, ip := range records[q.Name.String()] {
+			header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60}
+			if ip4 := ip.To4(); ip4 != nil && q.Type == dnsmessage.TypeA {
+				a := &dnsmessage.AResource{}
+				copy(a.A[:], ip4)
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: a})
+			} else if ip4 == nil && q.Type == dnsmessage.TypeAAAA {
+				aaaa := &dnsmessage.AAAAResource{}
+				copy(aaaa.AAAA[:], ip)
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: aaaa})
+			}
+		}
+		if len(msg.Answers) == 0 && len(records[q.Name.String()]) == 0 {
+			msg.Header.RCode = dnsmessage.RCodeNameError
+		}
+
+		packed, err := msg.Pack()
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", dohMediaType)
+		_, _ = w.Write(packed)
+	}))
+}
+
+func TestDoHLookup(t *testing.T) {
+	srv := newDoHServer(t, map[string][]net.IP{
+		"example.com.": {net.ParseIP("1.2.3.4"), net.ParseIP("2001:db8::68")},
+	})
+	defer srv.Close()
+
+	t.Run("LookupIPs", func(t *testing.T) {
+		lookup, err := NewDoHLookup(srv.URL, nil)
+		require.NoError(t, err)
+
+		ips, err := lookup.LookupIPs("example.com")
+		require.NoError(t, err)
+		assert.Equal(t, []net.IP{net.ParseIP("1.2.3.4").To4(), net.ParseIP("2001:db8::68")}, ips)
+
+		ips, err = lookup.LookupIPs("no-such-host.com")
+		require.NoError(t, err)
+		assert.Empty(t, ips)
+	})
+
+	t.Run("Bootstrap", func(t *testing.T) {
+		_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+		require.NoError(t, err)
+
+		// the host of the endpoint can only be resolved with the bootstrap IPs
+		lookup, err := NewDoHLookup("http://doh.invalid:"+port, []net.IP{net.ParseIP("127.0.0.1")})
+		require.NoError(t, err)
+
+		ips, err := lookup.LookupIPs("example.com")
+		require.NoError(t, err)
+		assert.Len(t, ips, 2)
+	})
+
+	t.Run("Resolver", func(t *testing.T) {
+		resolver, err := NewDoHResolver(srv.URL, nil, 0, types.DNSfirst, types.DNSonlyIPv6)
+		require.NoError(t, err)
+
+		ip, err := resolver.LookupIP("example.com")
+		require.NoError(t, err)
+		assert.Equal(t, net.ParseIP("2001:db8::68"), ip)
+	})
+
+	t.Run("InvalidEndpoint", func(t *testing.T) {
+		_, err := NewDoHLookup("udp://1.1.1.1", nil)
+		assert.Error(t, err)
+	})
+}
@@ -29,6 +29,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
 	"strconv"
 	"strings"
@@ -39,6 +40,7 @@ import (
 	null "gopkg.in/guregu/null.v3"
 
 	"github.com/runner-mei/gojs/lib"
+	"github.com/runner-mei/gojs/lib/netext"
 	"github.com/runner-mei/gojs/stats"
 )
 
@@ -99,6 +101,11 @@ type Request struct {
 	Headers map[string][]string             `json:"headers"`
 	Body    string                          `json:"body"`
 	Cookies map[string][]*HTTPRequestCookie `json:"cookies"`
+	// BodyBytesSent and BodyBytesReceived are the bytes written and read on the
+	// connections of the request, redirects included, while it was made, see
+	// netext.Conn.Snapshot for their accuracy.
+	BodyBytesSent     int64 `json:"body_bytes_sent" js:"bodyBytesSent"`
+	BodyBytesReceived int64 `json:"body_bytes_received" js:"bodyBytesReceived"`
 }
 
 // ParsedHTTPRequest a represantion of a request after it has been parsed from a user script
@@ -115,6 +122,9 @@ type ParsedHTTPRequest struct {
 	ActiveJar    *cookiejar.Jar
 	Cookies      map[string]*HTTPRequestCookie
 	Tags         map[string]string
+	// NoConnectionReuse makes the request, and its redirects, use new connections
+	// through the FreshTransport of the state.
+	NoConnectionReuse bool
 }
 
 // Matches non-compliant io.Closer implementations (e.g. zstd.Decoder)
@@ -170,6 +180,35 @@ func updateK6Response(k6Response *Response, finishedReq *finishedRequest) {
 	}
 }
 
+// connBytes marks the byte counters of the connections a request is sent on, the
+// connections of a redirect included.
+type connBytes map[*netext.Conn]netext.BytesSnapshot
+
+// trace returns ctx with a trace marking the connections the request gets.
+func (b connBytes) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn, ok := netext.UnwrapConn(info.Conn)
+			if !ok {
+				return
+			}
+			if _, seen := b[conn]; !seen {
+				b[conn] = conn.Snapshot()
+			}
+		},
+	})
+}
+
+// delta returns the bytes read and written on the connections since they were marked.
+func (b connBytes) delta() (read, written int64) {
+	for _, snapshot := range b {
+		r, w := snapshot.Delta()
+		read += r
+		written += w
+	}
+	return read, written
+}
+
 // MakeRequest makes http request for tor the provided ParsedHTTPRequest
 func MakeRequest(ctx context.Context, preq *ParsedHTTPRequest) (*Response, error) {
 	state := lib.GetState(ctx)
@@ -252,22 +291,20 @@ func MakeRequest(ctx context.Context, preq *ParsedHTTPRequest) (*Response, error
 	}
 
 	tracerTransport := newTransport(ctx, state, tags)
-	var transport http.RoundTripper = tracerTransport
-
-	// Combine tags with common log fields
-	combinedLogFields := []log.Field{
-		log.String("source", "http-debug"),
-	}
-	for k, v := range tags {
-		combinedLogFields = append(combinedLogFields, log.String(k, v))
+	if preq.NoConnectionReuse && state.FreshTransport != nil {
+		tracerTransport.roundTripper = state.FreshTransport
 	}
+	var transport http.RoundTripper = tracerTransport
 
 	if state.Options.HTTPDebug.String != "" {
-		transport = httpDebugTransport{
-			originalTransport: transport,
-			httpDebugOption:   state.Options.HTTPDebug.String,
-			logger:            state.Logger.With(combinedLogFields...),
+		// Combine tags with common log fields
+		combinedLogFields := []log.Field{
+			log.String("source", "http-debug"),
+		}
+		for k, v := range tags {
+			combinedLogFields = append(combinedLogFields, log.String(k, v))
 		}
+		transport = lib.NewHTTPDebugTransport(transport, state.Options, state.Logger.With(combinedLogFields...))
 	}
 
 	if preq.Auth == "digest" {
@@ -310,7 +347,8 @@ func MakeRequest(ctx context.Context, preq *ParsedHTTPRequest) (*Response, error
 
 	reqCtx, cancelFunc := context.WithTimeout(ctx, preq.Timeout)
 	defer cancelFunc()
-	mreq := preq.Req.WithContext(reqCtx)
+	counters := connBytes{}
+	mreq := preq.Req.WithContext(counters.trace(reqCtx))
 	res, resErr := client.Do(mreq)
 
 	// TODO(imiric): It would be safer to check for a writeable
@@ -323,6 +361,7 @@ func MakeRequest(ctx context.Context, preq *ParsedHTTPRequest) (*Response, error
 	}
 
 	resp.Body, resErr = readResponseBody(state, preq.ResponseType, res, resErr)
+	resp.Request.BodyBytesReceived, resp.Request.BodyBytesSent = counters.delta()
 	finishedReq := tracerTransport.processLastSavedRequest(wrapDecompressionError(resErr))
 	if finishedReq != nil {
 		updateK6Response(resp, finishedReq)
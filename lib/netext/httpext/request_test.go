@@ -25,9 +25,12 @@ import (
 	"context"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -36,8 +39,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/runner-mei/gojs/lib"
+	"github.com/runner-mei/gojs/lib/netext"
 	"github.com/runner-mei/gojs/stats"
+	"github.com/runner-mei/log"
 	"github.com/runner-mei/log/logtest"
+	null "gopkg.in/guregu/null.v3"
 )
 
 type reader func([]byte) (int, error)
@@ -278,3 +284,183 @@ func BenchmarkWrapDecompressionError(b *testing.B) {
 		_ = wrapDecompressionError(err)
 	}
 }
+
+func TestMakeRequestTagger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	samples := make(chan stats.SampleContainer, 10)
+
+	state := &lib.State{
+		Options: lib.Options{
+			RunTags:    &stats.SampleTags{},
+			SystemTags: &stats.DefaultSystemTagSet,
+		},
+		Transport: srv.Client().Transport,
+		Samples:   samples,
+		Logger:    logtest.NewLogger(t),
+		Tags:      map[string]string{"vu_tag": "vu", "trace_id": "static"},
+		RequestTagger: func(req *http.Request) map[string]string {
+			return map[string]string{"trace_id": req.Header.Get("X-Trace-Id"), "method": "overridden"}
+		},
+	}
+	ctx := lib.WithState(context.Background(), state)
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("X-Trace-Id", "abc")
+	preq := &ParsedHTTPRequest{
+		Req:     req,
+		URL:     &URL{u: req.URL, URL: srv.URL},
+		Body:    new(bytes.Buffer),
+		Timeout: 10 * time.Second,
+		Tags:    map[string]string{"req_tag": "req"},
+	}
+
+	_, err := MakeRequest(ctx, preq)
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+	for _, s := range (<-samples).GetSamples() {
+		tags := s.Tags.CloneTags()
+		assert.Equal(t, "abc", tags["trace_id"])
+		assert.Equal(t, "vu", tags["vu_tag"])
+		assert.Equal(t, "req", tags["req_tag"])
+		assert.Equal(t, "GET", tags["method"])
+	}
+}
+
+func TestMakeRequestHTTPDebug(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	opts := lib.Options{
+		RunTags:    &stats.SampleTags{},
+		SystemTags: &stats.DefaultSystemTagSet,
+		HTTPDebug:  null.StringFrom("headers"),
+	}
+	newStates := map[string]func(t *testing.T, logger log.Logger) *lib.State{
+		"literal": func(t *testing.T, logger log.Logger) *lib.State {
+			return &lib.State{Options: opts, Transport: srv.Client().Transport, Logger: logger}
+		},
+		"NewState": func(t *testing.T, logger log.Logger) *lib.State {
+			state, err := lib.NewState(logger, opts)
+			require.NoError(t, err)
+			return state
+		},
+	}
+	for name, newState := range newStates {
+		newState := newState
+		t.Run(name, func(t *testing.T) {
+			logger, observedLogs := logtest.NewObservedLogger()
+			state := newState(t, logger)
+			state.Samples = make(chan stats.SampleContainer, 10)
+			ctx := lib.WithState(context.Background(), state)
+			req, _ := http.NewRequest("GET", srv.URL, nil)
+			preq := &ParsedHTTPRequest{
+				Req:     req,
+				URL:     &URL{u: req.URL, URL: srv.URL},
+				Body:    new(bytes.Buffer),
+				Timeout: 10 * time.Second,
+				Tags:    map[string]string{"req_tag": "req"},
+			}
+
+			_, err := MakeRequest(ctx, preq)
+			require.NoError(t, err)
+
+			var requests int
+			for _, entry := range observedLogs.All() {
+				if !strings.HasPrefix(entry.Message, "Request:") {
+					continue
+				}
+				requests++
+				assert.Equal(t, "http-debug", entry.ContextMap()["source"])
+				assert.Equal(t, "req", entry.ContextMap()["req_tag"])
+			}
+			assert.Equal(t, 1, requests)
+		})
+	}
+}
+
+func TestMakeRequestBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(ioutil.Discard, r.Body)
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	dialer := netext.NewDialer(net.Dialer{}, nil)
+	state := &lib.State{
+		Options:   lib.Options{RunTags: &stats.SampleTags{}},
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		Dialer:    dialer,
+		Logger:    logtest.NewLogger(t),
+		Samples:   make(chan stats.SampleContainer, 10),
+	}
+	ctx := lib.WithState(context.Background(), state)
+
+	var previous Request
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("POST", srv.URL, bytes.NewBufferString("body"))
+		require.NoError(t, err)
+		preq := &ParsedHTTPRequest{
+			Req:          req,
+			URL:          &URL{u: req.URL, URL: srv.URL},
+			Body:         bytes.NewBufferString("body"),
+			Timeout:      10 * time.Second,
+			ResponseType: ResponseTypeText,
+		}
+
+		res, err := MakeRequest(ctx, preq)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", res.Body)
+		assert.True(t, res.Request.BodyBytesSent > int64(len("body")), res.Request.BodyBytesSent)
+		assert.True(t, res.Request.BodyBytesReceived > int64(len("hello")), res.Request.BodyBytesReceived)
+		if i > 0 {
+			// The connection is reused, the second request has the same size.
+			assert.Equal(t, previous.BodyBytesSent, res.Request.BodyBytesSent)
+		}
+		previous = res.Request
+	}
+}
+
+func TestMakeRequestNoConnectionReuse(t *testing.T) {
+	var newConns int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&newConns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	state := &lib.State{
+		Options:        lib.Options{RunTags: &stats.SampleTags{}},
+		Transport:      &http.Transport{},
+		FreshTransport: &http.Transport{DisableKeepAlives: true},
+		Logger:         logtest.NewLogger(t),
+		Samples:        make(chan stats.SampleContainer, 10),
+	}
+	ctx := lib.WithState(context.Background(), state)
+
+	for i, tc := range []struct {
+		noConnectionReuse bool
+		newConns          int64
+	}{
+		{false, 1},
+		{false, 1},
+		{true, 2},
+		{false, 2},
+		{true, 3},
+	} {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		require.NoError(t, err)
+		preq := &ParsedHTTPRequest{
+			Req:               req,
+			URL:               &URL{u: req.URL, URL: srv.URL},
+			Body:              new(bytes.Buffer),
+			Timeout:           10 * time.Second,
+			NoConnectionReuse: tc.noConnectionReuse,
+		}
+		_, err = MakeRequest(ctx, preq)
+		require.NoError(t, err)
+		assert.Equal(t, tc.newConns, atomic.LoadInt64(&newConns), "request %d", i)
+	}
+}
@@ -39,6 +39,8 @@ type transport struct {
 	ctx   context.Context
 	state *lib.State
 	tags  map[string]string
+	// roundTripper is the Transport of the state, or its FreshTransport.
+	roundTripper http.RoundTripper
 
 	lastRequest     *unfinishedRequest
 	lastRequestLock *sync.Mutex
@@ -81,6 +83,7 @@ func newTransport(
 		ctx:             ctx,
 		state:           state,
 		tags:            tags,
+		roundTripper:    state.Transport,
 		lastRequestLock: new(sync.Mutex),
 	}
 }
@@ -94,6 +97,11 @@ func (t *transport) measureAndEmitMetrics(unfReq *unfinishedRequest) *finishedRe
 	for k, v := range t.tags {
 		tags[k] = v
 	}
+	if t.state.RequestTagger != nil {
+		for k, v := range t.state.RequestTagger(unfReq.request) {
+			tags[k] = v
+		}
+	}
 
 	result := &finishedRequest{
 		unfinishedRequest: unfReq,
@@ -210,7 +218,7 @@ func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	ctx := req.Context()
 	tracer := &Tracer{}
 	reqWithTracer := req.WithContext(httptrace.WithClientTrace(ctx, tracer.Trace()))
-	resp, err := t.state.Transport.RoundTrip(reqWithTracer)
+	resp, err := t.roundTripper.RoundTrip(reqWithTracer)
 
 	t.saveCurrentRequest(&unfinishedRequest{
 		ctx:      ctx,
@@ -113,4 +113,35 @@ func TestResolver(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Policy", func(t *testing.T) {
+		mr := mockresolver.New(map[string][]net.IP{
+			"ip4host": {net.ParseIP("127.0.0.10")},
+			"ip6host": {net.ParseIP("2001:db8::10")},
+			"anyhost": {net.ParseIP("2001:db8::10"), net.ParseIP("127.0.0.10")},
+		}, nil)
+
+		testCases := []struct {
+			host  string
+			pol   types.DNSPolicy
+			expIP net.IP
+		}{
+			{"ip6host", types.DNSpreferIPv4, net.ParseIP("2001:db8::10")},
+			{"ip4host", types.DNSpreferIPv6, net.ParseIP("127.0.0.10")},
+			{"ip6host", types.DNSonlyIPv4, nil},
+			{"ip4host", types.DNSonlyIPv6, nil},
+			{"anyhost", types.DNSany, net.ParseIP("2001:db8::10")},
+			{"anyhost", types.DNSpreferIPv4, net.ParseIP("127.0.0.10")},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(fmt.Sprintf("%s_%s", tc.host, tc.pol), func(t *testing.T) {
+				r := NewResolver(mr.LookupIPAll, 0, types.DNSfirst, tc.pol)
+				ip, err := r.LookupIP(tc.host)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expIP, ip)
+			})
+		}
+	})
 }
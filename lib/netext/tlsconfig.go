@@ -22,6 +22,9 @@ package netext
 import (
 	"crypto/tls"
 	"encoding/json"
+	"io/fs"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -56,23 +59,52 @@ type TLSVersionsFields struct {
 	Max TLSVersion `json:"max" ignored:"true"` // Maximum allowed version, 0 = any.
 }
 
-// Describes a set (min/max) of TLS versions.
+// Describes a set (min/max) of TLS versions. Besides the object form, it's
+// unmarshalled from a string, either a single version, eg. "tls1.2", or an
+// open-ended range, eg. "tls1.2+" for TLS 1.2 or higher.
 type TLSVersions TLSVersionsFields
 
+// MarshalJSON uses the open-ended "tls1.2+" form when only Min is set.
+func (v TLSVersions) MarshalJSON() ([]byte, error) {
+	if v.Min != 0 && v.Max == 0 {
+		return json.Marshal(SupportedTLSVersionsToString[v.Min] + "+")
+	}
+	return json.Marshal(TLSVersionsFields(v))
+}
+
 func (v *TLSVersions) UnmarshalJSON(data []byte) error {
 	var fields TLSVersionsFields
 	if err := json.Unmarshal(data, &fields); err != nil {
-		var ver TLSVersion
-		if err2 := json.Unmarshal(data, &ver); err2 != nil {
+		var str string
+		if err2 := json.Unmarshal(data, &str); err2 != nil {
 			return err
 		}
+		var ver TLSVersion
+		openEnded := strings.HasSuffix(str, "+")
+		if err2 := ver.UnmarshalJSON([]byte(strconv.Quote(strings.TrimSuffix(str, "+")))); err2 != nil {
+			return err2
+		}
 		fields.Min = ver
-		fields.Max = ver
+		if !openEnded {
+			fields.Max = ver
+		}
+	}
+	if err := TLSVersions(fields).validate(); err != nil {
+		return err
 	}
 	*v = TLSVersions(fields)
 	return nil
 }
 
+// validate returns an error if the range can't be satisfied by any version.
+func (v TLSVersions) validate() error {
+	if v.Min != 0 && v.Max != 0 && v.Min > v.Max {
+		return errors.Errorf("invalid TLS version range: min (%s) is higher than max (%s)",
+			SupportedTLSVersionsToString[v.Min], SupportedTLSVersionsToString[v.Max])
+	}
+	return nil
+}
+
 func (v *TLSVersions) IsTLS13() bool {
 	return v.Min == TLSVersion13 || v.Max == TLSVersion13
 }
@@ -121,6 +153,16 @@ type TLSAuthFields struct {
 	Cert string `json:"cert"`
 	Key  string `json:"key"`
 
+	// Paths of the PEM-encoded certificate and key, read when Cert or Key are
+	// empty from the file system given to CertificateFS, see Options.TLSAuthFS.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+
+	// Password of the key, if it's encrypted, either as a PKCS#8 "ENCRYPTED PRIVATE KEY"
+	// or as a legacy PEM block with a "Proc-Type: 4,ENCRYPTED" header. It's read by
+	// TLSAuth.UnmarshalJSON but never marshalled, so it doesn't leak with the options.
+	Password string `json:"-"`
+
 	// Domains to present the certificate to. May contain wildcards, eg. "*.example.com".
 	Domains []string `json:"domains"`
 }
@@ -135,15 +177,53 @@ func (c *TLSAuth) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &c.TLSAuthFields); err != nil {
 		return err
 	}
+	var secret struct {
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return err
+	}
+	c.Password = secret.Password
+	// The files are read later, from the file system given to NewState.
+	if c.readsFiles() {
+		return nil
+	}
 	if _, err := c.Certificate(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// readsFiles returns true if the certificate or the key is read from a file.
+func (c *TLSAuth) readsFiles() bool {
+	return (c.Cert == "" && c.CertFile != "") || (c.Key == "" && c.KeyFile != "")
+}
+
+// Certificate returns the certificate of the inline Cert and Key, or the one
+// already loaded by CertificateFS.
 func (c *TLSAuth) Certificate() (*tls.Certificate, error) {
+	return c.CertificateFS(nil)
+}
+
+// CertificateFS returns the certificate, reading CertFile and KeyFile from fsys
+// when Cert or Key are empty. The paths are names of fsys, e.g. the ones returned
+// by InitEnvironment.ResolvePath, so the files are read in the same sandbox as
+// the scripts, and they can't be read at all when fsys is nil.
+func (c *TLSAuth) CertificateFS(fsys fs.FS) (*tls.Certificate, error) {
 	if c.certificate == nil {
-		cert, err := tls.X509KeyPair([]byte(c.Cert), []byte(c.Key))
+		certPEM, err := readPEM(fsys, c.Cert, c.CertFile)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := readPEM(fsys, c.Key, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := decryptPEMKey(keyPEM, c.Password)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair(certPEM, key)
 		if err != nil {
 			return nil, err
 		}
@@ -152,6 +232,17 @@ func (c *TLSAuth) Certificate() (*tls.Certificate, error) {
 	return c.certificate, nil
 }
 
+// readPEM returns the inline PEM data, or reads it from filename in fsys when it's empty.
+func readPEM(fsys fs.FS, inline, filename string) ([]byte, error) {
+	if inline != "" || filename == "" {
+		return []byte(inline), nil
+	}
+	if fsys == nil {
+		return nil, errors.Errorf("can't read the TLS auth file '%s' without a file system, see Options.TLSAuthFS", filename)
+	}
+	return fs.ReadFile(fsys, filename)
+}
+
 // From https://golang.org/pkg/crypto/tls/#pkg-constants
 
 // SupportedTLSVersions is string-to-constant map of available TLS versions.
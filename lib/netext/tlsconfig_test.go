@@ -0,0 +1,128 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSVersionsUnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		json   string
+		exp    TLSVersions
+		expErr string
+	}{
+		{`"tls1.2"`, TLSVersions{Min: tls.VersionTLS12, Max: tls.VersionTLS12}, ""},
+		{`""`, TLSVersions{}, ""},
+		{`{}`, TLSVersions{}, ""},
+		{`{"min":"tls1.0","max":"tls1.2"}`, TLSVersions{Min: tls.VersionTLS10, Max: tls.VersionTLS12}, ""},
+		{`{"min":"tls1.2","max":"tls1.2"}`, TLSVersions{Min: tls.VersionTLS12, Max: tls.VersionTLS12}, ""},
+		{`{"min":"tls1.2"}`, TLSVersions{Min: tls.VersionTLS12}, ""},
+		{`{"max":"tls1.1"}`, TLSVersions{Max: tls.VersionTLS11}, ""},
+		{`{"min":"tls1.3","max":"tls1.0"}`, TLSVersions{},
+			"invalid TLS version range: min (tls1.3) is higher than max (tls1.0)"},
+		{`{"min":"tls1.2","max":"tls1.1"}`, TLSVersions{},
+			"invalid TLS version range: min (tls1.2) is higher than max (tls1.1)"},
+		{`{"min":"tls9.9"}`, TLSVersions{}, "unknown TLS version: tls9.9"},
+		{`"tls1.2+"`, TLSVersions{Min: tls.VersionTLS12}, ""},
+		{`"tls1.3+"`, TLSVersions{Min: TLSVersion13}, ""},
+		{`"tls9.9+"`, TLSVersions{}, "unknown TLS version: tls9.9"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.json, func(t *testing.T) {
+			var v TLSVersions
+			err := json.Unmarshal([]byte(tc.json), &v)
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.exp, v)
+		})
+	}
+}
+
+func TestTLSVersionsMarshalJSON(t *testing.T) {
+	testCases := []struct {
+		v   TLSVersions
+		exp string
+	}{
+		{TLSVersions{Min: tls.VersionTLS12}, `"tls1.2+"`},
+		{TLSVersions{Min: tls.VersionTLS10, Max: tls.VersionTLS12}, `{"min":"tls1.0","max":"tls1.2"}`},
+		{TLSVersions{Max: tls.VersionTLS12}, `{"min":"","max":"tls1.2"}`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.exp, func(t *testing.T) {
+			data, err := json.Marshal(tc.v)
+			require.NoError(t, err)
+			assert.Equal(t, tc.exp, string(data))
+
+			var v TLSVersions
+			require.NoError(t, json.Unmarshal(data, &v))
+			assert.Equal(t, tc.v, v)
+		})
+	}
+}
+
+func TestTLSAuthFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"certs/client.crt": {Data: []byte(testClientCert)},
+		"certs/client.key": {Data: []byte(testClientKey)},
+	}
+
+	testCases := map[string]string{
+		"Files":       `{"certFile":"certs/client.crt","keyFile":"certs/client.key"}`,
+		"Mixed":       `{"certFile":"certs/client.crt","key":` + strconv.Quote(testClientKey) + `}`,
+		"Inline wins": `{"cert":` + strconv.Quote(testClientCert) + `,"certFile":"nope.crt","keyFile":"certs/client.key"}`,
+	}
+	for name, data := range testCases {
+		data := data
+		t.Run(name, func(t *testing.T) {
+			var auth TLSAuth
+			require.NoError(t, json.Unmarshal([]byte(data), &auth))
+			cert, err := auth.CertificateFS(fsys)
+			require.NoError(t, err)
+			assert.NotNil(t, cert)
+		})
+	}
+
+	var auth TLSAuth
+	require.NoError(t, json.Unmarshal([]byte(`{"certFile":"nope.crt","keyFile":"certs/client.key"}`), &auth))
+	_, err := auth.CertificateFS(fsys)
+	assert.Error(t, err)
+
+	// no file system, no files
+	auth = TLSAuth{}
+	require.NoError(t, json.Unmarshal([]byte(testCases["Files"]), &auth))
+	_, err = auth.Certificate()
+	assert.Error(t, err)
+}
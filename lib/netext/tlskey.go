@@ -0,0 +1,185 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"  // nolint: gosec
+	"crypto/sha1" // nolint: gosec
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"hash"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrKeyPasswordRequired is returned when a TLSAuth private key is encrypted
+// but no password is given.
+var ErrKeyPasswordRequired = errors.New("the private key is encrypted, but no password was given")
+
+// nolint: gochecknoglobals
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPEMKey decrypts the encrypted blocks of a PEM-encoded private key,
+// both the PKCS#8 "ENCRYPTED PRIVATE KEY" blocks and the legacy blocks with a
+// "Proc-Type: 4,ENCRYPTED" header. The key is returned unchanged when it's
+// not encrypted.
+func decryptPEMKey(key []byte, password string) ([]byte, error) {
+	var out []byte
+	encrypted := false
+	for rest := key; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+
+		// nolint: staticcheck
+		if block.Type == "ENCRYPTED PRIVATE KEY" || x509.IsEncryptedPEMBlock(block) {
+			if password == "" {
+				return nil, ErrKeyPasswordRequired
+			}
+			encrypted = true
+
+			var der []byte
+			var err error
+			if block.Type == "ENCRYPTED PRIVATE KEY" {
+				der, err = decryptPKCS8(block.Bytes, []byte(password))
+				block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+			} else {
+				der, err = x509.DecryptPEMBlock(block, []byte(password)) // nolint: staticcheck
+				block = &pem.Block{Type: block.Type, Bytes: der}
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "couldn't decrypt the private key")
+			}
+		}
+		out = append(out, pem.EncodeToMemory(block)...)
+	}
+
+	if !encrypted {
+		return key, nil
+	}
+	return out, nil
+}
+
+// decryptPKCS8 decrypts a PKCS#8 EncryptedPrivateKeyInfo, only the PBES2
+// scheme with PBKDF2 and AES or 3DES in CBC mode is supported.
+func decryptPKCS8(data, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, errors.Errorf("unsupported encryption scheme: %s", info.Algo.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, errors.Errorf("unsupported key derivation function: %s", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, err
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdfParams.PRF.Algorithm) == 0, kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, errors.Errorf("unsupported pseudorandom function: %s", kdfParams.PRF.Algorithm)
+	}
+
+	var keyLen int
+	var newCipher func(key []byte) (cipher.Block, error)
+	switch algo := params.EncryptionScheme.Algorithm; {
+	case algo.Equal(oidAES128CBC):
+		keyLen, newCipher = 16, aes.NewCipher
+	case algo.Equal(oidAES192CBC):
+		keyLen, newCipher = 24, aes.NewCipher
+	case algo.Equal(oidAES256CBC):
+		keyLen, newCipher = 32, aes.NewCipher
+	case algo.Equal(oidDESEDE3CBC):
+		keyLen, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, errors.Errorf("unsupported cipher: %s", algo)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+
+	block, err := newCipher(pbkdf2.Key(password, kdfParams.Salt, kdfParams.IterationCount, keyLen, prf))
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(info.EncryptedData) == 0 ||
+		len(info.EncryptedData)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted data")
+	}
+	der := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(der, info.EncryptedData)
+
+	// the PKCS#7 padding is the only check of the password we get
+	padding := int(der[len(der)-1])
+	if padding == 0 || padding > block.BlockSize() || padding > len(der) ||
+		!bytes.Equal(der[len(der)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, x509.IncorrectPasswordError
+	}
+	return der[:len(der)-padding], nil
+}
@@ -23,8 +23,10 @@ package lib
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"reflect"
 
+	"golang.org/x/time/rate"
 	null "gopkg.in/guregu/null.v3"
 
 	"github.com/runner-mei/gojs/lib/netext"
@@ -45,6 +47,13 @@ type Options struct {
 	// Limit HTTP requests per second.
 	RPS null.Int `json:"rps" envconfig:"K6_RPS"`
 
+	// How many HTTP requests can be sent at once after an idle period, 1 by default.
+	RPSBurst null.Int `json:"rpsBurst" envconfig:"K6_RPS_BURST"`
+
+	// Limiter shared by the states of several VUs, so they are throttled against
+	// a single RPS budget. RPS and RPSBurst are ignored when it's set.
+	RPSLimiter *rate.Limiter `json:"-" ignored:"true"`
+
 	// DNS handling configuration.
 	DNS types.DNSConfig `json:"dns" envconfig:"K6_DNS"`
 
@@ -61,6 +70,10 @@ type Options struct {
 	// Should all HTTP requests and responses be logged (excluding body)?
 	HTTPDebug null.String `json:"httpDebug" envconfig:"K6_HTTP_DEBUG"`
 
+	// Maximum number of bytes of the request bodies logged by HTTPDebug "full",
+	// DefaultHTTPDebugBodyLimit by default.
+	HTTPDebugBodyLimit null.Int `json:"httpDebugBodyLimit" envconfig:"K6_HTTP_DEBUG_BODY_LIMIT"`
+
 	// Accept invalid or untrusted TLS certificates.
 	InsecureSkipTLSVerify null.Bool `json:"insecureSkipTLSVerify" envconfig:"K6_INSECURE_SKIP_TLS_VERIFY"`
 
@@ -69,6 +82,15 @@ type Options struct {
 	TLSVersion      *netext.TLSVersions     `json:"tlsVersion" ignored:"true"`
 	TLSAuth         []*netext.TLSAuth       `json:"tlsAuth" envconfig:"K6_TLSAUTH"`
 
+	// File system the certFile and keyFile of TLSAuth are read from, usually the
+	// FileSystem of the InitEnvironment. The files can't be used when it's nil.
+	TLSAuthFS fs.FS `json:"-" ignored:"true"`
+
+	// Warn about the client certificates expiring within this duration, 7 days by default,
+	// and fail instead of warning when TLSAuthExpiryError is set.
+	TLSAuthExpiryThreshold types.NullDuration `json:"tlsAuthExpiryThreshold" envconfig:"K6_TLSAUTH_EXPIRY_THRESHOLD"`
+	TLSAuthExpiryError     null.Bool          `json:"tlsAuthExpiryError" envconfig:"K6_TLSAUTH_EXPIRY_ERROR"`
+
 	// Throw warnings (eg. failed HTTP requests) as errors instead of simply logging them.
 	Throw null.Bool `json:"throw" envconfig:"K6_THROW"`
 
@@ -83,12 +105,21 @@ type Options struct {
 	// Block hostname patterns that tests may not contact.
 	BlockedHostnames types.NullHostnameTrie `json:"blockHostnames" envconfig:"K6_BLOCK_HOSTNAMES"`
 
+	// Block the ports that tests may not contact, whatever the host.
+	BlockedPorts []int `json:"blockPorts" envconfig:"K6_BLOCK_PORTS"`
+
 	// Hosts overrides dns entries for given hosts
 	Hosts map[string]*netext.HostAddress `json:"hosts" envconfig:"K6_HOSTS"`
 
+	// HTTP transport and dialer settings, the defaults are used for the unset ones.
+	Transport types.TransportConfig `json:"transport" ignored:"true"`
+
 	// Disable keep-alive connections
 	NoConnectionReuse null.Bool `json:"noConnectionReuse" envconfig:"K6_NO_CONNECTION_REUSE"`
 
+	// Force HTTP/1.1, by not negotiating HTTP/2 with the servers
+	DisableHTTP2 null.Bool `json:"disableHTTP2" envconfig:"K6_DISABLE_HTTP2"`
+
 	// These values are for third party collectors' benefit.
 	// Can't be set through env vars.
 	External map[string]json.RawMessage `json:"ext" ignored:"true"`
@@ -124,6 +155,10 @@ type Options struct {
 
 // Returns the result of overwriting any fields with any that are set on the argument.
 //
+// The Hosts, Thresholds and External maps are merged key by key instead: the keys of the
+// argument win and the other keys are kept, while a key with a nil host, no thresholds or
+// a null external value is removed. See ApplyReplacingMaps for replacing them wholesale.
+//
 // Example:
 //   a := Options{VUs: null.IntFrom(10), VUsMax: null.IntFrom(10)}
 //   b := Options{VUs: null.IntFrom(5)}
@@ -132,6 +167,12 @@ func (o Options) Apply(opts Options) Options {
 	if opts.RPS.Valid {
 		o.RPS = opts.RPS
 	}
+	if opts.RPSBurst.Valid {
+		o.RPSBurst = opts.RPSBurst
+	}
+	if opts.RPSLimiter != nil {
+		o.RPSLimiter = opts.RPSLimiter
+	}
 	if opts.MaxRedirects.Valid {
 		o.MaxRedirects = opts.MaxRedirects
 	}
@@ -147,6 +188,9 @@ func (o Options) Apply(opts Options) Options {
 	if opts.HTTPDebug.Valid {
 		o.HTTPDebug = opts.HTTPDebug
 	}
+	if opts.HTTPDebugBodyLimit.Valid {
+		o.HTTPDebugBodyLimit = opts.HTTPDebugBodyLimit
+	}
 	if opts.InsecureSkipTLSVerify.Valid {
 		o.InsecureSkipTLSVerify = opts.InsecureSkipTLSVerify
 	}
@@ -162,11 +206,20 @@ func (o Options) Apply(opts Options) Options {
 	if opts.TLSAuth != nil {
 		o.TLSAuth = opts.TLSAuth
 	}
+	if opts.TLSAuthFS != nil {
+		o.TLSAuthFS = opts.TLSAuthFS
+	}
+	if opts.TLSAuthExpiryThreshold.Valid {
+		o.TLSAuthExpiryThreshold = opts.TLSAuthExpiryThreshold
+	}
+	if opts.TLSAuthExpiryError.Valid {
+		o.TLSAuthExpiryError = opts.TLSAuthExpiryError
+	}
 	if opts.Throw.Valid {
 		o.Throw = opts.Throw
 	}
 	if opts.Thresholds != nil {
-		o.Thresholds = opts.Thresholds
+		o.Thresholds = mergeThresholds(o.Thresholds, opts.Thresholds)
 	}
 	if opts.BlacklistIPs != nil {
 		o.BlacklistIPs = opts.BlacklistIPs
@@ -174,12 +227,18 @@ func (o Options) Apply(opts Options) Options {
 	if opts.BlockedHostnames.Valid {
 		o.BlockedHostnames = opts.BlockedHostnames
 	}
+	if opts.BlockedPorts != nil {
+		o.BlockedPorts = opts.BlockedPorts
+	}
 	if opts.Hosts != nil {
-		o.Hosts = opts.Hosts
+		o.Hosts = mergeHosts(o.Hosts, opts.Hosts)
 	}
 	if opts.NoConnectionReuse.Valid {
 		o.NoConnectionReuse = opts.NoConnectionReuse
 	}
+	if opts.DisableHTTP2.Valid {
+		o.DisableHTTP2 = opts.DisableHTTP2
+	}
 	// if opts.NoVUConnectionReuse.Valid {
 	// 	o.NoVUConnectionReuse = opts.NoVUConnectionReuse
 	// }
@@ -190,7 +249,7 @@ func (o Options) Apply(opts Options) Options {
 		o.NoCookiesReset = opts.NoCookiesReset
 	}
 	if opts.External != nil {
-		o.External = opts.External
+		o.External = mergeExternal(o.External, opts.External)
 	}
 	if opts.SummaryTrendStats != nil {
 		o.SummaryTrendStats = opts.SummaryTrendStats
@@ -225,10 +284,82 @@ func (o Options) Apply(opts Options) Options {
 	if opts.DNS.Policy.Valid {
 		o.DNS.Policy = opts.DNS.Policy
 	}
+	if opts.DNS.DoH.Valid {
+		o.DNS.DoH = opts.DNS.DoH
+	}
+	if opts.DNS.DoHBootstrap != nil {
+		o.DNS.DoHBootstrap = opts.DNS.DoHBootstrap
+	}
+	o.Transport = o.Transport.Apply(opts.Transport)
 
 	return o
 }
 
+// ApplyReplacingMaps is like Apply, but the Hosts, Thresholds and External maps set on the
+// argument replace the ones of o as a whole, instead of being merged into them.
+func (o Options) ApplyReplacingMaps(opts Options) Options {
+	if opts.Thresholds != nil {
+		o.Thresholds = nil
+	}
+	if opts.Hosts != nil {
+		o.Hosts = nil
+	}
+	if opts.External != nil {
+		o.External = nil
+	}
+	return o.Apply(opts)
+}
+
+// mergeHosts returns a copy of dst with the hosts of src set, a nil host removes the key.
+func mergeHosts(dst, src map[string]*netext.HostAddress) map[string]*netext.HostAddress {
+	merged := make(map[string]*netext.HostAddress, len(dst)+len(src))
+	for k, v := range dst {
+		merged[k] = v
+	}
+	for k, v := range src {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// mergeThresholds returns a copy of dst with the thresholds of src set, a value without
+// any threshold removes the key.
+func mergeThresholds(dst, src map[string]stats.Thresholds) map[string]stats.Thresholds {
+	merged := make(map[string]stats.Thresholds, len(dst)+len(src))
+	for k, v := range dst {
+		merged[k] = v
+	}
+	for k, v := range src {
+		if len(v.Thresholds) == 0 {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// mergeExternal returns a copy of dst with the values of src set, an empty or null value
+// removes the key.
+func mergeExternal(dst, src map[string]json.RawMessage) map[string]json.RawMessage {
+	merged := make(map[string]json.RawMessage, len(dst)+len(src))
+	for k, v := range dst {
+		merged[k] = v
+	}
+	for k, v := range src {
+		if len(v) == 0 || string(v) == "null" {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
 // // Validate checks if all of the specified options make sense
 // func (o Options) Validate() []error {
 // 	// TODO: validate all of the other options... that we should have already been validating...
@@ -252,25 +383,46 @@ func (o Options) Apply(opts Options) Options {
 // }
 
 // ForEachSpecified enumerates all struct fields and calls the supplied function with each
-// element that is valid. It panics for any unfamiliar or unexpected fields, so make sure
-// new fields in Options are accounted for.
+// element that is valid. The nested config structs, like DNS, are recursed into and their
+// fields are reported with dotted keys, e.g. "dns.ttl". It panics for any unfamiliar or
+// unexpected fields, so make sure new fields in Options are accounted for.
 func (o Options) ForEachSpecified(structTag string, callback func(key string, value interface{})) {
-	structType := reflect.TypeOf(o)
-	structVal := reflect.ValueOf(o)
+	forEachSpecified(reflect.ValueOf(o), structTag, "", callback)
+}
+
+func forEachSpecified(structVal reflect.Value, structTag, prefix string, callback func(key string, value interface{})) {
+	structType := structVal.Type()
 	for i := 0; i < structType.NumField(); i++ {
 		fieldType := structType.Field(i)
 		fieldVal := structVal.Field(i)
 		value := fieldVal.Interface()
 
+		key, ok := fieldType.Tag.Lookup(structTag)
+		if !ok {
+			key = fieldType.Name
+		}
+		if prefix != "" {
+			if key == "-" {
+				continue
+			}
+			key = prefix + "." + key
+		}
+
 		shouldCall := false
 		switch fieldType.Type.Kind() {
 		case reflect.Struct:
+			// Nested config structs, like types.DNSConfig, have no Valid field of their own
+			if _, isNull := fieldType.Type.FieldByName("Valid"); !isNull {
+				forEachSpecified(fieldVal, structTag, key, callback)
+				continue
+			}
 			// Unpack any guregu/null values
 			shouldCall = fieldVal.FieldByName("Valid").Bool()
 			valOrZero := fieldVal.MethodByName("ValueOrZero")
 			if shouldCall && valOrZero.IsValid() {
 				value = valOrZero.Call([]reflect.Value{})[0].Interface()
-				if v, ok := value.(types.Duration); ok {
+				// The durations and the DNS enums are reported like in the JSON, e.g. "1m" or "onlyIPv4"
+				if v, ok := value.(fmt.Stringer); ok {
 					value = v.String()
 				}
 			}
@@ -278,18 +430,13 @@ func (o Options) ForEachSpecified(structTag string, callback func(key string, va
 			shouldCall = fieldVal.Len() > 0
 		case reflect.Map:
 			shouldCall = fieldVal.Len() > 0
-		case reflect.Ptr:
+		case reflect.Ptr, reflect.Interface:
 			shouldCall = !fieldVal.IsNil()
 		default:
 			panic(fmt.Sprintf("Unknown Options field %#v", fieldType))
 		}
 
 		if shouldCall {
-			key, ok := fieldType.Tag.Lookup(structTag)
-			if !ok {
-				key = fieldType.Name
-			}
-
 			callback(key, value)
 		}
 	}
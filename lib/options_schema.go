@@ -0,0 +1,251 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	null "gopkg.in/guregu/null.v3"
+
+	"github.com/runner-mei/gojs/lib/netext"
+	"github.com/runner-mei/gojs/lib/types"
+	"github.com/runner-mei/gojs/stats"
+)
+
+// jsonSchema is a JSON Schema (draft-07) node.
+type jsonSchema map[string]interface{}
+
+// OptionsJSONSchema returns a JSON Schema describing the JSON form of Options, i.e. what
+// Options' UnmarshalJSON accepts. The fields without a JSON form, like the `json:"-"`
+// ones, are left out. Every field is optional and nullable, like the null types.
+func OptionsJSONSchema() []byte {
+	schema := structSchema(reflect.TypeOf(Options{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "Options"
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// customTypeSchema returns the schema of t if it has a custom JSON form.
+func customTypeSchema(t reflect.Type) (jsonSchema, bool) {
+	switch t {
+	case reflect.TypeOf(null.Bool{}):
+		return jsonSchema{"type": []string{"boolean", "null"}}, true
+	case reflect.TypeOf(null.Int{}):
+		return jsonSchema{"type": []string{"integer", "null"}}, true
+	case reflect.TypeOf(null.Float{}):
+		return jsonSchema{"type": []string{"number", "null"}}, true
+	case reflect.TypeOf(null.String{}):
+		return jsonSchema{"type": []string{"string", "null"}}, true
+	// A duration is either a string, e.g. "1m30s" or "2d", or a number of milliseconds.
+	case reflect.TypeOf(types.Duration(0)):
+		return jsonSchema{"type": []string{"string", "number"}}, true
+	case reflect.TypeOf(types.NullDuration{}):
+		return jsonSchema{"type": []string{"string", "number", "null"}}, true
+	case reflect.TypeOf(types.NullDNSPolicy{}):
+		return nullable(enumSchema(dnsPolicyNames())), true
+	case reflect.TypeOf(types.NullDNSSelect{}):
+		return nullable(enumSchema(dnsSelectNames())), true
+	case reflect.TypeOf(types.NullHostnameTrie{}):
+		return nullable(jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}}), true
+	case reflect.TypeOf(types.NullIPPool{}):
+		return jsonSchema{"type": []string{"string", "null"}}, true
+	case reflect.TypeOf(netext.TLSVersion(0)):
+		return enumSchema(append([]string{""}, tlsVersionNames()...)), true
+	// A TLS version range is either an object, a single version or an open-ended range, e.g. "tls1.2+".
+	case reflect.TypeOf(netext.TLSVersions{}):
+		versions := tlsVersionNames()
+		names := make([]string, 0, 2*len(versions))
+		for _, name := range versions {
+			names = append(names, name, name+"+")
+		}
+		return jsonSchema{"anyOf": []jsonSchema{
+			enumSchema(names),
+			structSchema(reflect.TypeOf(netext.TLSVersionsFields{})),
+		}}, true
+	case reflect.TypeOf(netext.TLSCipherSuites{}):
+		names := make([]string, 0, len(netext.SupportedTLSCipherSuites))
+		for name := range netext.SupportedTLSCipherSuites {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return jsonSchema{"type": "array", "items": enumSchema(names)}, true
+	case reflect.TypeOf(netext.TLSAuth{}):
+		// The password is only read, see TLSAuthFields.Password.
+		schema := structSchema(reflect.TypeOf(netext.TLSAuthFields{}))
+		schema["properties"].(jsonSchema)["password"] = jsonSchema{"type": "string"}
+		return schema, true
+	case reflect.TypeOf(netext.IPNet{}), reflect.TypeOf(netext.HostAddress{}):
+		return jsonSchema{"type": "string"}, true
+	// A threshold is either its source or an object with the abort settings.
+	case reflect.TypeOf(stats.Thresholds{}):
+		return jsonSchema{"type": "array", "items": jsonSchema{"anyOf": []jsonSchema{
+			{"type": "string"},
+			{
+				"type": "object",
+				"properties": jsonSchema{
+					"threshold":      jsonSchema{"type": "string"},
+					"abortOnFail":    jsonSchema{"type": "boolean"},
+					"delayAbortEval": jsonSchema{"type": []string{"string", "number", "null"}},
+				},
+				"required": []string{"threshold"},
+			},
+		}}}, true
+	case reflect.TypeOf(stats.SystemTagSet(0)):
+		values := stats.SystemTagSetValues()
+		names := make([]string, len(values))
+		for i, v := range values {
+			names[i] = v.String()
+		}
+		return jsonSchema{"type": "array", "items": enumSchema(names)}, true
+	case reflect.TypeOf(stats.SampleTags{}):
+		return jsonSchema{"type": "object", "additionalProperties": jsonSchema{"type": "string"}}, true
+	case reflect.TypeOf(json.RawMessage{}):
+		return jsonSchema{}, true
+	default:
+		return nil, false
+	}
+}
+
+// typeSchema returns the schema of the JSON form of t. It panics for the kinds without
+// a JSON form, so the new types in Options must be accounted for.
+func typeSchema(t reflect.Type) jsonSchema {
+	if schema, ok := customTypeSchema(t); ok {
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(typeSchema(t.Elem()))
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return jsonSchema{"type": "number"}
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return nullable(jsonSchema{"type": "array", "items": typeSchema(t.Elem())})
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			panic(fmt.Sprintf("unsupported map key type %s", t.Key()))
+		}
+		return nullable(jsonSchema{"type": "object", "additionalProperties": typeSchema(t.Elem())})
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		panic(fmt.Sprintf("unsupported type %s", t))
+	}
+}
+
+// structSchema returns the schema of a struct without a custom JSON form, its exported
+// fields are named after their JSON tags, like encoding/json does.
+func structSchema(t reflect.Type) jsonSchema {
+	properties := jsonSchema{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tag = strings.Split(tag, ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		properties[name] = typeSchema(field.Type)
+	}
+	return jsonSchema{"type": "object", "properties": properties}
+}
+
+// nullable returns schema also accepting null.
+func nullable(schema jsonSchema) jsonSchema {
+	switch typ := schema["type"].(type) {
+	case string:
+		schema["type"] = []string{typ, "null"}
+		if enum, ok := schema["enum"].([]interface{}); ok {
+			schema["enum"] = append(enum, nil)
+		}
+		return schema
+	case []string:
+		for _, t := range typ {
+			if t == "null" {
+				return schema
+			}
+		}
+		schema["type"] = append(typ, "null")
+		return schema
+	default:
+		if len(schema) == 0 {
+			return schema
+		}
+		return jsonSchema{"anyOf": []jsonSchema{schema, {"type": "null"}}}
+	}
+}
+
+func enumSchema(names []string) jsonSchema {
+	enum := make([]interface{}, len(names))
+	for i, name := range names {
+		enum[i] = name
+	}
+	return jsonSchema{"type": "string", "enum": enum}
+}
+
+func tlsVersionNames() []string {
+	names := make([]string, 0, len(netext.SupportedTLSVersions))
+	for name := range netext.SupportedTLSVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func dnsPolicyNames() []string {
+	values := types.DNSPolicyValues()
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = v.String()
+	}
+	return names
+}
+
+func dnsSelectNames() []string {
+	values := types.DNSSelectValues()
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = v.String()
+	}
+	return names
+}
@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsJSONSchema(t *testing.T) {
+	var schema struct {
+		Type       string                     `json:"type"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(OptionsJSONSchema(), &schema))
+	assert.Equal(t, "object", schema.Type)
+
+	typ := reflect.TypeOf(Options{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			assert.NotContains(t, schema.Properties, typ.Field(i).Name)
+			continue
+		}
+		assert.Contains(t, schema.Properties, name)
+	}
+
+	assert.JSONEq(t, `{"type": ["integer", "null"]}`, string(schema.Properties["rps"]))
+	assert.JSONEq(t, `{"type": ["string", "number", "null"]}`, string(schema.Properties["tlsAuthExpiryThreshold"]))
+
+	var tlsVersion struct {
+		AnyOf []struct {
+			Type       interface{}              `json:"type"`
+			Enum       []interface{}            `json:"enum"`
+			Properties map[string]interface{}   `json:"properties"`
+			AnyOf      []map[string]interface{} `json:"anyOf"`
+		} `json:"anyOf"`
+	}
+	require.NoError(t, json.Unmarshal(schema.Properties["tlsVersion"], &tlsVersion))
+	require.Len(t, tlsVersion.AnyOf, 2)
+	versions := tlsVersion.AnyOf[0].AnyOf
+	require.Len(t, versions, 2)
+	assert.Contains(t, versions[0]["enum"], "tls1.2")
+	assert.Contains(t, versions[0]["enum"], "tls1.2+")
+	assert.Contains(t, versions[1]["properties"], "min")
+	assert.Contains(t, versions[1]["properties"], "max")
+	assert.Equal(t, "null", tlsVersion.AnyOf[1].Type)
+
+	var dns struct {
+		Properties map[string]struct {
+			Enum []interface{} `json:"enum"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(schema.Properties["dns"], &dns))
+	assert.Contains(t, dns.Properties["policy"].Enum, "onlyIPv4")
+	assert.Contains(t, dns.Properties["select"].Enum, "roundRobin")
+
+	var systemTags struct {
+		Items struct {
+			Enum []string `json:"enum"`
+		} `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(schema.Properties["systemTags"], &systemTags))
+	assert.Contains(t, systemTags.Items.Enum, "status")
+}
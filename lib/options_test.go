@@ -27,6 +27,7 @@ import (
 	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/stretchr/testify/assert"
@@ -240,6 +241,15 @@ func TestOptions(t *testing.T) {
 			jsonStr := `{"tlsAuth":[{"Cert":""}]}`
 			assert.Error(t, json.Unmarshal([]byte(jsonStr), &opts))
 		})
+
+		t.Run("No password", func(t *testing.T) {
+			auth := *tlsAuth[0]
+			auth.Password = "secret"
+			data, err := json.Marshal(Options{TLSAuth: []*netext.TLSAuth{&auth}})
+			require.NoError(t, err)
+			assert.NotContains(t, string(data), "password")
+			assert.NotContains(t, string(data), "secret")
+		})
 	})
 	t.Run("NoConnectionReuse", func(t *testing.T) {
 		opts := Options{}.Apply(Options{NoConnectionReuse: null.BoolFrom(true)})
@@ -277,6 +287,12 @@ func TestOptions(t *testing.T) {
 		assert.NotNil(t, opts.BlockedHostnames)
 		assert.Equal(t, blockedHostnames, opts.BlockedHostnames)
 	})
+	t.Run("BlockedPorts", func(t *testing.T) {
+		opts := Options{}.Apply(Options{BlockedPorts: []int{22, 3306}})
+		assert.Equal(t, []int{22, 3306}, opts.BlockedPorts)
+		opts = opts.Apply(Options{})
+		assert.Equal(t, []int{22, 3306}, opts.BlockedPorts)
+	})
 
 	t.Run("Hosts", func(t *testing.T) {
 		host, err := netext.NewHostAddress(net.ParseIP("192.0.2.1"), "80")
@@ -311,6 +327,37 @@ func TestOptions(t *testing.T) {
 		assert.Equal(t, ext, opts.External)
 	})
 
+	t.Run("MergeMaps", func(t *testing.T) {
+		host1, err := netext.NewHostAddress(net.ParseIP("192.0.2.1"), "80")
+		require.NoError(t, err)
+		host2, err := netext.NewHostAddress(net.ParseIP("192.0.2.2"), "80")
+		require.NoError(t, err)
+		thresholds := stats.Thresholds{Thresholds: []*stats.Threshold{{}}}
+
+		base := Options{
+			Hosts:      map[string]*netext.HostAddress{"a.test": host1, "b.test": host1},
+			Thresholds: map[string]stats.Thresholds{"a": thresholds, "b": thresholds},
+			External:   map[string]json.RawMessage{"a": json.RawMessage("1"), "b": json.RawMessage("2")},
+		}
+		override := Options{
+			Hosts:      map[string]*netext.HostAddress{"a.test": host2, "b.test": nil, "c.test": host2},
+			Thresholds: map[string]stats.Thresholds{"b": {}, "c": thresholds},
+			External:   map[string]json.RawMessage{"a": json.RawMessage("3"), "b": json.RawMessage("null"), "c": json.RawMessage("4")},
+		}
+
+		opts := base.Apply(override)
+		assert.Equal(t, map[string]*netext.HostAddress{"a.test": host2, "c.test": host2}, opts.Hosts)
+		assert.Equal(t, map[string]stats.Thresholds{"a": thresholds, "c": thresholds}, opts.Thresholds)
+		assert.Equal(t, map[string]json.RawMessage{"a": json.RawMessage("3"), "c": json.RawMessage("4")}, opts.External)
+		assert.Len(t, base.Hosts, 2, "the maps of the receiver must not be modified")
+
+		opts = base.ApplyReplacingMaps(Options{
+			Hosts: map[string]*netext.HostAddress{"c.test": host2},
+		})
+		assert.Equal(t, map[string]*netext.HostAddress{"c.test": host2}, opts.Hosts)
+		assert.Equal(t, base.Thresholds, opts.Thresholds)
+	})
+
 	t.Run("JSON", func(t *testing.T) {
 		data, err := json.Marshal(Options{})
 		assert.NoError(t, err)
@@ -371,6 +418,32 @@ func TestOptions(t *testing.T) {
 	})
 }
 
+func TestOptionsForEachSpecified(t *testing.T) {
+	opts := Options{
+		RPS: null.IntFrom(10),
+		DNS: types.DNSConfig{
+			TTL:    null.StringFrom("1m"),
+			Select: types.NullDNSSelect{DNSSelect: types.DNSroundRobin, Valid: true},
+			Policy: types.NullDNSPolicy{DNSPolicy: types.DNSonlyIPv4, Valid: true},
+		},
+		Transport: types.TransportConfig{
+			DialTimeout: types.NullDurationFrom(time.Second),
+		},
+	}
+
+	specified := map[string]interface{}{}
+	opts.ForEachSpecified("json", func(key string, value interface{}) {
+		specified[key] = value
+	})
+	assert.Equal(t, map[string]interface{}{
+		"rps":                   int64(10),
+		"dns.ttl":               "1m",
+		"dns.select":            "roundRobin",
+		"dns.policy":            "onlyIPv4",
+		"transport.dialTimeout": "1s",
+	}, specified)
+}
+
 func TestOptionsEnv(t *testing.T) {
 	mustIPPool := func(s string) *types.IPPool {
 		p, err := types.NewIPPool(s)
@@ -472,6 +545,49 @@ func TestOptionsEnv(t *testing.T) {
 	}
 }
 
+func TestOptionsFromEnv(t *testing.T) {
+	env := map[string]string{
+		"K6_RPS":                      "10",
+		"K6_INSECURE_SKIP_TLS_VERIFY": "true",
+		"K6_TLSAUTH_EXPIRY_THRESHOLD": "48h",
+		"K6_TLS_CIPHER_SUITES":        "TLS_RSA_WITH_AES_128_GCM_SHA256,TLS_RSA_WITH_AES_256_GCM_SHA384",
+		"K6_BLACKLIST_IPS":            "10.0.0.0/8,192.168.0.0/16",
+		"K6_BLOCK_PORTS":              "22,3306",
+		"K6_DNS":                      "ttl=1m,policy=onlyIPv4,dohBootstrap={1.1.1.1,::1}",
+		"K6_SYSTEM_TAGS":              "status,method",
+		"K6_SUMMARY_TREND_STATS":      "avg,p(99)",
+		"K6_THRESHOLDS":               `{"http_req_duration": ["p(95)<500"]}`,
+		"K6_TRANSPORT":                "ignored",
+	}
+	opts, err := OptionsFromEnv(func(key string) string { return env[key] })
+	require.NoError(t, err)
+
+	assert.Equal(t, null.IntFrom(10), opts.RPS)
+	assert.Equal(t, null.BoolFrom(true), opts.InsecureSkipTLSVerify)
+	assert.Equal(t, types.NullDurationFrom(48*time.Hour), opts.TLSAuthExpiryThreshold)
+	assert.Equal(t, &netext.TLSCipherSuites{tls.TLS_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_256_GCM_SHA384}, opts.TLSCipherSuites)
+	if assert.Len(t, opts.BlacklistIPs, 2) {
+		assert.Equal(t, "10.0.0.0/8", opts.BlacklistIPs[0].String())
+		assert.Equal(t, "192.168.0.0/16", opts.BlacklistIPs[1].String())
+	}
+	assert.Equal(t, []int{22, 3306}, opts.BlockedPorts)
+	assert.Equal(t, null.StringFrom("1m"), opts.DNS.TTL)
+	assert.Equal(t, types.NullDNSPolicy{DNSPolicy: types.DNSonlyIPv4, Valid: true}, opts.DNS.Policy)
+	assert.Equal(t, []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("::1")}, opts.DNS.DoHBootstrap)
+	assert.Equal(t, stats.NewSystemTagSet(stats.TagStatus, stats.TagMethod), opts.SystemTags)
+	assert.Equal(t, []string{"avg", "p(99)"}, opts.SummaryTrendStats)
+	assert.Len(t, opts.Thresholds["http_req_duration"].Thresholds, 1)
+	assert.False(t, opts.NoConnectionReuse.Valid)
+
+	_, err = OptionsFromEnv(func(key string) string {
+		if key == "K6_RPS" {
+			return "many"
+		}
+		return ""
+	})
+	assert.Contains(t, err.Error(), "invalid K6_RPS")
+}
+
 func TestCIDRUnmarshal(t *testing.T) {
 	testData := []struct {
 		input          string
@@ -515,6 +631,44 @@ func TestCIDRUnmarshal(t *testing.T) {
 	}
 }
 
+func TestHostAddressMarshalZone(t *testing.T) {
+	for input, expected := range map[string]string{
+		"fe80::1%eth0":      "[fe80::1%eth0]:0",
+		"[fe80::1%eth0]:80": "[fe80::1%eth0]:80",
+	} {
+		host := &netext.HostAddress{}
+		require.NoError(t, host.UnmarshalText([]byte(input)))
+
+		data, err := host.MarshalText()
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(data))
+	}
+}
+
+func TestCIDRMarshalRoundTrip(t *testing.T) {
+	for _, cidr := range []string{
+		"192.168.0.0/24",
+		"10.0.0.1/32",
+		"fc00:1234:5678::/48",
+		"2001:db8::68/128",
+	} {
+		cidr := cidr
+		t.Run(cidr, func(t *testing.T) {
+			ipNet, err := netext.ParseCIDR(cidr)
+			require.NoError(t, err)
+
+			data, err := json.Marshal([]*netext.IPNet{ipNet})
+			require.NoError(t, err)
+			assert.Equal(t, `["`+cidr+`"]`, string(data))
+
+			var blacklist []*netext.IPNet
+			require.NoError(t, json.Unmarshal(data, &blacklist))
+			require.Len(t, blacklist, 1)
+			assert.Equal(t, ipNet, blacklist[0])
+		})
+	}
+}
+
 func TestHostAddressUnmarshal(t *testing.T) {
 	testData := []struct {
 		input          string
@@ -556,6 +710,21 @@ func TestHostAddressUnmarshal(t *testing.T) {
 			nil,
 			"strconv.Atoi: parsing \"asdf\": invalid syntax",
 		},
+		{
+			"fe80::1%eth0",
+			&netext.HostAddress{IP: net.ParseIP("fe80::1"), Zone: "eth0"},
+			"",
+		},
+		{
+			"[fe80::1%eth0]:80",
+			&netext.HostAddress{IP: net.ParseIP("fe80::1"), Port: 80, Zone: "eth0"},
+			"",
+		},
+		{
+			"1.2.3.4%eth0",
+			nil,
+			"invalid IP address: 1.2.3.4%eth0",
+		},
 	}
 
 	for _, data := range testData {
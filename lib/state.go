@@ -23,10 +23,13 @@ package lib
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/oxtoacart/bpool"
@@ -45,6 +48,10 @@ type DialContexter interface {
 }
 
 // State provides the volatile state for a VU.
+//
+// A State must not be copied once it's used, since it holds the lock of its Tags,
+// go vet reports the copies. The States are passed around as pointers, a new VU
+// gets a new State from NewState or a new literal.
 type State struct {
 	// Global options.
 	Options Options
@@ -58,6 +65,12 @@ type State struct {
 	CookieJar *cookiejar.Jar
 	TLSConfig *tls.Config
 
+	// FreshTransport is like Transport but never reuses a connection, it's used
+	// by the requests made with the noConnectionReuse param, so they open a new
+	// connection without disabling the keep-alives of the others. It's the
+	// Transport itself when Options.NoConnectionReuse disables them all.
+	FreshTransport http.RoundTripper
+
 	// Rate limits.
 	RPSLimit *rate.Limiter
 
@@ -68,11 +81,27 @@ type State struct {
 	// TODO: maybe use https://golang.org/pkg/sync/#Pool ?
 	BPool *bpool.BufferPool
 
-	Tags map[string]string
+	// Tags added to the samples of the VU. Use SetTag, DeleteTag and CloneTags
+	// once the state is shared between goroutines, they are guarded by a lock.
+	Tags   map[string]string
+	tagsMu sync.RWMutex
+
+	// RequestTagger, when set, returns tags added to the samples of every outbound
+	// HTTP request, redirects included, e.g. a trace ID. Its tags override the Tags
+	// of the VU and the tags of the request params, and the enabled system tags,
+	// e.g. method or status, override all of them.
+	RequestTagger func(*http.Request) map[string]string
+
+	// Warnings about the client certificates of Options.TLSAuth which are
+	// expired or expire soon, for the caller to log.
+	CertWarnings []*CertExpiryWarning
 }
 
 // CloneTags makes a copy of the tags map and returns it.
 func (s *State) CloneTags() map[string]string {
+	s.tagsMu.RLock()
+	defer s.tagsMu.RUnlock()
+
 	tags := make(map[string]string, len(s.Tags))
 	for k, v := range s.Tags {
 		tags[k] = v
@@ -80,6 +109,69 @@ func (s *State) CloneTags() map[string]string {
 	return tags
 }
 
+// SetTag sets the tag with the given key.
+func (s *State) SetTag(key, value string) {
+	s.tagsMu.Lock()
+	defer s.tagsMu.Unlock()
+
+	if s.Tags == nil {
+		s.Tags = make(map[string]string)
+	}
+	s.Tags[key] = value
+}
+
+// DeleteTag removes the tag with the given key.
+func (s *State) DeleteTag(key string) {
+	s.tagsMu.Lock()
+	defer s.tagsMu.Unlock()
+
+	delete(s.Tags, key)
+}
+
+// ResetCookies replaces the cookie jar with an empty one, unless
+// Options.NoCookiesReset is set. The hosts running the scripts in iterations
+// should call it before each iteration, the cookies are kept for the whole
+// life of the State otherwise. The jars obtained by the scripts before the
+// reset, e.g. with http.cookieJar(), keep the old cookies.
+func (s *State) ResetCookies() error {
+	if s.Options.NoCookiesReset.Bool {
+		return nil
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	s.CookieJar = jar
+	return nil
+}
+
+// NewRPSLimiter returns a limiter of rps requests per second, with the burst of
+// Options.RPSBurst. By default the burst is 1, i.e. the requests are evenly
+// spaced and don't catch up after an idle period.
+func NewRPSLimiter(rps float64, opts Options) *rate.Limiter {
+	burst := 1
+	if opts.RPSBurst.Valid && opts.RPSBurst.Int64 > 0 {
+		burst = int(opts.RPSBurst.Int64)
+	}
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+// SetRPSLimit changes the rate of the RPS limiter, which is shared with the
+// other states when it comes from Options.RPSLimiter. A rate of zero or less
+// removes the limit. The rate of an existing limiter can be changed while
+// requests are sent, but a state without a limiter gets a new one, which must
+// not happen concurrently with its requests.
+func (s *State) SetRPSLimit(rps float64) {
+	switch {
+	case s.RPSLimit != nil && rps > 0:
+		s.RPSLimit.SetLimit(rate.Limit(rps))
+	case s.RPSLimit != nil:
+		s.RPSLimit.SetLimit(rate.Inf)
+	case rps > 0:
+		s.RPSLimit = NewRPSLimiter(rps, s.Options)
+	}
+}
+
 func parseTTL(ttlS string) (time.Duration, error) {
 	ttl := time.Duration(0)
 	switch ttlS {
@@ -101,10 +193,22 @@ func parseTTL(ttlS string) (time.Duration, error) {
 	return ttl, nil
 }
 
-func NewState(logger log.Logger, opts Options) (*State, error) {
-	var rpsLimit *rate.Limiter
-	if rps := opts.RPS; rps.Valid {
-		rpsLimit = rate.NewLimiter(rate.Limit(rps.Int64), 1)
+// TransportWrapper wraps the HTTP transport built by NewState, e.g. to add
+// retries or to sign the requests.
+type TransportWrapper func(http.RoundTripper) http.RoundTripper
+
+// NewState creates the State for the given options. The wrappers are applied in
+// order to the HTTP transport, so the last one is the outermost. They're applied
+// once, the FreshTransport of the State goes through the same wrappers as its
+// Transport and only differs in the connection used under them. The transport
+// logging the requests and responses when Options.HTTPDebug is set comes
+// before them, so it logs what is actually sent to the servers. The requests of
+// httpext.MakeRequest are logged by MakeRequest instead, with their tags, see
+// NewHTTPDebugTransport.
+func NewState(logger log.Logger, opts Options, wrappers ...TransportWrapper) (*State, error) {
+	rpsLimit := opts.RPSLimiter
+	if rps := opts.RPS; rpsLimit == nil && rps.Valid {
+		rpsLimit = NewRPSLimiter(float64(rps.Int64), opts)
 	}
 
 	if opts.SystemTags == nil {
@@ -129,10 +233,21 @@ func NewState(logger log.Logger, opts Options) (*State, error) {
 	if err != nil {
 		return nil, err
 	}
-	resolver := netext.NewResolver(net.LookupIP,
-		ttl,
-		opts.DNS.Select.DNSSelect,
-		opts.DNS.Policy.DNSPolicy)
+	var resolver netext.Resolver
+	if opts.DNS.DoH.Valid {
+		resolver, err = netext.NewDoHResolver(opts.DNS.DoH.String, opts.DNS.DoHBootstrap,
+			ttl,
+			opts.DNS.Select.DNSSelect,
+			opts.DNS.Policy.DNSPolicy)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		resolver = netext.NewResolver(net.LookupIP,
+			ttl,
+			opts.DNS.Select.DNSSelect,
+			opts.DNS.Policy.DNSPolicy)
+	}
 
 	var cipherSuites []uint16
 	if opts.TLSCipherSuites != nil {
@@ -144,34 +259,73 @@ func NewState(logger log.Logger, opts Options) (*State, error) {
 		tlsVersions = *opts.TLSVersion
 	}
 
+	expiryThreshold := DefaultTLSAuthExpiryThreshold
+	if opts.TLSAuthExpiryThreshold.Valid {
+		expiryThreshold = time.Duration(opts.TLSAuthExpiryThreshold.Duration)
+	}
+
 	tlsAuth := opts.TLSAuth
 	certs := make([]tls.Certificate, len(tlsAuth))
 	nameToCert := make(map[string]*tls.Certificate)
+	var certWarnings []*CertExpiryWarning
+	// The index of the TLSAuth entry of every domain, a domain presented with two
+	// certificates is an error rather than a silent override by the last entry.
+	// The certificates are selected by the server name, which has no port.
+	domainAuth := make(map[string]int)
 	for i, auth := range tlsAuth {
+		if len(auth.Domains) == 0 {
+			continue
+		}
+		cert, err := auth.CertificateFS(opts.TLSAuthFS)
+		if err != nil {
+			return nil, err
+		}
+		certs[i] = *cert
 		for _, name := range auth.Domains {
-			cert, err := auth.Certificate()
-			if err != nil {
-				return nil, err
+			key := strings.ToLower(name)
+			if j, ok := domainAuth[key]; ok && j != i {
+				return nil, fmt.Errorf("the domain %q is in the tlsAuth entries %d and %d, "+
+					"only one client certificate can be presented to it", name, j, i)
 			}
-			certs[i] = *cert
+			domainAuth[key] = i
 			nameToCert[name] = &certs[i]
 		}
+
+		warning, err := checkCertExpiry(&certs[i], auth.Domains, expiryThreshold)
+		if err != nil {
+			return nil, err
+		}
+		if warning != nil {
+			if opts.TLSAuthExpiryError.Bool {
+				return nil, warning
+			}
+			certWarnings = append(certWarnings, warning)
+		}
+	}
+
+	transportConfig := types.DefaultTransportConfig().Apply(opts.Transport)
+	maxIdleConns, maxIdleConnsPerHost := opts.Batch.Int64, opts.BatchPerHost.Int64
+	if transportConfig.MaxIdleConns.Valid {
+		maxIdleConns = transportConfig.MaxIdleConns.Int64
+	}
+	if transportConfig.MaxIdleConnsPerHost.Valid {
+		maxIdleConnsPerHost = transportConfig.MaxIdleConnsPerHost.Int64
 	}
 
 	dialer := &netext.Dialer{
 		Dialer: net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
+			Timeout:   time.Duration(transportConfig.DialTimeout.Duration),
+			KeepAlive: time.Duration(transportConfig.KeepAlive.Duration),
 			DualStack: true,
 		},
 		Resolver:         resolver,
 		Blacklist:        opts.BlacklistIPs,
 		BlockedHostnames: opts.BlockedHostnames.Trie,
+		BlockedPorts:     opts.BlockedPorts,
 		Hosts:            opts.Hosts,
 	}
 	if opts.LocalIPs.Valid {
-		var ipIndex uint64 = 0
-		dialer.Dialer.LocalAddr = &net.TCPAddr{IP: opts.LocalIPs.Pool.GetIP(ipIndex)}
+		dialer.LocalIPs = opts.LocalIPs.Pool
 	}
 
 	tlsConfig := &tls.Config{
@@ -183,16 +337,41 @@ func NewState(logger log.Logger, opts Options) (*State, error) {
 		NameToCertificate:  nameToCert,
 		Renegotiation:      tls.RenegotiateFreelyAsClient,
 	}
-	transport := &http.Transport{
-		Proxy:               http.ProxyFromEnvironment,
-		TLSClientConfig:     tlsConfig,
-		DialContext:         dialer.DialContext,
-		DisableCompression:  true,
-		DisableKeepAlives:   opts.NoConnectionReuse.Bool,
-		MaxIdleConns:        int(opts.Batch.Int64),
-		MaxIdleConnsPerHost: int(opts.BatchPerHost.Int64),
+	newTransport := func(disableKeepAlives bool) *http.Transport {
+		transport := &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			TLSClientConfig:       tlsConfig,
+			DialContext:           dialer.DialContext,
+			DisableCompression:    transportConfig.DisableCompression.Bool,
+			DisableKeepAlives:     disableKeepAlives,
+			MaxIdleConns:          int(maxIdleConns),
+			MaxIdleConnsPerHost:   int(maxIdleConnsPerHost),
+			IdleConnTimeout:       time.Duration(transportConfig.IdleConnTimeout.Duration),
+			ResponseHeaderTimeout: time.Duration(transportConfig.ResponseHeaderTimeout.Duration),
+			ExpectContinueTimeout: time.Duration(transportConfig.ExpectContinueTimeout.Duration),
+		}
+		if opts.DisableHTTP2.Bool {
+			// a non-nil empty map prevents the transport from enabling HTTP/2 by itself
+			transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		} else {
+			_ = http2.ConfigureTransport(transport)
+		}
+		return transport
+	}
+	// The wrappers are only applied once, to a transport which picks the connections
+	// of the request, so they see the requests of FreshTransport as well.
+	var roundTripper http.RoundTripper = newTransport(opts.NoConnectionReuse.Bool)
+	if !opts.NoConnectionReuse.Bool {
+		roundTripper = connReuseTransport{keepAlive: roundTripper, fresh: newTransport(true)}
+	}
+	roundTripper = NewHTTPDebugTransport(roundTripper, opts, logger.With(log.String("source", "http-debug")))
+	for _, wrap := range wrappers {
+		roundTripper = wrap(roundTripper)
+	}
+	freshTransport := roundTripper
+	if !opts.NoConnectionReuse.Bool {
+		freshTransport = freshConnTransport{roundTripper}
 	}
-	_ = http2.ConfigureTransport(transport)
 
 	cookieJar, err := cookiejar.New(nil)
 	if err != nil {
@@ -202,12 +381,79 @@ func NewState(logger log.Logger, opts Options) (*State, error) {
 	return &State{
 		Logger:    logger,
 		Options:   opts,
-		Transport: transport,
+		Transport: roundTripper,
 		Dialer:    dialer,
 		TLSConfig: tlsConfig,
 		CookieJar: cookieJar,
 		RPSLimit:  rpsLimit,
 		BPool:     bpool.NewBufferPool(100),
 		Tags:      opts.RunTags.CloneTags(),
+
+		FreshTransport: freshTransport,
+		CertWarnings:   certWarnings,
 	}, nil
 }
+
+// freshConnKey marks the context of the requests made with State.FreshTransport.
+type freshConnKey struct{}
+
+// freshConnTransport is the FreshTransport of NewState, it marks the requests so
+// the connReuseTransport under the wrappers sends them on a new connection.
+type freshConnTransport struct {
+	roundTripper http.RoundTripper
+}
+
+func (t freshConnTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.roundTripper.RoundTrip(req.WithContext(context.WithValue(req.Context(), freshConnKey{}, true)))
+}
+
+// connReuseTransport sends the requests marked by freshConnTransport through fresh,
+// which never reuses a connection, and the other ones through keepAlive.
+type connReuseTransport struct {
+	keepAlive, fresh http.RoundTripper
+}
+
+func (t connReuseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(freshConnKey{}) != nil {
+		return t.fresh.RoundTrip(req)
+	}
+	return t.keepAlive.RoundTrip(req)
+}
+
+// DefaultTLSAuthExpiryThreshold is the default of Options.TLSAuthExpiryThreshold.
+const DefaultTLSAuthExpiryThreshold = 7 * 24 * time.Hour
+
+// CertExpiryWarning is about a client certificate which is expired, or expires
+// within Options.TLSAuthExpiryThreshold. It's returned as an error by NewState
+// when Options.TLSAuthExpiryError is set.
+type CertExpiryWarning struct {
+	Domains  []string
+	NotAfter time.Time
+	Expired  bool
+}
+
+func (w *CertExpiryWarning) Error() string {
+	if w.Expired {
+		return fmt.Sprintf("the client certificate for %s expired on %s",
+			strings.Join(w.Domains, ", "), w.NotAfter.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("the client certificate for %s expires on %s",
+		strings.Join(w.Domains, ", "), w.NotAfter.Format(time.RFC3339))
+}
+
+// checkCertExpiry returns a warning if the leaf certificate of cert expires within threshold.
+func checkCertExpiry(cert *tls.Certificate, domains []string, threshold time.Duration) (*CertExpiryWarning, error) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if now.Add(threshold).Before(leaf.NotAfter) {
+		return nil, nil
+	}
+	return &CertExpiryWarning{Domains: domains, NotAfter: leaf.NotAfter, Expired: now.After(leaf.NotAfter)}, nil
+}
@@ -0,0 +1,455 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+	null "gopkg.in/guregu/null.v3"
+
+	"github.com/runner-mei/gojs/lib/netext"
+	"github.com/runner-mei/gojs/lib/testutils"
+	"github.com/runner-mei/gojs/lib/types"
+	"github.com/runner-mei/log"
+	"github.com/runner-mei/log/logtest"
+)
+
+// newTestTLSAuth returns a self-signed client certificate for domain, expiring at notAfter.
+func newTestTLSAuth(t *testing.T, domain string, notAfter time.Time) *netext.TLSAuth {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &netext.TLSAuth{TLSAuthFields: netext.TLSAuthFields{
+		Cert:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:     string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+		Domains: []string{domain},
+	}}
+}
+
+func TestNewStateCertExpiry(t *testing.T) {
+	now := time.Now()
+	valid := newTestTLSAuth(t, "valid.example.com", now.Add(30*24*time.Hour))
+	expiring := newTestTLSAuth(t, "expiring.example.com", now.Add(24*time.Hour))
+	expired := newTestTLSAuth(t, "expired.example.com", now.Add(-time.Hour))
+
+	state, err := NewState(testutils.NewLogger(t), Options{TLSAuth: []*netext.TLSAuth{valid, expiring, expired}})
+	require.NoError(t, err)
+	require.Len(t, state.CertWarnings, 2)
+	assert.Equal(t, []string{"expiring.example.com"}, state.CertWarnings[0].Domains)
+	assert.False(t, state.CertWarnings[0].Expired)
+	assert.Contains(t, state.CertWarnings[0].Error(), "the client certificate for expiring.example.com expires on ")
+	assert.True(t, state.CertWarnings[1].Expired)
+	assert.Contains(t, state.CertWarnings[1].Error(), "the client certificate for expired.example.com expired on ")
+
+	state, err = NewState(testutils.NewLogger(t), Options{
+		TLSAuth:                []*netext.TLSAuth{valid, expiring},
+		TLSAuthExpiryThreshold: types.NullDurationFrom(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, state.CertWarnings)
+
+	_, err = NewState(testutils.NewLogger(t), Options{
+		TLSAuth:            []*netext.TLSAuth{valid, expired},
+		TLSAuthExpiryError: null.BoolFrom(true),
+	})
+	require.IsType(t, &CertExpiryWarning{}, err)
+}
+
+func TestNewStateTLSAuthDuplicateDomain(t *testing.T) {
+	notAfter := time.Now().Add(30 * 24 * time.Hour)
+	first := newTestTLSAuth(t, "example.com", notAfter)
+	second := newTestTLSAuth(t, "other.example.com", notAfter)
+	second.Domains = append(second.Domains, "EXAMPLE.com")
+
+	_, err := NewState(testutils.NewLogger(t), Options{TLSAuth: []*netext.TLSAuth{first, second}})
+	require.EqualError(t, err, `the domain "EXAMPLE.com" is in the tlsAuth entries 0 and 1, `+
+		`only one client certificate can be presented to it`)
+
+	// The same domain twice in a single entry is fine.
+	first.Domains = append(first.Domains, "example.com")
+	state, err := NewState(testutils.NewLogger(t), Options{TLSAuth: []*netext.TLSAuth{first}})
+	require.NoError(t, err)
+	tlsConfig := keepAliveTransport(state.Transport).TLSClientConfig
+	assert.Same(t, &tlsConfig.Certificates[0], tlsConfig.NameToCertificate["example.com"])
+}
+
+func TestNewStateTLSAuthFS(t *testing.T) {
+	auth := newTestTLSAuth(t, "example.com", time.Now().Add(30*24*time.Hour))
+	fsys := fstest.MapFS{
+		"certs/client.crt": {Data: []byte(auth.Cert)},
+		"certs/client.key": {Data: []byte(auth.Key)},
+	}
+	auth.Cert, auth.Key = "", ""
+	auth.CertFile, auth.KeyFile = "certs/client.crt", "certs/client.key"
+
+	_, err := NewState(testutils.NewLogger(t), Options{TLSAuth: []*netext.TLSAuth{auth}})
+	require.Error(t, err)
+
+	state, err := NewState(testutils.NewLogger(t), Options{TLSAuth: []*netext.TLSAuth{auth}, TLSAuthFS: fsys})
+	require.NoError(t, err)
+	tlsConfig := keepAliveTransport(state.Transport).TLSClientConfig
+	assert.NotNil(t, tlsConfig.NameToCertificate["example.com"])
+}
+
+func TestNewStateTransportConfig(t *testing.T) {
+	state, err := NewState(testutils.NewLogger(t), Options{Batch: null.IntFrom(20), BatchPerHost: null.IntFrom(5)})
+	require.NoError(t, err)
+	transport := keepAliveTransport(state.Transport)
+	dialer := state.Dialer.(*netext.Dialer)
+	assert.True(t, transport.DisableCompression)
+	assert.Equal(t, 20, transport.MaxIdleConns)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Duration(0), transport.IdleConnTimeout)
+	assert.Equal(t, 30*time.Second, dialer.Timeout)
+	assert.Equal(t, 30*time.Second, dialer.KeepAlive)
+
+	state, err = NewState(testutils.NewLogger(t), Options{
+		Batch: null.IntFrom(20),
+		Transport: types.TransportConfig{
+			DialTimeout:           types.NullDurationFrom(5 * time.Second),
+			KeepAlive:             types.NullDurationFrom(time.Minute),
+			IdleConnTimeout:       types.NullDurationFrom(90 * time.Second),
+			ResponseHeaderTimeout: types.NullDurationFrom(10 * time.Second),
+			ExpectContinueTimeout: types.NullDurationFrom(time.Second),
+			MaxIdleConns:          null.IntFrom(100),
+			DisableCompression:    null.BoolFrom(false),
+		},
+	})
+	require.NoError(t, err)
+	transport = keepAliveTransport(state.Transport)
+	dialer = state.Dialer.(*netext.Dialer)
+	assert.False(t, transport.DisableCompression)
+	assert.Equal(t, 100, transport.MaxIdleConns)
+	assert.Equal(t, 90*time.Second, transport.IdleConnTimeout)
+	assert.Equal(t, 10*time.Second, transport.ResponseHeaderTimeout)
+	assert.Equal(t, time.Second, transport.ExpectContinueTimeout)
+	assert.Equal(t, 5*time.Second, dialer.Timeout)
+	assert.Equal(t, time.Minute, dialer.KeepAlive)
+}
+
+// keepAliveTransport returns the transport of NewState used by the requests which
+// reuse the connections.
+func keepAliveTransport(rt http.RoundTripper) *http.Transport {
+	if t, ok := rt.(connReuseTransport); ok {
+		rt = t.keepAlive
+	}
+	return rt.(*http.Transport)
+}
+
+func TestNewStateFreshTransport(t *testing.T) {
+	state, err := NewState(testutils.NewLogger(t), Options{})
+	require.NoError(t, err)
+	fresh := state.Transport.(connReuseTransport).fresh.(*http.Transport)
+	assert.False(t, keepAliveTransport(state.Transport).DisableKeepAlives)
+	assert.True(t, fresh.DisableKeepAlives)
+	assert.Equal(t, keepAliveTransport(state.Transport).MaxIdleConns, fresh.MaxIdleConns)
+
+	// All the connections are fresh with NoConnectionReuse.
+	state, err = NewState(testutils.NewLogger(t), Options{NoConnectionReuse: null.BoolFrom(true)})
+	require.NoError(t, err)
+	assert.True(t, keepAliveTransport(state.Transport).DisableKeepAlives)
+	assert.Same(t, state.Transport, state.FreshTransport)
+}
+
+func TestNewStateFreshTransportWrappers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Wrapped") + " " + r.RemoteAddr))
+	}))
+	defer srv.Close()
+
+	var wrapped int
+	wrapper := func(next http.RoundTripper) http.RoundTripper {
+		wrapped++
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Wrapped", "true")
+			return next.RoundTrip(req)
+		})
+	}
+	state, err := NewState(testutils.NewLogger(t), Options{}, wrapper)
+	require.NoError(t, err)
+	assert.Equal(t, 1, wrapped)
+
+	get := func(rt http.RoundTripper) (wrapped, remoteAddr string) {
+		resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		parts := strings.SplitN(string(body), " ", 2)
+		return parts[0], parts[1]
+	}
+	_, first := get(state.Transport)
+	_, second := get(state.Transport)
+	assert.Equal(t, first, second, "the connection wasn't reused")
+	freshWrapped, fresh := get(state.FreshTransport)
+	assert.Equal(t, "true", freshWrapped)
+	assert.NotEqual(t, first, fresh, "the connection was reused")
+}
+
+func TestNewStateLocalIPs(t *testing.T) {
+	var opts Options
+	require.NoError(t, opts.LocalIPs.UnmarshalText([]byte("192.168.0.10,fd00::1")))
+	state, err := NewState(testutils.NewLogger(t), opts)
+	require.NoError(t, err)
+	dialer := state.Dialer.(*netext.Dialer)
+	assert.Same(t, opts.LocalIPs.Pool, dialer.LocalIPs)
+	assert.Nil(t, dialer.Dialer.LocalAddr)
+	ip, _ := dialer.LocalIPs.GetIPOfFamily(dialer.LocalIPIndex, true)
+	assert.Equal(t, "fd00::1", ip.String())
+}
+
+func TestNewStateDisableHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	for proto, disableHTTP2 := range map[string]bool{"HTTP/2.0": false, "HTTP/1.1": true} {
+		disableHTTP2 := disableHTTP2
+		t.Run(proto, func(t *testing.T) {
+			state, err := NewState(testutils.NewLogger(t), Options{
+				InsecureSkipTLSVerify: null.BoolFrom(true),
+				DisableHTTP2:          null.BoolFrom(disableHTTP2),
+			})
+			require.NoError(t, err)
+
+			resp, err := (&http.Client{Transport: state.Transport}).Get(srv.URL)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, proto, resp.Proto)
+		})
+	}
+}
+
+func TestStateResetCookies(t *testing.T) {
+	u, err := url.Parse("http://example.com")
+	require.NoError(t, err)
+
+	for _, noCookiesReset := range []bool{false, true} {
+		noCookiesReset := noCookiesReset
+		t.Run(fmt.Sprintf("NoCookiesReset=%v", noCookiesReset), func(t *testing.T) {
+			state, err := NewState(testutils.NewLogger(t), Options{NoCookiesReset: null.BoolFrom(noCookiesReset)})
+			require.NoError(t, err)
+			state.CookieJar.SetCookies(u, []*http.Cookie{{Name: "a", Value: "b"}})
+
+			require.NoError(t, state.ResetCookies())
+			if noCookiesReset {
+				assert.Len(t, state.CookieJar.Cookies(u), 1)
+			} else {
+				assert.Empty(t, state.CookieJar.Cookies(u))
+			}
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewStateTransportWrappers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Join(r.Header["X-Wrappers"], ",")))
+	}))
+	defer srv.Close()
+
+	wrapper := func(name string) TransportWrapper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Add("X-Wrappers", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	state, err := NewState(testutils.NewLogger(t), Options{}, wrapper("a"), wrapper("b"))
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: state.Transport}).Get(srv.URL)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "b,a", string(body))
+}
+
+func TestNewStateHTTPDebug(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	for _, httpDebug := range []string{"headers", "full", "", "foo"} {
+		httpDebug := httpDebug
+		t.Run(httpDebug, func(t *testing.T) {
+			logger, observedLogs := logtest.NewObservedLogger()
+			state, err := NewState(logger, Options{HTTPDebug: null.StringFrom(httpDebug)})
+			require.NoError(t, err)
+
+			resp, err := (&http.Client{Transport: state.Transport}).Get(srv.URL)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			exists, logEntry := logtest.LastEntry(observedLogs)
+			if httpDebug != "headers" && httpDebug != "full" {
+				assert.False(t, exists)
+				return
+			}
+			if assert.True(t, exists) {
+				assert.Equal(t, log.InfoLevel, logEntry.Level)
+				assert.Equal(t, "http-debug", logEntry.ContextMap()["source"])
+			}
+		})
+	}
+}
+
+func TestNewStateHTTPDebugBody(t *testing.T) {
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	logger, observedLogs := logtest.NewObservedLogger()
+	state, err := NewState(logger, Options{
+		HTTPDebug:          null.StringFrom("full"),
+		HTTPDebugBodyLimit: null.IntFrom(10),
+	})
+	require.NoError(t, err)
+
+	// A reader which isn't a *bytes.Reader, so the body can't be read again.
+	body := strings.Repeat("0123456789", 100)
+	reqBody := ioutil.NopCloser(strings.NewReader(body))
+	req, err := http.NewRequest("POST", srv.URL, reqBody)
+	require.NoError(t, err)
+	resp, err := state.Transport.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, body, string(received))
+	assert.Equal(t, reqBody, req.Body, "the request of the caller was modified")
+
+	var dump string
+	for _, entry := range observedLogs.All() {
+		if strings.HasPrefix(entry.Message, "Request:") {
+			dump, _ = entry.ContextMap()["body"].(string)
+		}
+	}
+	assert.True(t, strings.HasSuffix(dump,
+		"\n\n0123456789\n[body truncated to 10 bytes, see the httpDebugBodyLimit option]"), dump)
+}
+
+func TestNewStateSharedRPSLimiter(t *testing.T) {
+	const rps, vus, duration = 100, 10, 500 * time.Millisecond
+	opts := Options{RPS: null.IntFrom(rps)}
+	opts.RPSLimiter = NewRPSLimiter(rps, opts)
+
+	var wg sync.WaitGroup
+	var requests int64
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	for i := 0; i < vus; i++ {
+		state, err := NewState(testutils.NewLogger(t), opts)
+		require.NoError(t, err)
+		require.Same(t, opts.RPSLimiter, state.RPSLimit)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for state.RPSLimit.Wait(ctx) == nil {
+				atomic.AddInt64(&requests, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the budget is shared, so it's not multiplied by the number of VUs
+	assert.InDelta(t, rps*duration.Seconds(), float64(requests), rps*duration.Seconds()*0.2)
+}
+
+func TestStateSetRPSLimit(t *testing.T) {
+	state, err := NewState(testutils.NewLogger(t), Options{RPSBurst: null.IntFrom(5)})
+	require.NoError(t, err)
+	assert.Nil(t, state.RPSLimit)
+
+	state.SetRPSLimit(10)
+	require.NotNil(t, state.RPSLimit)
+	assert.Equal(t, rate.Limit(10), state.RPSLimit.Limit())
+	assert.Equal(t, 5, state.RPSLimit.Burst())
+
+	state.SetRPSLimit(20)
+	assert.Equal(t, rate.Limit(20), state.RPSLimit.Limit())
+	state.SetRPSLimit(0)
+	assert.Equal(t, rate.Inf, state.RPSLimit.Limit())
+}
+
+func TestStateTagsRace(t *testing.T) {
+	state := &State{Tags: map[string]string{"group": ""}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("tag%d", i)
+				state.SetTag(key, "value")
+				state.DeleteTag(key)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.Equal(t, "", state.CloneTags()["group"])
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]string{"group": ""}, state.CloneTags())
+}
@@ -0,0 +1,104 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/runner-mei/gojs/stats"
+)
+
+// ThresholdResult is the outcome of the evaluation of the thresholds of a single metric.
+type ThresholdResult struct {
+	// Passed is false if any of the thresholds failed
+	Passed bool
+	// Failed contains the sources of the thresholds that failed
+	Failed []string
+	// Values are the values computed from the metric samples, the ones the thresholds were evaluated with
+	Values map[string]float64
+	// NoData is true when no sample matched the metric, the thresholds weren't
+	// evaluated then, Passed is true and Values is nil
+	NoData bool
+}
+
+// EvaluateThresholds evaluates the thresholds against the collected samples,
+// the result is keyed like the thresholds, i.e. by metric or submetric name
+// (e.g. `http_req_duration{status:200}`). t is the duration of the test, it is
+// used by the rate values and the abort grace periods. The thresholds of the
+// metrics without samples are reported with NoData.
+//
+// The thresholds are evaluated on copies, so their LastFailed and Abort fields
+// aren't modified, but they are still not safe for concurrent use, since the
+// copies share their goja runtime.
+func EvaluateThresholds(
+	samples []stats.SampleContainer, thresholds map[string]stats.Thresholds, t time.Duration,
+) (map[string]ThresholdResult, error) {
+	results := make(map[string]ThresholdResult, len(thresholds))
+	for name, ts := range thresholds {
+		parent, sm := stats.NewSubmetric(name)
+
+		var sink stats.Sink
+		for _, container := range samples {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric == nil || sample.Metric.Name != parent || !sample.Tags.Contains(sm.Tags) {
+					continue
+				}
+				if sink == nil {
+					sink = newSink(sample.Metric)
+				}
+				sink.Add(sample)
+			}
+		}
+		if sink == nil {
+			results[name] = ThresholdResult{Passed: true, NoData: true}
+			continue
+		}
+		sink.Calc()
+
+		ts.Thresholds = make([]*stats.Threshold, len(ts.Thresholds))
+		for i, th := range thresholds[name].Thresholds {
+			th := *th
+			ts.Thresholds[i] = &th
+		}
+		passed, err := ts.Run(sink, t)
+		if err != nil {
+			return nil, fmt.Errorf("threshold '%s': %w", name, err)
+		}
+
+		result := ThresholdResult{Passed: passed, Values: sink.Format(t)}
+		for _, th := range ts.Thresholds {
+			if th.LastFailed {
+				result.Failed = append(result.Failed, th.Source)
+			}
+		}
+		results[name] = result
+	}
+	return results, nil
+}
+
+// newSink returns an empty sink of the same kind as the sink of the metric.
+func newSink(m *stats.Metric) stats.Sink {
+	if hs, ok := m.Sink.(*stats.HistogramSink); ok {
+		return &stats.HistogramSink{Buckets: hs.Buckets}
+	}
+	return stats.New(m.Name, m.Type).Sink
+}
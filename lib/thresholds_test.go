@@ -0,0 +1,83 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runner-mei/gojs/stats"
+)
+
+func TestEvaluateThresholds(t *testing.T) {
+	metric := stats.New("my_trend", stats.Trend)
+	newSample := func(value float64, tags map[string]string) stats.Sample {
+		return stats.Sample{Metric: metric, Value: value, Tags: stats.NewSampleTags(tags)}
+	}
+	samples := []stats.SampleContainer{
+		newSample(10, map[string]string{"status": "200"}),
+		stats.Samples{
+			newSample(20, map[string]string{"status": "200"}),
+			newSample(300, map[string]string{"status": "500"}),
+		},
+	}
+
+	newThresholds := func(sources ...string) stats.Thresholds {
+		ts, err := stats.NewThresholds(sources)
+		require.NoError(t, err)
+		return ts
+	}
+
+	thresholds := map[string]stats.Thresholds{
+		"my_trend":               newThresholds("max<100", "min<100"),
+		"my_trend{status:200}":   newThresholds("max<100"),
+		"my_trend{status:'500'}": newThresholds("avg>100"),
+	}
+	results, err := EvaluateThresholds(samples, thresholds, time.Second)
+	require.NoError(t, err)
+	assert.False(t, thresholds["my_trend"].Thresholds[0].LastFailed, "the thresholds of the caller were modified")
+
+	assert.False(t, results["my_trend"].Passed)
+	assert.Equal(t, []string{"max<100"}, results["my_trend"].Failed)
+	assert.Equal(t, 300.0, results["my_trend"].Values["max"])
+
+	assert.True(t, results["my_trend{status:200}"].Passed)
+	assert.Empty(t, results["my_trend{status:200}"].Failed)
+	assert.Equal(t, 20.0, results["my_trend{status:200}"].Values["max"])
+
+	assert.True(t, results["my_trend{status:'500'}"].Passed)
+
+	t.Run("NoData", func(t *testing.T) {
+		results, err := EvaluateThresholds(samples, map[string]stats.Thresholds{
+			"unknown":              newThresholds("count>0"),
+			"my_trend{status:404}": newThresholds("max<100"),
+			"my_trend":             newThresholds("max<1000"),
+		}, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, ThresholdResult{Passed: true, NoData: true}, results["unknown"])
+		assert.Equal(t, ThresholdResult{Passed: true, NoData: true}, results["my_trend{status:404}"])
+		assert.True(t, results["my_trend"].Passed)
+		assert.False(t, results["my_trend"].NoData)
+	})
+}
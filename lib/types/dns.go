@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
 
 	"github.com/runner-mei/gojs/lib/strvals"
 	null "gopkg.in/guregu/null.v3"
@@ -35,12 +36,14 @@ type DNSConfig struct {
 	TTL null.String `json:"ttl"`
 	// Select specifies the strategy to use when picking a single IP if more than one is returned for a host name.
 	Select NullDNSSelect `json:"select"`
-	// Policy specifies how to handle returning of IPv4 or IPv6 addresses.
+	// Policy specifies how to handle returning of IPv4 or IPv6 addresses, the
+	// resolved addresses are filtered and ordered by it before Select picks one.
 	Policy NullDNSPolicy `json:"policy"`
-	// FIXME: Valid is unused and is only added to satisfy some logic in
-	// lib.Options.ForEachSpecified(), otherwise it would panic with
-	// `reflect: call of reflect.Value.Bool on zero Value`.
-	Valid bool `json:"-"`
+	// DoH is the URL of a DNS-over-HTTPS server to use instead of the system resolver.
+	DoH null.String `json:"doh"`
+	// DoHBootstrap are the IPs of the DoH server, its host is resolved with the
+	// system resolver when they're not set, e.g. dohBootstrap={1.1.1.1,1.0.0.1}.
+	DoHBootstrap []net.IP `json:"dohBootstrap"`
 }
 
 // DefaultDNSConfig returns the default DNS configuration.
@@ -120,6 +123,15 @@ func (d NullDNSPolicy) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.DNSPolicy)
 }
 
+// ValueOrZero returns the underlying DNSPolicy value of d if valid or
+// its zero equivalent otherwise. It matches the existing guregu/null API.
+func (d NullDNSPolicy) ValueOrZero() DNSPolicy {
+	if !d.Valid {
+		return 0
+	}
+	return d.DNSPolicy
+}
+
 // DNSSelect is the strategy to use when picking a single IP if more than one
 // is returned for a host name.
 //go:generate enumer -type=DNSSelect -trimprefix DNS -output dns_select_gen.go
@@ -185,6 +197,15 @@ func (d NullDNSSelect) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.DNSSelect)
 }
 
+// ValueOrZero returns the underlying DNSSelect value of d if valid or
+// its zero equivalent otherwise. It matches the existing guregu/null API.
+func (d NullDNSSelect) ValueOrZero() DNSSelect {
+	if !d.Valid {
+		return 0
+	}
+	return d.DNSSelect
+}
+
 // String implements fmt.Stringer.
 func (c DNSConfig) String() string {
 	return fmt.Sprintf("ttl=%s,select=%s,policy=%s",
@@ -197,6 +218,9 @@ func (c *DNSConfig) UnmarshalJSON(data []byte) error {
 		TTL    null.String   `json:"ttl"`
 		Select NullDNSSelect `json:"select"`
 		Policy NullDNSPolicy `json:"policy"`
+		DoH    null.String   `json:"doh"`
+
+		DoHBootstrap []net.IP `json:"dohBootstrap"`
 	}
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err
@@ -204,6 +228,8 @@ func (c *DNSConfig) UnmarshalJSON(data []byte) error {
 	c.TTL = s.TTL
 	c.Select = s.Select
 	c.Policy = s.Policy
+	c.DoH = s.DoH
+	c.DoHBootstrap = s.DoHBootstrap
 	return nil
 }
 
@@ -240,6 +266,21 @@ func (c *DNSConfig) unmarshal(params map[string]interface{}) error {
 		case "ttl":
 			ttlv := fmt.Sprintf("%v", v)
 			c.TTL = null.StringFrom(ttlv)
+		case "doh":
+			c.DoH = null.StringFrom(fmt.Sprintf("%v", v))
+		case "dohBootstrap":
+			ips, ok := v.([]interface{})
+			if !ok {
+				ips = []interface{}{v}
+			}
+			c.DoHBootstrap = make([]net.IP, 0, len(ips))
+			for _, v := range ips {
+				ip := net.ParseIP(fmt.Sprintf("%v", v))
+				if ip == nil {
+					return fmt.Errorf("invalid DoH bootstrap IP: %v", v)
+				}
+				c.DoHBootstrap = append(c.DoHBootstrap, ip)
+			}
 		default:
 			return fmt.Errorf("unknown DNS configuration field: %s", k)
 		}
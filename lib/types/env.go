@@ -0,0 +1,164 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// LoadEnv sets the fields of the struct pointed by target from the environment variables
+// named by their `envconfig` tags, the fields tagged with `ignored:"true"` and the unset
+// or empty variables are skipped. getenv is usually os.Getenv.
+//
+// A value is decoded with the UnmarshalText method of the field when it has one, else with
+// its UnmarshalJSON method: JSON arrays, objects and strings are passed as is, while a plain
+// value is passed as a JSON string, or as an array of strings split on the commas when the
+// field is a slice. The other slices and maps are either JSON or a comma separated list,
+// of "key:value" pairs for the maps.
+func LoadEnv(target interface{}, getenv func(string) string) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to a struct, got %T", target)
+	}
+	val = val.Elem()
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := field.Tag.Get("envconfig")
+		if key == "" || key == "-" || field.Tag.Get("ignored") == "true" {
+			continue
+		}
+		str := getenv(key)
+		if str == "" {
+			continue
+		}
+		if err := decodeEnv(val.Field(i), str); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func decodeEnv(v reflect.Value, str string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(str))
+	}
+	if u, ok := v.Addr().Interface().(json.Unmarshaler); ok {
+		data := []byte(str)
+		if !isJSONComposite(str) {
+			var err error
+			if v.Kind() == reflect.Slice {
+				data, err = json.Marshal(strings.Split(str, ","))
+			} else {
+				data, err = json.Marshal(str)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return u.UnmarshalJSON(data)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeEnv(elem.Elem(), str); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(str)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(str, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(str, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if isJSONComposite(str) {
+			return json.Unmarshal([]byte(str), v.Addr().Interface())
+		}
+		parts := strings.Split(str, ",")
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := decodeEnv(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		if isJSONComposite(str) {
+			return json.Unmarshal([]byte(str), v.Addr().Interface())
+		}
+		m := reflect.MakeMap(v.Type())
+		for _, pair := range strings.Split(str, ",") {
+			idx := strings.Index(pair, ":")
+			if idx < 0 {
+				return fmt.Errorf("invalid map item %q, expected key:value", pair)
+			}
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := decodeEnv(key, strings.TrimSpace(pair[:idx])); err != nil {
+				return err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeEnv(elem, strings.TrimSpace(pair[idx+1:])); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, elem)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// isJSONComposite returns true if str is a JSON array, object or string.
+func isJSONComposite(str string) bool {
+	str = strings.TrimSpace(str)
+	if str == "" || !strings.ContainsAny(str[:1], `[{"`) {
+		return false
+	}
+	return json.Valid([]byte(str))
+}
@@ -23,10 +23,12 @@ package types
 import (
 	"bytes"
 	"encoding/json"
+	"path"
 	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
 )
 
 // NullHostnameTrie is a nullable HostnameTrie, in the same vein as the nullable types provided by
@@ -82,8 +84,22 @@ func (d NullHostnameTrie) MarshalJSON() ([]byte, error) {
 // HostnameTrie is a tree-structured list of hostname matches with support
 // for wildcards exclusively at the start of the pattern. Items may only
 // be inserted and searched. Internationalized hostnames are valid.
+//
+// The patterns with wildcards elsewhere, e.g. "*.internal.*" or "api-*.corp",
+// are globs: a * matches any sequence of characters, dots included, so it can
+// span several labels. They are matched one by one, after the trie, so the
+// patterns with a single leading wildcard should be preferred for long lists.
+// The labels with a wildcard are only lowercased, they match the punycode form
+// of the internationalized labels.
+//
+// The patterns and the searched hostnames are normalized the same way, see
+// normalizeHostname: the matches are case-insensitive, an internationalized
+// hostname matches its punycode form and the other way around, and the dot of
+// a fully qualified hostname is ignored. The matched patterns are returned in
+// their normalized form.
 type HostnameTrie struct {
 	*trieNode
+	globs  []string // the normalized patterns with a wildcard which isn't leading
 	source []string
 }
 
@@ -130,10 +146,24 @@ func isValidHostnamePattern(s string) error {
 	return nil
 }
 
+//nolint:gochecknoglobals
+var validGlobPattern = regexp.MustCompile(`^[a-z0-9*\-]+(\.[a-z0-9*\-]+)*$`)
+
 // insert a hostname pattern into the given HostnameTrie. Returns an error
 // if hostname pattern is invalid.
 func (t *HostnameTrie) insert(s string) error {
-	s = strings.ToLower(s)
+	if strings.LastIndexByte(s, '*') > 0 {
+		return t.insertGlob(s)
+	}
+
+	wildcard := ""
+	if strings.HasPrefix(s, "*") {
+		wildcard, s = "*", s[1:]
+		if strings.HasPrefix(s, ".") {
+			wildcard, s = "*.", s[1:]
+		}
+	}
+	s = wildcard + normalizeHostname(s)
 	if err := isValidHostnamePattern(s); err != nil {
 		return err
 	}
@@ -141,10 +171,50 @@ func (t *HostnameTrie) insert(s string) error {
 	return t.trieNode.insert(s)
 }
 
+// insertGlob adds a pattern with a wildcard which isn't leading to the globs.
+func (t *HostnameTrie) insertGlob(s string) error {
+	labels := strings.Split(strings.TrimSuffix(s, "."), ".")
+	for i, label := range labels {
+		if strings.Contains(label, "*") {
+			labels[i] = strings.ToLower(label)
+		} else {
+			labels[i] = normalizeHostname(label)
+		}
+	}
+	glob := strings.Join(labels, ".")
+	if !validGlobPattern.MatchString(glob) {
+		return errors.Errorf("invalid hostname pattern %s", s)
+	}
+	t.globs = append(t.globs, glob)
+	return nil
+}
+
 // Contains returns whether s matches a pattern in the HostnameTrie
 // along with the matching pattern, if one was found.
 func (t *HostnameTrie) Contains(s string) (matchedPattern string, matchFound bool) {
-	return t.trieNode.contains(s)
+	s = normalizeHostname(s)
+	if match, found := t.trieNode.contains(s); found {
+		return match, true
+	}
+	for _, glob := range t.globs {
+		// The globs only have the * meta character, which matches the dots too.
+		if matched, _ := path.Match(glob, s); matched {
+			return glob, true
+		}
+	}
+	return "", false
+}
+
+// normalizeHostname returns the lowercase ASCII form of the hostname s, with its
+// internationalized labels in punycode and without the dot of a fully qualified
+// name, e.g. "xn--bcher-kva.example" for "Bücher.example.". When s isn't a valid
+// internationalized hostname, it's only lowercased.
+func normalizeHostname(s string) string {
+	s = strings.TrimSuffix(s, ".")
+	if ascii, err := idna.Lookup.ToASCII(s); err == nil {
+		return ascii
+	}
+	return strings.ToLower(s)
 }
 
 type trieNode struct {
@@ -31,8 +31,12 @@ func TestHostnameTrieInsert(t *testing.T) {
 	hostnames, err := NewHostnameTrie([]string{"foo.bar"})
 	assert.NoError(t, err)
 	assert.NoError(t, hostnames.insert("test.k6.io"))
-	assert.Error(t, hostnames.insert("inval*d.pattern"))
+	assert.Error(t, hostnames.insert("inval_d.pattern"))
 	assert.NoError(t, hostnames.insert("*valid.pattern"))
+	assert.NoError(t, hostnames.insert("inval*d.pattern"))
+	assert.Error(t, hostnames.insert("inval*d..pattern"))
+	assert.Error(t, hostnames.insert("api-*.c_rp"))
+	assert.Equal(t, []string{"inval*d.pattern"}, hostnames.globs)
 }
 
 func TestHostnameTrieContains(t *testing.T) {
@@ -64,3 +68,74 @@ func TestHostnameTrieContains(t *testing.T) {
 		})
 	}
 }
+
+func TestHostnameTrieNormalization(t *testing.T) {
+	trie, err := NewHostnameTrie([]string{"Bücher.Example", "*.MÜNCHEN.de", "xn--caf-dma.example"})
+	require.NoError(t, err)
+	cases := map[string]string{
+		"bücher.example":            "xn--bcher-kva.example",
+		"BÜCHER.EXAMPLE":            "xn--bcher-kva.example",
+		"xn--bcher-kva.example":     "xn--bcher-kva.example",
+		"XN--BCHER-KVA.example.":    "xn--bcher-kva.example",
+		"www.münchen.de":            "*.xn--mnchen-3ya.de",
+		"www.xn--mnchen-3ya.de":     "*.xn--mnchen-3ya.de",
+		"café.example":              "xn--caf-dma.example",
+		"CAFÉ.Example.":             "xn--caf-dma.example",
+		"bucher.example":            "",
+		"münchen.de":                "",
+		"sub.xn--bcher-kva.example": "",
+	}
+	for key, value := range cases {
+		host, pattern := key, value
+		t.Run(host, func(t *testing.T) {
+			match, matches := trie.Contains(host)
+			if pattern == "" {
+				assert.False(t, matches)
+				assert.Empty(t, match)
+			} else {
+				assert.True(t, matches)
+				assert.Equal(t, pattern, match)
+			}
+		})
+	}
+}
+
+func TestHostnameTrieGlobs(t *testing.T) {
+	trie, err := NewHostnameTrie([]string{"*.internal.*", "api-*.Corp", "db*.*.münchen.de", "*.k6.io"})
+	require.NoError(t, err)
+	cases := map[string]string{
+		// The suffix wildcards are still matched by the trie.
+		"test.k6.io":     "*.k6.io",
+		"a.b.test.k6.io": "*.k6.io",
+		// The infix ones can span several labels.
+		"a.internal.b":             "*.internal.*",
+		"a.b.INTERNAL.c.d":         "*.internal.*",
+		"api-v1.corp":              "api-*.corp",
+		"API-v1.eu.corp.":          "api-*.corp",
+		"api-.corp":                "api-*.corp",
+		"db1.eu.münchen.de":        "db*.*.xn--mnchen-3ya.de",
+		"db1.eu.xn--mnchen-3ya.de": "db*.*.xn--mnchen-3ya.de",
+		// The non-matching ones.
+		"k6.io":           "",
+		"internal.a":      "",
+		"a.internal":      "",
+		"a.internals.b":   "",
+		"api.corp":        "",
+		"www.api-v1.corp": "",
+		"api-v1.corp.com": "",
+		"db1.münchen.de":  "",
+	}
+	for key, value := range cases {
+		host, pattern := key, value
+		t.Run(host, func(t *testing.T) {
+			match, matches := trie.Contains(host)
+			if pattern == "" {
+				assert.False(t, matches)
+				assert.Empty(t, match)
+			} else {
+				assert.True(t, matches)
+				assert.Equal(t, pattern, match)
+			}
+		})
+	}
+}
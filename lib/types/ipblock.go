@@ -32,12 +32,14 @@ import (
 type ipBlock struct {
 	firstIP, count *big.Int
 	ipv6           bool
+	zone           string // the zone of the IPv6 addresses, e.g. the interface of the link-local ones
 }
 
-// ipPoolBlock is similar to ipBlock but instead of knowing its count/size it knows the first index
-// from which it starts in an IPPool
+// ipPoolBlock is similar to ipBlock but also knows the first index from which it starts in an IPPool
 type ipPoolBlock struct {
-	firstIP, startIndex *big.Int
+	firstIP, startIndex, count *big.Int
+	ipv6                       bool
+	zone                       string
 }
 
 // IPPool represent a slice of IPBlocks
@@ -46,7 +48,21 @@ type IPPool struct {
 	count *big.Int
 }
 
+// getIPBlock parses an IP, an IP range or a CIDR, the IPv6 ones can end with a
+// %zone, e.g. fe80::/64%eth0.
 func getIPBlock(s string) (*ipBlock, error) {
+	if i := strings.LastIndex(s, "%"); i >= 0 {
+		block, err := getIPBlock(s[:i])
+		if err != nil {
+			return nil, err
+		}
+		if !block.ipv6 || i == len(s)-1 {
+			return nil, errors.New("a zone needs an IPv6 block and a name: " + s)
+		}
+		block.zone = s[i+1:]
+		return block, nil
+	}
+
 	switch {
 	case strings.Contains(s, "-"):
 		return ipBlockFromRange(s)
@@ -130,7 +146,15 @@ func (b ipPoolBlock) getIP(index *big.Int) net.IP {
 	i := new(big.Int)
 	i.Add(b.firstIP, index)
 	// TODO use big.Int.FillBytes when golang 1.14 is no longer supported
-	return net.IP(i.Bytes())
+	size := net.IPv4len
+	if b.ipv6 {
+		size = net.IPv6len
+	}
+	// The leading zero bytes aren't in i.Bytes(), e.g. for ::1.
+	ip := make(net.IP, size)
+	bs := i.Bytes()
+	copy(ip[size-len(bs):], bs)
+	return ip
 }
 
 // NewIPPool returns an IPPool slice from the provided string representation that should be comma
@@ -149,6 +173,9 @@ func NewIPPool(ranges string) (*IPPool, error) {
 		pool.list[i] = ipPoolBlock{
 			firstIP:    r.firstIP,
 			startIndex: new(big.Int).Set(pool.count), // this is how many there are until now
+			count:      r.count,
+			ipv6:       r.ipv6,
+			zone:       r.zone,
 		}
 		pool.count.Add(pool.count, r.count)
 	}
@@ -178,6 +205,36 @@ func (pool *IPPool) GetIPBig(index *big.Int) net.IP {
 	return nil
 }
 
+// GetIPOfFamily returns an IP of the IPv4 or, when ipv6 is true, of the IPv6 blocks
+// of the pool with the provided index, and the zone of its block. The index goes
+// through the blocks of the family only, in their order, and a nil IP is returned
+// when the pool has none.
+func (pool *IPPool) GetIPOfFamily(index uint64, ipv6 bool) (net.IP, string) {
+	count := new(big.Int)
+	for _, b := range pool.list {
+		if b.ipv6 == ipv6 {
+			count.Add(count, b.count)
+		}
+	}
+	if count.Sign() == 0 {
+		return nil, ""
+	}
+
+	idx := new(big.Int).Rem(new(big.Int).SetUint64(index), count)
+	// The list is reversed, see NewIPPool.
+	for i := len(pool.list) - 1; i >= 0; i-- {
+		b := pool.list[i]
+		if b.ipv6 != ipv6 {
+			continue
+		}
+		if idx.Cmp(b.count) < 0 {
+			return b.getIP(idx), b.zone
+		}
+		idx.Sub(idx, b.count)
+	}
+	return nil, ""
+}
+
 // NullIPPool is a nullable IPPool
 type NullIPPool struct {
 	Pool  *IPPool
@@ -68,7 +68,7 @@ func TestIpBlock(t *testing.T) {
 			b, err := getIPBlock(name)
 			require.NoError(t, err)
 			assert.Equal(t, data.count, b.count)
-			pb := ipPoolBlock{firstIP: b.firstIP}
+			pb := ipPoolBlock{firstIP: b.firstIP, ipv6: b.ipv6}
 			idx := big.NewInt(0)
 			assert.Equal(t, data.firstIP.To16(), pb.getIP(idx).To16())
 			idx.Sub(idx.Add(idx, b.count), big.NewInt(1))
@@ -133,6 +133,36 @@ func TestIPPool(t *testing.T) {
 	}
 }
 
+func TestIPPoolGetIPOfFamily(t *testing.T) {
+	p, err := NewIPPool("192.168.0.101-192.168.0.102,::1,fe80::/127%eth0,192.168.0.200")
+	require.NoError(t, err)
+
+	for index, want := range map[uint64]string{0: "192.168.0.101", 1: "192.168.0.102", 2: "192.168.0.200", 3: "192.168.0.101"} {
+		ip, zone := p.GetIPOfFamily(index, false)
+		assert.Equal(t, net.IPv4len, len(ip))
+		assert.Equal(t, want, ip.String(), "index %d", index)
+		assert.Empty(t, zone)
+	}
+	for index, want := range map[uint64][2]string{
+		0: {"::1", ""},
+		1: {"fe80::", "eth0"},
+		2: {"fe80::1", "eth0"},
+		3: {"::1", ""},
+	} {
+		ip, zone := p.GetIPOfFamily(index, true)
+		assert.Equal(t, net.IPv6len, len(ip))
+		assert.Equal(t, want[0], ip.String(), "index %d", index)
+		assert.Equal(t, want[1], zone, "index %d", index)
+	}
+	assert.Equal(t, net.ParseIP("::1"), p.GetIP(2))
+
+	p, err = NewIPPool("192.168.0.101")
+	require.NoError(t, err)
+	ip, zone := p.GetIPOfFamily(0, true)
+	assert.Nil(t, ip)
+	assert.Empty(t, zone)
+}
+
 func TestIpBlockError(t *testing.T) {
 	testdata := map[string]string{
 		"whatever":                       "not a valid IP",
@@ -144,6 +174,8 @@ func TestIpBlockError(t *testing.T) {
 		"fd00::1-192.168.0.101":          "mixed IP range format",
 		"192.168.0.100-192.168.0.2":      "negative IP range",
 		"fd00:1:1:0::0-fd00:1:0:ff::3ff": "negative IP range",
+		"192.168.0.10/24%eth0":           "a zone needs an IPv6 block",
+		"fe80::1%":                       "a zone needs an IPv6 block",
 	}
 	for name, data := range testdata {
 		name, data := name, data
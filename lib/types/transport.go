@@ -0,0 +1,87 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2020 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"time"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+// TransportConfig is the configuration of the HTTP transport and its dialer.
+type TransportConfig struct {
+	// DialTimeout is the maximum amount of time a dial waits for a connect to complete.
+	DialTimeout NullDuration `json:"dialTimeout"`
+	// KeepAlive is the interval between the keep-alive probes of the connections.
+	KeepAlive NullDuration `json:"keepAlive"`
+	// IdleConnTimeout is how long an idle connection stays in the pool, zero means no limit.
+	IdleConnTimeout NullDuration `json:"idleConnTimeout"`
+	// ResponseHeaderTimeout is how long to wait for the response headers, zero means no limit.
+	ResponseHeaderTimeout NullDuration `json:"responseHeaderTimeout"`
+	// ExpectContinueTimeout is how long to wait for a "100 Continue" response,
+	// zero means the body is sent without waiting.
+	ExpectContinueTimeout NullDuration `json:"expectContinueTimeout"`
+	// MaxIdleConns and MaxIdleConnsPerHost limit the idle connections in the pool,
+	// they default to the batch and batchPerHost options.
+	MaxIdleConns        null.Int `json:"maxIdleConns"`
+	MaxIdleConnsPerHost null.Int `json:"maxIdleConnsPerHost"`
+	// DisableCompression prevents the transport from requesting and decoding
+	// gzip responses by itself.
+	DisableCompression null.Bool `json:"disableCompression"`
+}
+
+// DefaultTransportConfig returns the default transport configuration.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		DialTimeout:        NewNullDuration(30*time.Second, false),
+		KeepAlive:          NewNullDuration(30*time.Second, false),
+		DisableCompression: null.NewBool(true, false),
+	}
+}
+
+// Apply returns the result of overwriting the fields of c with the fields set in cfg.
+func (c TransportConfig) Apply(cfg TransportConfig) TransportConfig {
+	if cfg.DialTimeout.Valid {
+		c.DialTimeout = cfg.DialTimeout
+	}
+	if cfg.KeepAlive.Valid {
+		c.KeepAlive = cfg.KeepAlive
+	}
+	if cfg.IdleConnTimeout.Valid {
+		c.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.ResponseHeaderTimeout.Valid {
+		c.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	if cfg.ExpectContinueTimeout.Valid {
+		c.ExpectContinueTimeout = cfg.ExpectContinueTimeout
+	}
+	if cfg.MaxIdleConns.Valid {
+		c.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost.Valid {
+		c.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.DisableCompression.Valid {
+		c.DisableCompression = cfg.DisableCompression
+	}
+	return c
+}
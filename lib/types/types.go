@@ -129,6 +129,24 @@ func ParseExtendedDuration(data string) (result time.Duration, err error) {
 	return time.Duration(days)*24*time.Hour + hours, nil
 }
 
+// FormatExtendedDuration is the inverse of ParseExtendedDuration, it's like
+// time.Duration.String but the durations of a day or more start with the days,
+// e.g. "1d2h0m0s".
+func FormatExtendedDuration(d time.Duration) string {
+	days := d / (24 * time.Hour)
+	if days == 0 {
+		return d.String()
+	}
+	rest := d - days*24*time.Hour
+	if rest < 0 {
+		rest = -rest
+	}
+	if rest == 0 {
+		return fmt.Sprintf("%dd", days)
+	}
+	return fmt.Sprintf("%dd%s", days, rest)
+}
+
 // UnmarshalText converts text data to Duration
 func (d *Duration) UnmarshalText(data []byte) error {
 	v, err := ParseExtendedDuration(string(data))
@@ -149,6 +149,33 @@ func TestParseExtendedDuration(t *testing.T) {
 	}
 }
 
+func TestFormatExtendedDuration(t *testing.T) {
+	testCases := []struct {
+		dur    time.Duration
+		durStr string
+	}{
+		{0, "0s"},
+		{1120 * time.Millisecond, "1.12s"},
+		{2 * time.Hour, "2h0m0s"},
+		{-2 * time.Hour, "-2h0m0s"},
+		{24 * time.Hour, "1d"},
+		{47 * time.Hour, "1d23h0m0s"},
+		{-26 * time.Hour, "-1d2h0m0s"},
+		{48*time.Hour + 1, "2d1ns"},
+		{time.Duration(math.MaxInt64), "106751d23h47m16.854775807s"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.durStr, func(t *testing.T) {
+			assert.Equal(t, tc.durStr, FormatExtendedDuration(tc.dur))
+			result, err := ParseExtendedDuration(tc.durStr)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.dur, result)
+		})
+	}
+}
+
 func TestDuration(t *testing.T) {
 	t.Run("String", func(t *testing.T) {
 		assert.Equal(t, "1m15s", Duration(75*time.Second).String())
@@ -0,0 +1,108 @@
+package gojs
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	// memoryCheckInterval is how often the heap is sampled when a memory limit is set.
+	memoryCheckInterval = 10 * time.Millisecond
+	// StepInterval is the duration of a single step of RuntimeOptions.MaxSteps.
+	StepInterval = time.Millisecond
+)
+
+// MemoryLimitError is returned when a script exceeds RuntimeOptions.MemoryLimitBytes.
+type MemoryLimitError struct {
+	Limit uint64
+	Used  uint64
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("memory limit of %d bytes exceeded, %d bytes were allocated", e.Limit, e.Used)
+}
+
+// StepLimitError is returned when a script runs for more than RuntimeOptions.MaxSteps.
+type StepLimitError struct {
+	MaxSteps uint64
+}
+
+func (e *StepLimitError) Error() string {
+	return fmt.Sprintf("step budget of %d steps exceeded", e.MaxSteps)
+}
+
+// run runs fn under the limits of the runtime, fn is expected to run a script
+// in the goja runtime. The script is interrupted when it exceeds a limit, in
+// which case the error of the limit is returned instead of goja's InterruptedError.
+func (r *Runtime) run(fn func() (goja.Value, error)) (goja.Value, error) {
+	if r.opts.MemoryLimitBytes == 0 && r.opts.MaxSteps == 0 {
+		return fn()
+	}
+
+	var interrupted bool
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		interrupted = r.watch(done)
+	}()
+
+	v, err := fn()
+	close(done)
+	<-exited
+
+	if interrupted {
+		r.Runtime.ClearInterrupt()
+		if ierr, ok := err.(*goja.InterruptedError); ok {
+			if e, ok := ierr.Value().(error); ok {
+				return nil, e
+			}
+		}
+	}
+	return v, err
+}
+
+// watch samples the heap and counts the steps until done is closed, it
+// interrupts the runtime and returns true when one of the limits is exceeded.
+// The memory usage is only an approximation, since the heap is shared by the
+// whole process.
+func (r *Runtime) watch(done <-chan struct{}) bool {
+	var ms runtime.MemStats
+	var base uint64
+	var memoryC, stepC <-chan time.Time
+	if r.opts.MemoryLimitBytes > 0 {
+		runtime.ReadMemStats(&ms)
+		base = ms.HeapAlloc
+
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		memoryC = ticker.C
+	}
+	if r.opts.MaxSteps > 0 {
+		ticker := time.NewTicker(StepInterval)
+		defer ticker.Stop()
+		stepC = ticker.C
+	}
+
+	var steps uint64
+	for {
+		select {
+		case <-done:
+			return false
+		case <-memoryC:
+			runtime.ReadMemStats(&ms)
+			if limit := r.opts.MemoryLimitBytes; ms.HeapAlloc > base && ms.HeapAlloc-base > limit {
+				r.Runtime.Interrupt(&MemoryLimitError{Limit: limit, Used: ms.HeapAlloc - base})
+				return true
+			}
+		case <-stepC:
+			steps++
+			if steps >= r.opts.MaxSteps {
+				r.Runtime.Interrupt(&StepLimitError{MaxSteps: r.opts.MaxSteps})
+				return true
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package gojs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{MemoryLimitBytes: 50 * 1024 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	_, err = vm.RunString(ctx, `var a = []; while (true) { a.push({b: "x"}); }`)
+	var limitErr *MemoryLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatal("excepted MemoryLimitError got", err)
+	}
+
+	// the runtime is still usable after the limit was hit
+	ret, err := vm.RunString(ctx, `a = null; 1 + 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.ToInteger() != 2 {
+		t.Error("excepted 2 got", ret)
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{MaxSteps: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	_, err = vm.RunString(ctx, `while (true) {}`)
+	var limitErr *StepLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatal("excepted StepLimitError got", err)
+	}
+
+	ret, err := vm.RunString(ctx, `1 + 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.ToInteger() != 2 {
+		t.Error("excepted 2 got", ret)
+	}
+}
@@ -0,0 +1,49 @@
+package gojs
+
+import (
+	"context"
+
+	"github.com/runner-mei/log"
+)
+
+type loggerCtxKey struct{}
+
+func (key *loggerCtxKey) String() string {
+	return "js-logger"
+}
+
+var (
+	ctxKeyLogger = &loggerCtxKey{}
+)
+
+// WithLogger attaches the given logger to the context.
+func WithLogger(ctx context.Context, logger log.Logger) context.Context {
+	return context.WithValue(ctx, ctxKeyLogger, logger)
+}
+
+// GetLogger retrieves the attached logger from the given context, or a logger
+// discarding everything when none is attached.
+func GetLogger(ctx context.Context) log.Logger {
+	v := ctx.Value(ctxKeyLogger)
+	if v == nil {
+		return log.Empty()
+	}
+	return v.(log.Logger)
+}
+
+// SetLogger sets the logger attached to the context of the scripts run by the
+// runtime, unless the context passed to the Run methods already has one.
+func (r *Runtime) SetLogger(logger log.Logger) {
+	r.logger = logger
+}
+
+// withContext attaches the runtime and its logger to ctx, and updates the __VU
+// and __ITER globals from it.
+func (r *Runtime) withContext(ctx context.Context) context.Context {
+	r.setVU(ctx)
+	ctx = WithRuntime(ctx, r)
+	if r.logger != nil && ctx.Value(ctxKeyLogger) == nil {
+		ctx = WithLogger(ctx, r.logger)
+	}
+	return ctx
+}
@@ -2,12 +2,18 @@ package gojs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/runner-mei/gojs/js/compiler"
 	jslib "github.com/runner-mei/gojs/js/lib"
+	"github.com/runner-mei/log"
 )
 
 type runtimeCtxKey struct{}
@@ -34,6 +40,20 @@ func GetRuntime(ctx context.Context) *Runtime {
 	return v.(*Runtime)
 }
 
+// ErrRuntimeNotFound is the error of MustRuntime when no runtime is attached to the context.
+var ErrRuntimeNotFound = errors.New("runtime not found in context")
+
+// MustRuntime is like GetRuntime, but it panics with ErrRuntimeNotFound when no runtime
+// is attached to the context. The panic is thrown as a JS error when it happens in a
+// method bound with Bind, ToBindObject or Set.
+func MustRuntime(ctx context.Context) *Runtime {
+	rt := GetRuntime(ctx)
+	if rt == nil {
+		panic(ErrRuntimeNotFound)
+	}
+	return rt
+}
+
 func New() *Runtime {
 	r, err := NewWith(nil)
 	if err != nil {
@@ -56,25 +76,50 @@ func NewWith(opts *RuntimeOptions) (*Runtime, error) {
 		CompatibilityMode: compatMode,
 		Compiler:          compiler.New(),
 		Runtime:           goja.New(),
+		opts:              *opts,
+		strictGlobals:     opts.StrictGlobals,
 	}
 	rt.Runtime.SetFieldNameMapper(FieldNameMapper{})
-	rt.Runtime.SetRandSource(NewRandSource())
+	if opts.CryptoRandom {
+		if opts.Seed != nil {
+			return nil, errors.New("the seed can't be set with cryptoRandom")
+		}
+		rt.Runtime.SetRandSource(NewCryptoRandSource())
+	} else if opts.Seed != nil {
+		rt.SetSeed(*opts.Seed)
+	} else {
+		rt.Runtime.SetRandSource(NewRandSource())
+	}
 	if compatMode == compiler.CompatibilityModeExtended {
 		if _, err := rt.Runtime.RunProgram(jslib.GetCoreJS()); err != nil {
 			return nil, err
 		}
 	}
+	// core.js uses the Function constructor, so it must be disabled after it's loaded.
+	if opts.DisableDynamicCode {
+		if err := rt.DisableDynamicCode(); err != nil {
+			return nil, err
+		}
+	}
 
-	if opts.Env == nil {
-		opts.Env = map[string]string{}
+	if err := rt.bindModules(opts.Modules); err != nil {
+		return nil, err
 	}
+
+	env := opts.Env
 	if opts.IncludeSystemEnvVars {
-		for key, value := range collectEnv() {
-			opts.Env[key] = value
+		// opts.Env takes precedence over the system environment variables.
+		env = collectEnv()
+		for key, value := range opts.Env {
+			env[key] = value
 		}
+	} else if env == nil {
+		env = map[string]string{}
 	}
 
-	rt.Set("__ENV", opts.Env)
+	rt.SetEnv(env)
+	rt.defineManagedGlobals()
+	rt.setInfo()
 	return rt, nil
 }
 
@@ -95,61 +140,348 @@ func collectEnv() map[string]string {
 }
 
 type Runtime struct {
+	// runOwner is the token of the running script, or 0, see enterRun.
+	// It's accessed atomically, so it's first to be 64-bit aligned on 32-bit platforms.
+	runOwner int64
+	runMutex sync.Mutex
+
 	CompatibilityMode compiler.CompatibilityMode
 
 	*compiler.Compiler
 	*goja.Runtime
 	ctx context.Context
+
+	opts          RuntimeOptions
+	env           map[string]string
+	globals       []global
+	strictGlobals bool
+	programs      map[string]cachedProgram
+	logger        log.Logger
+
+	// Whether FreezeGlobals and DisableDynamicCode were called, to do it again in Clone.
+	frozen, dynamicCodeDisabled bool
+
+	// The values of the __ENV, __VU and __ITER globals, see defineManagedGlobals.
+	envObj *goja.Object
+	vuID   uint64
+	iter   int64
+}
+
+// global is a value set with Set or Bind, recorded to be replayed by Clone.
+type global struct {
+	name        string
+	value       interface{}
+	bind        bool
+	constructor bool
+	names       []BindNames // the names of a bound value
+}
+
+// Clone creates a new runtime with the same options, environment, logger and the
+// globals set with Set, Bind and RegisterConstructor, without compiling core.js
+// again. The clone is sandboxed like the runtime: the dynamic code is disabled in
+// it when DisableDynamicCode was called, and its globals are frozen when
+// FreezeGlobals was called.
+//
+// The Go values of the globals are shared between the clones, they are shared
+// mutable state, e.g. the buffer of a console made by NewConsole, and they must
+// be safe for concurrent use when the clones run concurrently. The consoles are
+// the exception, the clones get consoles with counters and timers of their own.
+// Anything defined by the scripts in the global scope is not copied.
+//
+// The clones get a random seed for Math.random(), even when the runtime has a
+// RuntimeOptions.Seed, unless RuntimeOptions.KeepSeedInClones is set.
+func (r *Runtime) Clone() (*Runtime, error) {
+	opts := r.opts
+	opts.Env = r.env
+	opts.IncludeSystemEnvVars = false
+	opts.Modules = nil // they are replayed with the other globals
+	opts.DisableDynamicCode = opts.DisableDynamicCode || r.dynamicCodeDisabled
+	if !opts.KeepSeedInClones {
+		opts.Seed = nil // a random one, so the clones don't repeat each other
+	}
+
+	rt, err := NewWith(&opts)
+	if err != nil {
+		return nil, err
+	}
+	rt.logger = r.logger
+	for _, g := range r.globals {
+		value := g.value
+		if v, ok := value.(runtimeCloner); ok {
+			value = v.cloneForRuntime()
+		}
+		if g.constructor {
+			rt.RegisterConstructor(g.name, value.(func(context.Context, goja.ConstructorCall) *goja.Object))
+		} else if g.bind {
+			rt.Bind(g.name, value, g.names...)
+		} else {
+			rt.Set(g.name, value)
+		}
+	}
+	if r.frozen {
+		if err := rt.FreezeGlobals(); err != nil {
+			return nil, err
+		}
+	}
+	return rt, nil
+}
+
+// runtimeCloner is implemented by the bound values keeping state of their own
+// runtime, Clone binds the value returned by cloneForRuntime instead of sharing them.
+type runtimeCloner interface {
+	cloneForRuntime() interface{}
+}
+
+func (r *Runtime) recordGlobal(name string, value interface{}, bind bool) {
+	r.addGlobal(global{name: name, value: value, bind: bind})
+}
+
+func (r *Runtime) recordConstructor(name string, fn func(context.Context, goja.ConstructorCall) *goja.Object) {
+	r.addGlobal(global{name: name, value: fn, constructor: true})
+}
+
+func (r *Runtime) addGlobal(g global) {
+	for i := range r.globals {
+		if r.globals[i].name == g.name {
+			r.globals[i] = g
+			return
+		}
+	}
+	r.globals = append(r.globals, g)
+}
+
+// setGlobal sets the global name to value, a failure, e.g. when the global was
+// frozen by FreezeGlobals, is logged since Set and Bind can't return it.
+func (r *Runtime) setGlobal(name string, value interface{}) {
+	r.logGlobalError(name, r.Runtime.GlobalObject().Set(name, value))
+}
+
+// logGlobalError logs err, if not nil, as the failure to define the global name.
+func (r *Runtime) logGlobalError(name string, err error) {
+	if err != nil && r.logger != nil {
+		r.logger.Warn("The global can't be defined", log.String("global", name), log.Error(err))
+	}
+}
+
+// defineManagedGlobals defines __ENV, __VU and __ITER as non-configurable getters
+// of the values set by SetEnv and setVU, so the scripts can neither redefine nor
+// delete them, and the runtime updates them without redefining the properties.
+func (r *Runtime) defineManagedGlobals() {
+	global := r.Runtime.GlobalObject()
+	define := func(name string, get func() interface{}) {
+		getter := r.Runtime.ToValue(func(goja.FunctionCall) goja.Value {
+			return r.Runtime.ToValue(get())
+		})
+		r.logGlobalError(name, global.DefineAccessorProperty(name, getter, nil, goja.FLAG_FALSE, goja.FLAG_TRUE))
+	}
+	define("__ENV", func() interface{} { return r.envObj })
+	define("__VU", func() interface{} { return r.vuID })
+	define("__ITER", func() interface{} { return r.iter })
+}
+
+// SetEnv replaces the object of the __ENV global with a read-only object holding
+// the given environment variables, scripts see the latest values on their next access.
+func (r *Runtime) SetEnv(env map[string]string) {
+	r.env = env
+	obj := r.Runtime.NewObject()
+	for key, value := range env {
+		_ = obj.Set(key, value)
+	}
+	freeze, _ := goja.AssertFunction(r.Runtime.Get("Object").ToObject(r.Runtime).Get("freeze"))
+	if _, err := freeze(goja.Undefined(), obj); err != nil {
+		panic(err)
+	}
+	r.envObj = obj
+}
+
+// SetSeed reseeds the source of Math.random().
+func (r *Runtime) SetSeed(seed int64) {
+	r.Runtime.SetRandSource(NewRandSourceWithSeed(seed))
 }
 
 func (r *Runtime) SetContext(ctx context.Context) {
 	r.ctx = ctx
 }
 
-// Compile the program in the given CompatibilityMode, wrapping it between pre and post code
+// context returns the context passed to the Go functions called by the scripts, it
+// always has the runtime attached, even when the functions are called directly
+// through goja instead of one of the Run methods.
+func (r *Runtime) context() context.Context {
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if GetRuntime(ctx) == nil {
+		ctx = WithRuntime(ctx, r)
+	}
+	return ctx
+}
+
+// Compile the program in the given CompatibilityMode, wrapping it between pre and post code.
+// pre and post are concatenated as is to the source, a line break in pre shifts
+// the line numbers of the source, see CompileModule for the CommonJS wrapper.
 func (r *Runtime) Compile(src, filename, pre, post string,
 	strict bool) (*goja.Program, string, error) {
 	return r.Compiler.Compile(src, filename, pre, post, strict, r.CompatibilityMode)
 }
 
 func (r *Runtime) RunString(ctx context.Context, str string) (goja.Value, error) {
-	r.ctx = WithRuntime(ctx, r)
-	return r.Runtime.RunString(str)
+	ctx, release := r.enterRun(ctx)
+	defer release()
+	r.ctx = r.withContext(ctx)
+	return wrapException(r.run(func() (goja.Value, error) {
+		return r.Runtime.RunString(str)
+	}))
 }
 
 func (r *Runtime) RunScript(ctx context.Context, name, src string) (goja.Value, error) {
-	r.ctx = WithRuntime(ctx, r)
-	return r.Runtime.RunScript(name, src)
+	ctx, release := r.enterRun(ctx)
+	defer release()
+	r.ctx = r.withContext(ctx)
+	return wrapException(r.run(func() (goja.Value, error) {
+		return r.Runtime.RunScript(name, src)
+	}))
 }
 
 func (r *Runtime) RunProgram(ctx context.Context, p *goja.Program) (goja.Value, error) {
-	r.ctx = WithRuntime(ctx, r)
-	return r.Runtime.RunProgram(p)
+	ctx, release := r.enterRun(ctx)
+	defer release()
+	r.ctx = r.withContext(ctx)
+	return wrapException(r.run(func() (goja.Value, error) {
+		return r.Runtime.RunProgram(p)
+	}))
+}
+
+// Exec compiles src in the CompatibilityMode of the runtime, with the cache of
+// the compiler if it has one, and runs it. The positions of the errors and the
+// JS stack traces are reported in filename.
+//
+// When src can't be compiled, the error is a *compiler.CompileError, when it
+// throws, the error is a *ScriptError, both can be retrieved with errors.As.
+// Compile and RunProgram are the lower level calls, e.g. to run a program
+// several times.
+func (r *Runtime) Exec(ctx context.Context, filename, src string) (goja.Value, error) {
+	pgm, _, err := r.Compile(src, filename, "", "", false)
+	if err != nil {
+		return nil, err
+	}
+	return r.RunProgram(ctx, pgm)
+}
+
+// MustRunString is like RunString but panics when the script fails, with the JS
+// stack trace of the error in the panic message. It's meant for the tests and the
+// setup code only, the failures of the scripts should be handled as errors.
+func (r *Runtime) MustRunString(ctx context.Context, str string) goja.Value {
+	return mustValue(r.RunString(ctx, str))
+}
+
+// MustRunProgram is like RunProgram but panics when the program fails, see
+// MustRunString.
+func (r *Runtime) MustRunProgram(ctx context.Context, p *goja.Program) goja.Value {
+	return mustValue(r.RunProgram(ctx, p))
 }
 
 func (r *Runtime) convertValue(value interface{}) interface{} {
 	switch i := value.(type) {
 	case func(context.Context, goja.FunctionCall) goja.Value:
 		return func(call goja.FunctionCall) goja.Value {
-			return i(r.ctx, call)
+			return i(r.context(), call)
 		}
 	case func(context.Context, goja.ConstructorCall) *goja.Object:
 		return func(call goja.ConstructorCall) *goja.Object {
-			return i(r.ctx, call)
+			return i(r.context(), call)
 		}
 	case map[string]interface{}:
+		// The members of a namespace object get the same treatment as the globals
+		// defined with Set and RegisterConstructor.
 		newValues := make(map[string]interface{}, len(i))
 		for k, v := range i {
-			newValues[k] = r.convertValue(v)
+			if fn, ok := v.(func(context.Context, goja.ConstructorCall) *goja.Object); ok {
+				newValues[k] = r.newConstructor(fn)
+			} else if isBindable(v) {
+				newValues[k] = r.ToBindObject(v)
+			} else {
+				newValues[k] = r.convertValue(v)
+			}
 		}
 		return newValues
+	case time.Time:
+		return r.dateValue(i)
 	default:
 		return value
 	}
 }
 
+// Set defines a global, the functions taking a context get the context of the running
+// script, and the structs, or pointers to structs, are bound like Bind does, so the
+// same naming and context injection rules apply to their methods, and their fields
+// are copied when Set is called.
 func (r *Runtime) Set(name string, value interface{}) {
-	r.Runtime.Set(name, r.convertValue(value))
+	r.checkGlobal(name)
+	if isBindable(value) {
+		r.setGlobal(name, r.ToBindObject(value))
+	} else {
+		r.setGlobal(name, r.convertValue(value))
+	}
+	r.recordGlobal(name, value, false)
+}
+
+// isBindable returns true if value is a struct or a pointer to a struct, other than
+// the goja values and time.Time, which is converted to a Date.
+func isBindable(value interface{}) bool {
+	if _, ok := value.(goja.Value); ok || value == nil {
+		return false
+	}
+	if _, ok := value.(time.Time); ok {
+		return false
+	}
+	typ := reflect.TypeOf(value)
+	if typ.Kind() == reflect.Ptr {
+		if reflect.ValueOf(value).IsNil() {
+			return false
+		}
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct
+}
+
+// Unbind removes the global with the given name, it is a no-op if the name isn't defined.
+func (r *Runtime) Unbind(name string) {
+	_ = r.Runtime.GlobalObject().Delete(name)
+	for i := range r.globals {
+		if r.globals[i].name == name {
+			r.globals = append(r.globals[:i], r.globals[i+1:]...)
+			break
+		}
+	}
+}
+
+// Globals returns the names of the globals set with Set, Bind and RegisterConstructor,
+// in the order they were first set.
+func (r *Runtime) Globals() []string {
+	names := make([]string, len(r.globals))
+	for i := range r.globals {
+		names[i] = r.globals[i].name
+	}
+	return names
+}
+
+// ResetGlobals removes the globals returned by Globals, the standard built-ins, core.js
+// and __ENV are kept, but a built-in replaced with Set is removed too. The globals
+// declared by the scripts themselves aren't tracked, so they aren't removed.
+func (r *Runtime) ResetGlobals() {
+	global := r.Runtime.GlobalObject()
+	for i := range r.globals {
+		_ = global.Delete(r.globals[i].name)
+	}
+	r.globals = nil
+}
+
+// checkGlobal panics if the runtime is in strict mode and name is already defined.
+func (r *Runtime) checkGlobal(name string) {
+	if r.strictGlobals && r.Runtime.Get(name) != nil {
+		panic(fmt.Errorf("global '%s' is already defined", name))
+	}
 }
 
 func (r *Runtime) ToValue(i interface{}) goja.Value {
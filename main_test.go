@@ -2,9 +2,13 @@ package gojs
 
 import (
 	"context"
+	"errors"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/dop251/goja"
+	"github.com/runner-mei/gojs/js/compiler"
 )
 
 func TestNativeCallWithContextParameter(t *testing.T) {
@@ -38,3 +42,496 @@ func TestNativeCallWithContextParameter(t *testing.T) {
 		t.Fatal(ret)
 	}
 }
+
+func TestUnbind(t *testing.T) {
+	vm := New()
+	vm.Set("a", 1)
+	vm.Unbind("a")
+
+	ret, err := vm.RunString(context.Background(), `typeof a`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "undefined" {
+		t.Fatal(ret)
+	}
+}
+
+func TestStrictGlobals(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{StrictGlobals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Set("a", 1)
+
+	defer func() {
+		if e := recover(); e == nil {
+			t.Fatal("excepted panic")
+		}
+	}()
+	vm.Bind("a", struct{}{})
+}
+
+func TestSeed(t *testing.T) {
+	seed := int64(42)
+	random := func(vm *Runtime) string {
+		ret, err := vm.RunString(context.Background(), `[Math.random(), Math.random()].join(",")`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret.String()
+	}
+
+	vm1, err := NewWith(&RuntimeOptions{Seed: &seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm2, err := NewWith(&RuntimeOptions{Seed: &seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	excepted := random(vm1)
+	if actual := random(vm2); excepted != actual {
+		t.Error("excepted", excepted, "got", actual)
+	}
+
+	vm2.SetSeed(seed)
+	if actual := random(vm2); excepted != actual {
+		t.Error("excepted", excepted, "got", actual)
+	}
+
+	// The clones are reseeded, unless they keep the seed.
+	clone1, err := vm1.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone2, err := vm1.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := random(clone1), random(clone2); a == b || a == excepted {
+		t.Error("same sequences", a, b)
+	}
+
+	vm3, err := NewWith(&RuntimeOptions{Seed: &seed, KeepSeedInClones: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone3, err := vm3.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := random(clone3); excepted != actual {
+		t.Error("excepted", excepted, "got", actual)
+	}
+}
+
+func TestCryptoRandom(t *testing.T) {
+	random := func(vm *Runtime) string {
+		ret, err := vm.RunString(context.Background(), `
+			var values = [];
+			for (var i = 0; i < 10; i++) {
+				var v = Math.random();
+				if (v < 0 || v >= 1) throw new Error("out of range: " + v);
+				values.push(v);
+			}
+			values.join(",")`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret.String()
+	}
+
+	vm1, err := NewWith(&RuntimeOptions{CryptoRandom: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm2, err := NewWith(&RuntimeOptions{CryptoRandom: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := random(vm1), random(vm2); a == b {
+		t.Error("same sequences", a)
+	}
+
+	seed := int64(42)
+	if _, err := NewWith(&RuntimeOptions{CryptoRandom: true, Seed: &seed}); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestIncludeSystemEnvVars(t *testing.T) {
+	os.Setenv("GOJS_TEST_A", "system")
+	os.Setenv("GOJS_TEST_B", "system")
+	defer os.Unsetenv("GOJS_TEST_A")
+	defer os.Unsetenv("GOJS_TEST_B")
+
+	for _, include := range []bool{false, true} {
+		vm, err := NewWith(&RuntimeOptions{
+			IncludeSystemEnvVars: include,
+			Env:                  map[string]string{"GOJS_TEST_A": "option"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ret, err := vm.RunString(context.Background(), `__ENV.GOJS_TEST_A + "," + __ENV.GOJS_TEST_B`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		excepted := "option,undefined"
+		if include {
+			excepted = "option,system"
+		}
+		if ret.String() != excepted {
+			t.Error("excepted", excepted, "got", ret.String())
+		}
+	}
+}
+
+func TestSetEnv(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{Env: map[string]string{"A": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	ret, err := vm.RunString(ctx, `__ENV.A`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "1" {
+		t.Error("excepted 1 got", ret)
+	}
+
+	vm.SetEnv(map[string]string{"A": "2"})
+	ret, err = vm.RunString(ctx, `__ENV.A = "3"; __ENV.B = "4"; __ENV = {}; __ENV.A + "," + __ENV.B`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "2,undefined" {
+		t.Error("excepted 2,undefined got", ret)
+	}
+
+	_, err = vm.RunString(ctx, `"use strict"; __ENV.A = "3"`)
+	if err == nil {
+		t.Error("excepted error")
+	}
+
+	// The global itself can't be redefined or deleted.
+	ret, err = vm.RunString(ctx, `
+		var redefined = true;
+		try { Object.defineProperty(this, "__ENV", {value: {A: "5"}}); } catch (e) { redefined = false; }
+		[redefined, delete this.__ENV, __ENV.A].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "false,false,2" {
+		t.Error("excepted false,false,2 got", ret)
+	}
+}
+
+func TestClone(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{
+		CompatibilityMode: "extended",
+		Env:               map[string]string{"A": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Set("a", 1)
+	vm.Set("b", 2)
+	vm.Unbind("b")
+
+	ctx := context.Background()
+	if _, err := vm.RunString(ctx, `var c = 3`); err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := vm.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := clone.RunString(ctx, `[__ENV.A, a, typeof b, typeof c, typeof Array.prototype.includes].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if excepted := "1,1,undefined,undefined,function"; ret.String() != excepted {
+		t.Error("excepted", excepted, "got", ret.String())
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		vm, err := NewWith(&RuntimeOptions{CompatibilityMode: "extended"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		vm.Set("a", 1)
+	}
+}
+
+func BenchmarkClone(b *testing.B) {
+	vm, err := NewWith(&RuntimeOptions{CompatibilityMode: "extended"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	vm.Set("a", 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := vm.Clone(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestScriptErrorStackTrace(t *testing.T) {
+	vm := New()
+	_, err := vm.RunScript(context.Background(), "a.js", "function f() {\n  throw new Error('boom');\n}\nf();")
+	scriptErr, ok := err.(*ScriptError)
+	if !ok {
+		t.Fatal(err)
+	}
+	if stack := scriptErr.StackTrace(); !strings.Contains(stack, "at f (a.js:2:") || !strings.Contains(stack, "a.js:4:") {
+		t.Fatal(stack)
+	}
+
+	var exc *goja.Exception
+	if !errors.As(err, &exc) || exc.Error() != err.Error() {
+		t.Fatal(err)
+	}
+}
+
+func TestMustRunString(t *testing.T) {
+	vm := New()
+	if ret := vm.MustRunString(context.Background(), `1 + 2`); ret.ToInteger() != 3 {
+		t.Fatal(ret)
+	}
+	pgm := goja.MustCompile("a.js", `3 + 4`, false)
+	if ret := vm.MustRunProgram(context.Background(), pgm); ret.ToInteger() != 7 {
+		t.Fatal(ret)
+	}
+
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "boom") || !strings.Contains(msg, "at f (") {
+			t.Fatal(msg)
+		}
+	}()
+	vm.MustRunString(context.Background(), "function f() {\n  throw new Error('boom');\n}\nf();")
+	t.Fatal("MustRunString must panic")
+}
+
+func TestExec(t *testing.T) {
+	vm := New()
+	ret, err := vm.Exec(context.Background(), "a.js", `let a = 1; a + 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.ToInteger() != 3 {
+		t.Fatal(ret)
+	}
+
+	_, err = vm.Exec(context.Background(), "b.js", "let b = 1;\nb +;")
+	var ce *compiler.CompileError
+	if !errors.As(err, &ce) {
+		t.Fatalf("%T %v", err, err)
+	}
+	if ce.Filename != "b.js" || ce.Line != 2 {
+		t.Fatal(ce.Filename, ce.Line, ce.Message)
+	}
+
+	_, err = vm.Exec(context.Background(), "c.js", "function f() {\n  throw new Error('boom');\n}\nf();")
+	var se *ScriptError
+	if !errors.As(err, &se) {
+		t.Fatalf("%T %v", err, err)
+	}
+	if trace := se.StackTrace(); !strings.Contains(trace, "boom") || !strings.Contains(trace, "at f (c.js:2:") {
+		t.Fatal(trace)
+	}
+}
+
+func TestInfoGlobal(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{CompatibilityMode: "base", MaxSteps: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := vm.RunString(context.Background(), `[
+		__GOJS.compatibilityMode, typeof __GOJS.version,
+		__GOJS.features.extended, __GOJS.features.dynamicCode, __GOJS.features.stepLimit,
+		Object.isFrozen(__GOJS), Object.isFrozen(__GOJS.features)
+	].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "base,string,false,true,true,true,true" {
+		t.Fatal(ret)
+	}
+
+	ret, err = vm.RunString(context.Background(), `__GOJS.version = "x"; __GOJS = {}; __GOJS.features.extended = true; __GOJS.compatibilityMode + "," + __GOJS.features.extended`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "base,false" {
+		t.Fatal(ret)
+	}
+}
+
+func TestRuntimeOptionsFromEnv(t *testing.T) {
+	env := map[string]string{
+		"K6_COMPATIBILITY_MODE":   "base",
+		"K6_MAX_STEPS":            "1000",
+		"K6_SEED":                 "42",
+		"K6_DISABLE_DYNAMIC_CODE": "true",
+		"K6_FILE_ROOT":            "/tmp",
+		"K6_MODULES":              "k6/data,k6/http",
+	}
+	opts, err := RuntimeOptionsFromEnv(func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.CompatibilityMode != "base" || opts.MaxSteps != 1000 || opts.Seed == nil || *opts.Seed != 42 ||
+		!opts.DisableDynamicCode || opts.FileRoot != "/tmp" || opts.StrictGlobals || opts.Env != nil ||
+		strings.Join(opts.Modules, " ") != "k6/data k6/http" {
+		t.Fatalf("%#v", opts)
+	}
+
+	env["K6_MAX_STEPS"] = "-1"
+	if _, err := RuntimeOptionsFromEnv(func(key string) string { return env[key] }); err == nil ||
+		!strings.Contains(err.Error(), "K6_MAX_STEPS") {
+		t.Fatal(err)
+	}
+}
+
+type setTestService struct {
+	Name string
+}
+
+func (s *setTestService) Greet(ctx context.Context, who string) string {
+	return s.Name + " greets " + who + " " + ctx.Value("a").(string)
+}
+
+func TestSetStruct(t *testing.T) {
+	vm := New()
+	vm.Set("service", &setTestService{Name: "gojs"})
+	vm.Set("list", []int{1, 2})
+
+	ctx := context.WithValue(context.Background(), "a", "b")
+	ret, err := vm.RunString(ctx, `service.greet("you") + "," + service.name + "," + list.length`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "gojs greets you b,gojs,2" {
+		t.Fatal(ret)
+	}
+}
+
+func TestSetNamespace(t *testing.T) {
+	vm := New()
+	vm.Set("ns", map[string]interface{}{
+		"fn": func(ctx context.Context, call goja.FunctionCall) goja.Value {
+			return vm.ToValue(ctx.Value("a"))
+		},
+		"Point": func(ctx context.Context, call goja.ConstructorCall) *goja.Object {
+			_ = call.This.Set("x", call.Argument(0))
+			return nil
+		},
+		"service": &setTestService{Name: "gojs"},
+		"nested": map[string]interface{}{
+			"service": setTestService{Name: "nested"},
+		},
+	})
+
+	ctx := context.WithValue(context.Background(), "a", "b")
+	ret, err := vm.RunString(ctx, `[
+		ns.fn(),
+		new ns.Point(1).x, new ns.Point(2) instanceof ns.Point,
+		ns.service.greet("you"), ns.nested.service.name
+	].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "b,1,true,gojs greets you b,nested" {
+		t.Fatal(ret)
+	}
+}
+
+type mustRuntimeTestService struct{}
+
+func (mustRuntimeTestService) Same(ctx context.Context) bool {
+	return MustRuntime(ctx) == GetRuntime(ctx)
+}
+
+func (mustRuntimeTestService) Detached(ctx context.Context) bool {
+	return MustRuntime(context.Background()) != nil
+}
+
+func TestMustRuntime(t *testing.T) {
+	func() {
+		defer func() {
+			if r := recover(); r != ErrRuntimeNotFound {
+				t.Fatal("want ErrRuntimeNotFound, got", r)
+			}
+		}()
+		MustRuntime(context.Background())
+	}()
+
+	vm := New()
+	vm.Set("svc", mustRuntimeTestService{})
+
+	// Calling through goja directly still passes a context with the runtime.
+	ret, err := vm.Runtime.RunString(`svc.same()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ret.ToBoolean() {
+		t.Fatal("runtime is missing from the context")
+	}
+
+	_, err = vm.RunString(context.Background(), `svc.detached()`)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if !strings.Contains(err.Error(), "runtime not found in context") {
+		t.Fatal(err)
+	}
+}
+
+func TestResetGlobals(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{CompatibilityMode: "extended"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Set("a", 1)
+	vm.Bind("svc", &setTestService{Name: "gojs"})
+	vm.Set("b", 2)
+	vm.Set("a", 3)
+	if names := strings.Join(vm.Globals(), ","); names != "a,svc,b" {
+		t.Fatal(names)
+	}
+
+	vm.ResetGlobals()
+	if names := vm.Globals(); len(names) != 0 {
+		t.Fatal(names)
+	}
+	ctx := context.Background()
+	ret, err := vm.RunString(ctx, `[typeof a, typeof svc, typeof b, typeof JSON, typeof __ENV,
+		typeof Array.prototype.includes].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "undefined,undefined,undefined,object,object,function" {
+		t.Fatal(ret)
+	}
+
+	vm.Set("a", 4)
+	ret, err = vm.RunString(ctx, `a`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.ToInteger() != 4 {
+		t.Fatal(ret)
+	}
+}
@@ -0,0 +1,67 @@
+package gojs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	// ModulePrefix and ModuleSuffix are the CommonJS wrapper applied by
+	// CompileModule. The prefix has no line break, so the line numbers of the
+	// module source are kept, only the columns of its first line are shifted.
+	// The suffix starts with a line break, so a trailing line comment in the
+	// source can't comment it out.
+	ModulePrefix = "(function(module, exports, require){"
+	ModuleSuffix = "\n})"
+)
+
+// ModuleFunc runs the body of a module compiled by CompileModule. The module
+// object should hold the exports object in its exports property, like the
+// one created by NewModule, and require may be undefined if the module
+// doesn't import anything. It returns the module.exports value set by the body.
+type ModuleFunc func(ctx context.Context, module *goja.Object, require goja.Value) (goja.Value, error)
+
+// NewModule returns a fresh module object with an empty exports object.
+func (r *Runtime) NewModule() *goja.Object {
+	module := r.Runtime.NewObject()
+	_ = module.Set("exports", r.Runtime.NewObject())
+	return module
+}
+
+// CompileModule compiles src wrapped in the CommonJS module wrapper, see
+// ModulePrefix, and returns a function running the module body. The module
+// can be run several times, each time with a fresh module object.
+func (r *Runtime) CompileModule(filename, src string) (ModuleFunc, error) {
+	pgm, _, err := r.Compile(src, filename, ModulePrefix, ModuleSuffix, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, module *goja.Object, require goja.Value) (goja.Value, error) {
+		ctx, release := r.enterRun(ctx)
+		defer release()
+		v, err := r.RunProgram(ctx, pgm)
+		if err != nil {
+			return nil, err
+		}
+		r.ctx = r.withContext(ctx)
+		fn, ok := goja.AssertFunction(v)
+		if !ok {
+			return nil, fmt.Errorf("module '%s' didn't compile to a function", filename)
+		}
+		if require == nil {
+			require = goja.Undefined()
+		}
+
+		exports := module.Get("exports")
+		_, err = wrapException(r.run(func() (goja.Value, error) {
+			return fn(exports, module, exports, require)
+		}))
+		if err != nil {
+			return nil, err
+		}
+		return module.Get("exports"), nil
+	}, nil
+}
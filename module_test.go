@@ -0,0 +1,47 @@
+package gojs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestCompileModule(t *testing.T) {
+	vm := New()
+	run, err := vm.CompileModule("a.js", "exports.a = 1;\nmodule.exports.b = require('b'); // comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	require := vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		return call.Argument(0)
+	})
+	for i := 0; i < 2; i++ {
+		exports, err := run(context.Background(), vm.NewModule(), require)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj := exports.ToObject(vm.Runtime)
+		if obj.Get("a").ToInteger() != 1 || obj.Get("b").String() != "b" {
+			t.Fatal(obj.Export())
+		}
+	}
+}
+
+func TestCompileModuleLineNumbers(t *testing.T) {
+	vm := New()
+	run, err := vm.CompileModule("a.js", "var a = 1;\nthrow new Error('boom');")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = run(context.Background(), vm.NewModule(), nil)
+	if err == nil {
+		t.Fatal("excepted error")
+	}
+	if !strings.Contains(err.Error(), "a.js:2:") {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+	"github.com/runner-mei/gojs"
+	"github.com/runner-mei/gojs/modules/k6/internal/modules"
+)
+
+func init() {
+	modules.Register("k6/cache", New())
+}
+
+const sharedNamePrefix = "k6/cache/"
+
+// Cache is a read-through cache shared by all the runtimes of an init environment,
+// e.g. all the VUs.
+type Cache struct{}
+
+func New() *Cache {
+	return &Cache{}
+}
+
+// entry is a cached value, shared with SharedObjects. The value is stored as JSON, so
+// every caller gets its own copy, and the loaded values must be JSON-serializable,
+// like the elements of a SharedArray.
+type entry struct {
+	mutex       sync.Mutex
+	loaded      bool
+	json        string
+	isUndefined bool
+}
+
+// Get returns the value cached under key, calling loader to get it the first time.
+// Under contention the loader runs once, the other callers wait for it. When the
+// loader throws, the error is returned to its caller only, and the next call of
+// Get runs the loader again. The loader must not get its own key.
+func (*Cache) Get(ctx context.Context, key string, loader goja.Callable) (goja.Value, error) {
+	rt := gojs.MustRuntime(ctx)
+	initEnv := gojs.GetInitEnv(ctx)
+	if initEnv == nil {
+		return nil, errors.New("missing init environment")
+	}
+	if loader == nil {
+		return nil, errors.New("the loader of cache.get() must be a function")
+	}
+
+	e := initEnv.SharedObjects.GetOrCreateShare(sharedNamePrefix+key, func() interface{} {
+		return &entry{}
+	}).(*entry)
+
+	// The lock of the entry, rather than the one of SharedObjects, is held while
+	// loading, so the other keys aren't blocked.
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.loaded {
+		value, err := loader(goja.Undefined())
+		if err != nil {
+			return nil, err
+		}
+		s, err := jsonCall(rt, "stringify", value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "the value of the key '%s' can't be serialized to JSON", key)
+		}
+		// JSON.stringify returns undefined for undefined and the functions.
+		e.isUndefined = goja.IsUndefined(s)
+		e.json = s.String()
+		e.loaded = true
+	}
+
+	if e.isUndefined {
+		return goja.Undefined(), nil
+	}
+	return jsonCall(rt, "parse", rt.Runtime.ToValue(e.json))
+}
+
+// jsonCall calls the method of the JSON object of the runtime.
+func jsonCall(rt *gojs.Runtime, method string, arg goja.Value) (goja.Value, error) {
+	json := rt.Runtime.Get("JSON").ToObject(rt.Runtime)
+	fn, ok := goja.AssertFunction(json.Get(method))
+	if !ok {
+		return nil, errors.Errorf("JSON.%s is not a function", method)
+	}
+	return fn(json, arg)
+}
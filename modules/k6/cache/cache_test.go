@@ -0,0 +1,99 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2017 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runner-mei/gojs"
+)
+
+func newTestRuntime(t *testing.T, loads *int64) *gojs.Runtime {
+	rt := gojs.New()
+	rt.Bind("cache", New())
+	rt.Set("load", func() int64 {
+		time.Sleep(50 * time.Millisecond)
+		return atomic.AddInt64(loads, 1)
+	})
+	return rt
+}
+
+func TestCacheGet(t *testing.T) {
+	t.Parallel()
+	ctx := gojs.WithInitEnv(context.Background(), &gojs.InitEnvironment{
+		SharedObjects: gojs.NewSharedObjects(),
+	})
+	var loads int64
+
+	const vus = 10
+	var wg sync.WaitGroup
+	results := make([]int64, vus)
+	errs := make([]error, vus)
+	for i := 0; i < vus; i++ {
+		rt := newTestRuntime(t, &loads)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := rt.RunString(ctx, `
+				var v = cache.get("config", function() { return {n: load(), list: [1, 2]}; });
+				v.list.push(3); // every caller gets its own copy
+				cache.get("config", function() { throw new Error("cached") }).list.length * 100 + v.n`)
+			errs[i] = err
+			if err == nil {
+				results[i] = v.ToInteger()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&loads))
+	for i := 0; i < vus; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, int64(201), results[i])
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		rt := newTestRuntime(t, &loads)
+		_, err := rt.RunString(ctx, `cache.get("failing", function() { throw new Error("no luck") })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no luck")
+
+		// The failed load isn't cached.
+		v, err := rt.RunString(ctx, `cache.get("failing", function() { return "ok" })`)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", v.String())
+
+		v, err = rt.RunString(ctx, `typeof cache.get("undefined", function() {})`)
+		require.NoError(t, err)
+		assert.Equal(t, "undefined", v.String())
+
+		_, err = rt.RunString(ctx, `var o = {}; o.o = o; cache.get("circular", function() { return o })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the value of the key 'circular' can't be serialized to JSON")
+	})
+}
@@ -68,60 +68,62 @@ func (*Crypto) RandomBytes(ctx context.Context, size int) []byte {
 }
 
 func (c *Crypto) Md4(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "md4")
+	hasher, _ := c.CreateHash(ctx, "md4")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
 func (c *Crypto) Md5(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "md5")
+	hasher, _ := c.CreateHash(ctx, "md5")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
 func (c *Crypto) Sha1(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "sha1")
+	hasher, _ := c.CreateHash(ctx, "sha1")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
 func (c *Crypto) Sha256(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "sha256")
+	hasher, _ := c.CreateHash(ctx, "sha256")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
 func (c *Crypto) Sha384(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "sha384")
+	hasher, _ := c.CreateHash(ctx, "sha384")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
 func (c *Crypto) Sha512(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "sha512")
+	hasher, _ := c.CreateHash(ctx, "sha512")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
 func (c *Crypto) Sha512_224(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "sha512_224")
+	hasher, _ := c.CreateHash(ctx, "sha512_224")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
 func (c *Crypto) Sha512_256(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "sha512_256")
+	hasher, _ := c.CreateHash(ctx, "sha512_256")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
 func (c *Crypto) Ripemd160(ctx context.Context, input []byte, outputEncoding string) interface{} {
-	hasher := c.CreateHash(ctx, "ripemd160")
+	hasher, _ := c.CreateHash(ctx, "ripemd160")
 	hasher.Update(input)
 	return hasher.Digest(outputEncoding)
 }
 
-func (*Crypto) CreateHash(ctx context.Context, algorithm string) *Hasher {
+// CreateHash returns a hasher of the given algorithm, e.g. "sha256", or an error
+// when the algorithm isn't supported.
+func (*Crypto) CreateHash(ctx context.Context, algorithm string) (*Hasher, error) {
 	hasher := Hasher{}
 	hasher.ctx = ctx
 
@@ -144,9 +146,11 @@ func (*Crypto) CreateHash(ctx context.Context, algorithm string) *Hasher {
 		hasher.hash = sha512.New()
 	case "ripemd160":
 		hasher.hash = ripemd160.New()
+	default:
+		return nil, errors.New("Invalid algorithm: " + algorithm)
 	}
 
-	return &hasher
+	return &hasher, nil
 }
 
 func (hasher *Hasher) Update(input []byte) {
@@ -183,6 +187,11 @@ func (hasher *Hasher) Digest(outputEncoding string) interface{} {
 	return ""
 }
 
+// Equals compares two strings or byte arrays, e.g. signatures, in a constant time.
+func (c Crypto) Equals(_ context.Context, a, b []byte) bool {
+	return hmac.Equal(a, b)
+}
+
 // HexEncode returns a string with the hex representation of the provided byte array
 func (c Crypto) HexEncode(_ context.Context, data []byte) string {
 	return hex.EncodeToString(data)
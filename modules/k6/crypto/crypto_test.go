@@ -403,6 +403,40 @@ func TestHMac(t *testing.T) {
 	}
 }
 
+func TestInvalidHashAlgorithm(t *testing.T) {
+	rt := gojs.New()
+	rt.SetFieldNameMapper(gojs.FieldNameMapper{})
+	ctx := context.Background()
+	rt.Bind("crypto", New())
+
+	_, err := rt.RunString(ctx, `crypto.createHash("md6");`)
+	assert.Contains(t, err.Error(), "GoError: Invalid algorithm: md6")
+
+	// It's an error off the scripts as well, not a panic.
+	_, err = New().CreateHash(ctx, "md6")
+	assert.EqualError(t, err, "Invalid algorithm: md6")
+}
+
+func TestEquals(t *testing.T) {
+	rt := gojs.New()
+	rt.SetFieldNameMapper(gojs.FieldNameMapper{})
+	ctx := context.Background()
+	rt.Bind("crypto", New())
+
+	_, err := rt.RunString(ctx, `
+	var signature = crypto.hmac("sha256", "a secret", "some data", "hex");
+	if (!crypto.equals(signature, crypto.hmac("sha256", "a secret", "some data", "hex"))) {
+		throw new Error("equal signatures are different");
+	}
+	if (crypto.equals(signature, crypto.hmac("sha256", "another secret", "some data", "hex"))) {
+		throw new Error("different signatures are equal");
+	}
+	if (crypto.equals("abc", "abcd")) {
+		throw new Error("strings of different lengths are equal");
+	}`)
+	assert.NoError(t, err)
+}
+
 func TestAWSv4(t *testing.T) {
 	// example values from https://docs.aws.amazon.com/general/latest/gr/signature-v4-examples.html
 	rt := gojs.New()
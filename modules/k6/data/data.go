@@ -22,10 +22,13 @@ package data
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 
 	"github.com/dop251/goja"
 	"github.com/pkg/errors"
 	"github.com/runner-mei/gojs"
+	"github.com/runner-mei/gojs/lib"
 	"github.com/runner-mei/gojs/modules/k6/internal/modules"
 )
 
@@ -40,10 +43,11 @@ const sharedArrayNamePrefix = "k6/data/SharedArray."
 // XSharedArray is a constructor returning a shareable read-only array
 // indentified by the name and having their contents be whatever the call returns
 func (d *data) XSharedArray(ctx context.Context, name string, call goja.Callable) (goja.Value, error) {
-	// if lib.GetState(ctx) != nil {
-	// 	return nil, errors.New("new SharedArray must be called in the init context")
-	// }
+	if lib.GetState(ctx) != nil {
+		return nil, errors.New("new SharedArray must be called in the init context")
+	}
 
+	rt := gojs.MustRuntime(ctx)
 	initEnv := gojs.GetInitEnv(ctx)
 	if initEnv == nil {
 		return nil, errors.New("missing init environment")
@@ -54,14 +58,14 @@ func (d *data) XSharedArray(ctx context.Context, name string, call goja.Callable
 
 	name = sharedArrayNamePrefix + name
 	value := initEnv.SharedObjects.GetOrCreateShare(name, func() interface{} {
-		return getShareArrayFromCall(ctx, gojs.GetRuntime(ctx), call)
+		return getShareArrayFromCall(ctx, rt, call)
 	})
 	array, ok := value.(sharedArray)
 	if !ok { // TODO more info in the error?
 		return nil, errors.New("wrong type of shared object")
 	}
 
-	return array.wrap(ctx, gojs.GetRuntime(ctx)), nil
+	return array.wrap(ctx, rt), nil
 }
 
 func getShareArrayFromCall(ctx context.Context, rt *gojs.Runtime, call goja.Callable) sharedArray {
@@ -77,9 +81,21 @@ func getShareArrayFromCall(ctx context.Context, rt *gojs.Runtime, call goja.Call
 
 	// We specifically use JSON.stringify here as we need to use JSON.parse on the way out
 	// it also has the benefit of needing only one loop and being more JS then using golang's json
+	// The failures tell the index of the element, with a hint of the likely cause.
 	cal, err := rt.RunString(ctx, `(function(input, output) {
 		for (var i = 0; i < input.length; i++) {
-			output[i] = JSON.stringify(input[i])
+			try {
+				output[i] = JSON.stringify(input[i])
+			} catch (e) {
+				var msg = String(e && e.message || e), hint = "it contains a value JSON can't represent";
+				if (/circular/i.test(msg)) {
+					hint = "it contains a circular reference";
+				} else if (/bigint/i.test(msg)) {
+					hint = "BigInt values aren't supported";
+				}
+				throw new TypeError("the element " + i + " of the SharedArray can't be serialized to JSON, " +
+					hint + ": " + msg);
+			}
 		}
 	})`)
 	if err != nil {
@@ -92,3 +108,57 @@ func getShareArrayFromCall(ctx context.Context, rt *gojs.Runtime, call goja.Call
 	}
 	return sharedArray{arr: arr}
 }
+
+const openFilePrefix = "k6/data/open."
+
+// Open reads the file at path through the file system of the init environment,
+// it returns a string or, in the "b" mode, an ArrayBuffer. The paths are
+// resolved and confined by InitEnvironment.Open. The contents are read once and
+// shared between the VUs, every call returns its own copy of them.
+func (d *data) Open(ctx context.Context, path string, mode ...string) (goja.Value, error) {
+	rt := gojs.MustRuntime(ctx)
+	initEnv := gojs.GetInitEnv(ctx)
+	if initEnv == nil {
+		return nil, errors.New("missing init environment")
+	}
+	if len(path) == 0 {
+		return nil, errors.New("open() can't be used with an empty filename")
+	}
+
+	binary := false
+	if len(mode) > 0 {
+		switch mode[0] {
+		case "":
+		case "b":
+			binary = true
+		default:
+			return nil, fmt.Errorf("unknown open mode '%s', it needs to be empty or 'b'", mode[0])
+		}
+	}
+
+	name, err := initEnv.ResolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := initEnv.SharedObjects.TryGetOrCreateShare(openFilePrefix+name, func() (interface{}, error) {
+		f, err := initEnv.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ioutil.ReadAll(f)
+	})
+	if err != nil {
+		return nil, err
+	}
+	bs, ok := value.([]byte)
+	if !ok {
+		return nil, errors.New("wrong type of shared object")
+	}
+
+	if !binary {
+		return rt.ToValue(string(bs)), nil
+	}
+	return rt.ToValue(rt.NewArrayBuffer(append([]byte(nil), bs...))), nil
+}
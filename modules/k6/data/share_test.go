@@ -22,10 +22,14 @@ package data
 
 import (
 	"context"
+	"errors"
+	"net/url"
 	"testing"
+	"testing/fstest"
 
 	"github.com/dop251/goja"
 	"github.com/runner-mei/gojs"
+	"github.com/runner-mei/gojs/lib"
 	"github.com/stretchr/testify/require"
 )
 
@@ -69,6 +73,10 @@ func TestSharedArrayConstructorExceptions(t *testing.T) {
 			code: `new SharedArray("", function() {return []});`,
 			err:  "empty name provided to SharedArray's constructor",
 		},
+		"circular reference": {
+			code: `var o = {}; o.self = o; new SharedArray("circular", function() {return [1, 2, o]});`,
+			err:  "the element 2 of the SharedArray can't be serialized to JSON, it contains a circular reference",
+		},
 		"function in the data": {
 			code: `
 			var s = new SharedArray("wat2", function() {return [{s: function() {}}]});
@@ -90,12 +98,41 @@ func TestSharedArrayConstructorExceptions(t *testing.T) {
 			}
 
 			require.Error(t, err)
-			exc := err.(*goja.Exception)
+			var exc *goja.Exception
+			require.True(t, errors.As(err, &exc))
 			require.Contains(t, exc.Error(), testCase.err)
 		})
 	}
 }
 
+func TestSharedArrayInitContext(t *testing.T) {
+	t.Parallel()
+	initEnv := &gojs.InitEnvironment{
+		SharedObjects: gojs.NewSharedObjects(),
+	}
+	ctx := gojs.WithInitEnv(context.Background(), initEnv)
+	rt, err := newConfiguredRuntime(ctx, initEnv)
+	require.NoError(t, err)
+
+	// The init context has no VU state.
+	_, err = rt.RunString(ctx, makeArrayScript)
+	require.NoError(t, err)
+
+	// The check comes before the lookup of the shared objects, so a name already
+	// created in the init context is rejected too.
+	runCtx := lib.WithState(ctx, &lib.State{})
+	for _, name := range []string{"shared", "new"} {
+		_, err = rt.RunString(runCtx, `new data.SharedArray("`+name+`", function() { return [1]; })`)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "new SharedArray must be called in the init context")
+	}
+
+	// The array created in the init context is still usable during the run.
+	v, err := rt.RunString(runCtx, `array.length`)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), v.ToInteger())
+}
+
 func TestSharedArrayAnotherRuntimeExceptions(t *testing.T) {
 	t.Parallel()
 
@@ -146,7 +183,8 @@ func TestSharedArrayAnotherRuntimeExceptions(t *testing.T) {
 			}
 
 			require.Error(t, err)
-			exc := err.(*goja.Exception)
+			var exc *goja.Exception
+			require.True(t, errors.As(err, &exc))
 			require.Contains(t, exc.Error(), testCase.err)
 		})
 	}
@@ -188,3 +226,118 @@ func TestSharedArrayAnotherRuntimeWorking(t *testing.T) {
 	`)
 	require.NoError(t, err)
 }
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"data.txt": &fstest.MapFile{Data: []byte("hi!")},
+	}
+
+	initEnv := &gojs.InitEnvironment{
+		FileSystem:    fsys,
+		CWD:           &url.URL{Scheme: "file", Path: "/path/to/"},
+		SharedObjects: gojs.NewSharedObjects(),
+	}
+	ctx := gojs.WithInitEnv(context.Background(), initEnv)
+	rt, err := newConfiguredRuntime(ctx, initEnv)
+	require.NoError(t, err)
+
+	t.Run("text", func(t *testing.T) {
+		v, err := rt.RunString(ctx, `data.open("data.txt")`)
+		require.NoError(t, err)
+		require.Equal(t, "hi!", v.Export())
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		v, err := rt.RunString(ctx, `
+		var buf = data.open("/path/to/data.txt", "b");
+		var bytes = new Uint8Array(buf);
+		bytes[0] = 72;
+		String.fromCharCode.apply(null, bytes);`)
+		require.NoError(t, err)
+		require.Equal(t, "Hi!", v.Export())
+
+		// The changes of an ArrayBuffer aren't seen by the other callers.
+		v, err = rt.RunString(ctx, `data.open("data.txt")`)
+		require.NoError(t, err)
+		require.Equal(t, "hi!", v.Export())
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		fsys["data.txt"] = &fstest.MapFile{Data: []byte("changed")}
+		rt, err := newConfiguredRuntime(ctx, initEnv)
+		require.NoError(t, err)
+		v, err := rt.RunString(ctx, `data.open("./data.txt")`)
+		require.NoError(t, err)
+		require.Equal(t, "hi!", v.Export())
+	})
+
+	cases := map[string]struct {
+		code, err string
+	}{
+		"missing file": {
+			code: `data.open("missing.txt")`,
+			err:  "file does not exist",
+		},
+		"outside of the root": {
+			code: `data.open("../secret.txt")`,
+			err:  "file '../secret.txt' is outside of the root '/path/to/'",
+		},
+		"absolute path outside of the root": {
+			code: `data.open("/path/secret.txt", "b")`,
+			err:  "file '/path/secret.txt' is outside of the root '/path/to/'",
+		},
+		"unknown mode": {
+			code: `data.open("data.txt", "x")`,
+			err:  "unknown open mode 'x'",
+		},
+		"empty filename": {
+			code: `data.open("")`,
+			err:  "open() can't be used with an empty filename",
+		},
+	}
+	for name, testCase := range cases {
+		name, testCase := name, testCase
+		t.Run(name, func(t *testing.T) {
+			_, err := rt.RunString(ctx, testCase.code)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), testCase.err)
+		})
+	}
+}
+
+func TestRuntimeOptionsModules(t *testing.T) {
+	t.Parallel()
+	ctx := gojs.WithInitEnv(context.Background(), &gojs.InitEnvironment{
+		SharedObjects: gojs.NewSharedObjects(),
+	})
+
+	rt, err := gojs.NewWith(&gojs.RuntimeOptions{Modules: []string{"k6/data"}})
+	require.NoError(t, err)
+	v, err := rt.RunString(ctx, makeArrayScript+`array.length + "," + array[1].value`)
+	require.NoError(t, err)
+	require.Equal(t, "50,something1", v.String())
+
+	// The modules are bound again by the clones.
+	clone, err := rt.Clone()
+	require.NoError(t, err)
+	v, err = clone.RunString(ctx, `typeof data.SharedArray`)
+	require.NoError(t, err)
+	require.Equal(t, "function", v.String())
+
+	_, err = gojs.NewWith(&gojs.RuntimeOptions{Modules: []string{"k6/data", "k6/unknown"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown module: k6/unknown, the registered modules are: ")
+	require.Contains(t, err.Error(), "k6/data")
+}
+
+func TestLoadModule(t *testing.T) {
+	t.Parallel()
+	rt := gojs.New()
+	require.NoError(t, rt.LoadModule("k6/data"))
+	v, err := rt.RunString(context.Background(), `typeof data.SharedArray`)
+	require.NoError(t, err)
+	require.Equal(t, "function", v.String())
+
+	require.EqualError(t, rt.LoadModule("k6/unknown"), "unknown module: k6/unknown")
+}
@@ -23,7 +23,14 @@ package encoding
 import (
 	"context"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 
+	"github.com/dop251/goja"
 	"github.com/runner-mei/gojs"
 	"github.com/runner-mei/gojs/modules/k6/internal/modules"
 )
@@ -32,28 +39,96 @@ func init() {
 	modules.Register("k6/encoding", New())
 }
 
-type Encoding struct{}
+type Encoding struct {
+	// Hex is exposed as encoding.hex.encode() and encoding.hex.decode().
+	Hex *Hex
+}
 
 func New() *Encoding {
-	return &Encoding{}
+	return &Encoding{Hex: &Hex{}}
+}
+
+// Hex encodes and decodes hexadecimal strings.
+type Hex struct{}
+
+// Encode returns the lowercase hexadecimal encoding of a string or an ArrayBuffer.
+func (*Hex) Encode(input goja.Value) (string, error) {
+	data, err := toBytes(input)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// Decode returns the string decoded from a hexadecimal string, or an ArrayBuffer
+// when format is "b", for the binary data which isn't valid UTF-8.
+func (*Hex) Decode(ctx context.Context, input string, format string) (goja.Value, error) {
+	data, err := hex.DecodeString(input)
+	if err != nil {
+		return nil, err
+	}
+	return decoded(gojs.GetRuntime(ctx), data, format), nil
+}
+
+// decoded returns data as a string, or as an ArrayBuffer when format is "b".
+func decoded(rt *gojs.Runtime, data []byte, format string) goja.Value {
+	if format == "b" {
+		return rt.ToValue(rt.NewArrayBuffer(data))
+	}
+	return rt.ToValue(string(data))
 }
 
-func (e *Encoding) B64encode(ctx context.Context, input []byte, encoding string) string {
+// toBytes returns the bytes of a string, an ArrayBuffer or an array of bytes.
+func toBytes(input goja.Value) ([]byte, error) {
+	if input == nil || goja.IsUndefined(input) || goja.IsNull(input) {
+		return nil, nil
+	}
+	switch v := input.Export().(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case goja.ArrayBuffer:
+		return v.Bytes(), nil
+	case []interface{}:
+		data := make([]byte, len(v))
+		for i, b := range v {
+			n, ok := b.(int64)
+			if !ok || n < 0 || n > 255 {
+				return nil, fmt.Errorf("invalid byte %v at index %d", b, i)
+			}
+			data[i] = byte(n)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("invalid type %T, it needs to be a string or an ArrayBuffer", v)
+	}
+}
+
+// B64encode returns the base64 encoding of a string or an ArrayBuffer.
+func (e *Encoding) B64encode(input goja.Value, encoding string) (string, error) {
+	data, err := toBytes(input)
+	if err != nil {
+		return "", err
+	}
+
 	switch encoding {
 	case "rawstd":
-		return base64.StdEncoding.WithPadding(base64.NoPadding).EncodeToString(input)
+		return base64.StdEncoding.WithPadding(base64.NoPadding).EncodeToString(data), nil
 	case "std":
-		return base64.StdEncoding.EncodeToString(input)
+		return base64.StdEncoding.EncodeToString(data), nil
 	case "rawurl":
-		return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(input)
+		return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(data), nil
 	case "url":
-		return base64.URLEncoding.EncodeToString(input)
+		return base64.URLEncoding.EncodeToString(data), nil
 	default:
-		return base64.StdEncoding.EncodeToString(input)
+		return base64.StdEncoding.EncodeToString(data), nil
 	}
 }
 
-func (e *Encoding) B64decode(ctx context.Context, input string, encoding string) string {
+// B64decode returns the string decoded from a base64 string, or an ArrayBuffer
+// when format is "b", see Hex.Decode.
+func (e *Encoding) B64decode(ctx context.Context, input string, encoding string, format string) (goja.Value, error) {
 	var output []byte
 	var err error
 
@@ -71,8 +146,117 @@ func (e *Encoding) B64decode(ctx context.Context, input string, encoding string)
 	}
 
 	if err != nil {
-		gojs.Throw(gojs.GetRuntime(ctx), err)
+		return nil, err
+	}
+	return decoded(gojs.GetRuntime(ctx), output, format), nil
+}
+
+// ParseJSONBig parses a JSON text like JSON.parse(), without its loss of precision on
+// the large integers, e.g. 64-bit IDs: the integers out of the safe range of a JS
+// number, ±(2^53-1), become big number objects, whose value property holds the
+// exact digits. Their toString() and valueOf() return the digits, so they're equal
+// (==) to the string of the digits, and JSON.stringify() writes them as strings,
+// since a JS number can't hold them. The other numbers are parsed as JSON.parse()
+// does, and the keys of the objects keep their order.
+func (e *Encoding) ParseJSONBig(ctx context.Context, text string) (goja.Value, error) {
+	rt := gojs.GetRuntime(ctx)
+	p := &bigJSONParser{rt: rt, dec: json.NewDecoder(strings.NewReader(text))}
+	p.dec.UseNumber()
+
+	v, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after the top-level JSON value")
+	}
+	return v, nil
+}
+
+// maxSafeInteger is Number.MAX_SAFE_INTEGER, the larger integers lose precision in a JS number.
+const maxSafeInteger = 1<<53 - 1
+
+type bigJSONParser struct {
+	rt       *gojs.Runtime
+	dec      *json.Decoder
+	bigProto *goja.Object
+}
+
+func (p *bigJSONParser) parse() (goja.Value, error) {
+	tok, err := p.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			obj := p.rt.Runtime.NewObject()
+			for p.dec.More() {
+				key, err := p.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := p.parse()
+				if err != nil {
+					return nil, err
+				}
+				// Defined rather than set, so a "__proto__" key is an own property
+				// like with JSON.parse() instead of replacing the prototype.
+				if err := obj.DefineDataProperty(key.(string), v, goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_TRUE); err != nil {
+					return nil, err
+				}
+			}
+			_, err = p.dec.Token() // }
+			return obj, err
+		default: // '['
+			var items []interface{}
+			for p.dec.More() {
+				v, err := p.parse()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, v)
+			}
+			_, err = p.dec.Token() // ]
+			return p.rt.Runtime.NewArray(items...), err
+		}
+	case json.Number:
+		return p.number(tok)
+	case nil:
+		return goja.Null(), nil
+	default: // string or bool
+		return p.rt.Runtime.ToValue(tok), nil
+	}
+}
+
+func (p *bigJSONParser) number(n json.Number) (goja.Value, error) {
+	if i, err := n.Int64(); err == nil && i >= -maxSafeInteger && i <= maxSafeInteger {
+		return p.rt.Runtime.ToValue(i), nil
+	}
+	if !strings.ContainsAny(n.String(), ".eE") {
+		return p.bigNumber(n.String()), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
 	}
+	return p.rt.Runtime.ToValue(f), nil
+}
 
-	return string(output)
+func (p *bigJSONParser) bigNumber(digits string) goja.Value {
+	if p.bigProto == nil {
+		value := func(call goja.FunctionCall) goja.Value {
+			return call.This.ToObject(p.rt.Runtime).Get("value")
+		}
+		p.bigProto = p.rt.Runtime.NewObject()
+		_ = p.bigProto.Set("toString", value)
+		_ = p.bigProto.Set("valueOf", value)
+		_ = p.bigProto.Set("toJSON", value)
+	}
+	obj := p.rt.Runtime.NewObject()
+	_ = obj.SetPrototype(p.bigProto)
+	_ = obj.DefineDataProperty("value", p.rt.Runtime.ToValue(digits), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	return obj
 }
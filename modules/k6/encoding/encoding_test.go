@@ -147,5 +147,141 @@ func TestEncodingAlgorithms(t *testing.T) {
 			}`)
 			assert.NoError(t, err)
 		})
+		t.Run("ArrayBufferEnc", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			var correct = "AAH/";
+			var encoded = encoding.b64encode(new Uint8Array([0, 1, 255]).buffer);
+			if (encoded !== correct) {
+				throw new Error("Encoding mismatch: " + encoded);
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("InvalidEnc", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			try {
+				encoding.b64encode({});
+				throw new Error("no error thrown");
+			} catch (e) {
+				if (e.message === "no error thrown") {
+					throw e;
+				}
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("BinaryDec", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			var encoded = encoding.b64encode(new Uint8Array([0, 1, 255]).buffer);
+			var decoded = new Uint8Array(encoding.b64decode(encoded, "std", "b"));
+			if (decoded.join() !== "0,1,255") {
+				throw new Error("Decoding mismatch: " + decoded.join());
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("InvalidDec", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			try {
+				encoding.b64decode("!!!");
+				throw new Error("no error thrown");
+			} catch (e) {
+				if (e.message === "no error thrown") {
+					throw e;
+				}
+			}`)
+			assert.NoError(t, err)
+		})
+	})
+
+	t.Run("Hex", func(t *testing.T) {
+		t.Run("Enc", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			var correct = "68656c6c6f20776f726c64";
+			var encoded = encoding.hex.encode("hello world");
+			if (encoded !== correct) {
+				throw new Error("Encoding mismatch: " + encoded);
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("ArrayBufferEnc", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			var correct = "0001ff";
+			var encoded = encoding.hex.encode(new Uint8Array([0, 1, 255]).buffer);
+			if (encoded !== correct) {
+				throw new Error("Encoding mismatch: " + encoded);
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("Dec", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			var correct = "hello world";
+			var decoded = encoding.hex.decode("68656C6C6F20776F726C64");
+			if (decoded !== correct) {
+				throw new Error("Decoding mismatch: " + decoded);
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("BinaryDec", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			var decoded = new Uint8Array(encoding.hex.decode(encoding.hex.encode(new Uint8Array([255]).buffer), "b"));
+			if (decoded.length !== 1 || decoded[0] !== 255) {
+				throw new Error("Decoding mismatch: " + decoded.join());
+			}`)
+			assert.NoError(t, err)
+		})
+		t.Run("InvalidDec", func(t *testing.T) {
+			_, err := rt.RunString(ctx, `
+			try {
+				encoding.hex.decode("xyz");
+				throw new Error("no error thrown");
+			} catch (e) {
+				if (e.message === "no error thrown") {
+					throw e;
+				}
+			}`)
+			assert.NoError(t, err)
+		})
 	})
 }
+
+func TestParseJSONBig(t *testing.T) {
+	rt := gojs.New()
+	rt.SetFieldNameMapper(gojs.FieldNameMapper{})
+	ctx := context.Background()
+	rt.Bind("encoding", New())
+
+	_, err := rt.RunString(ctx, `
+	var text = '{"id": 1234567890123456789, "small": 42, "float": 1.5, "list": [-9007199254740993, null, true, "s"]}';
+	var v = encoding.parseJSONBig(text);
+	if (v.id.value !== "1234567890123456789" || v.id != "1234567890123456789" || String(v.id) !== "1234567890123456789") {
+		throw new Error("id mismatch: " + v.id);
+	}
+	if (v.small !== 42 || v.float !== 1.5) {
+		throw new Error("number mismatch: " + v.small + " " + v.float);
+	}
+	if (v.list[0].toString() !== "-9007199254740993" || v.list[1] !== null || v.list[2] !== true || v.list[3] !== "s") {
+		throw new Error("list mismatch: " + JSON.stringify(v.list));
+	}
+	if (Object.keys(v).join(",") !== "id,small,float,list") {
+		throw new Error("keys mismatch: " + Object.keys(v));
+	}
+	var echo = JSON.stringify(v);
+	if (echo !== '{"id":"1234567890123456789","small":42,"float":1.5,"list":["-9007199254740993",null,true,"s"]}') {
+		throw new Error("stringify mismatch: " + echo);
+	}`)
+	assert.NoError(t, err)
+
+	_, err = rt.RunString(ctx, `
+	var v = encoding.parseJSONBig('{"__proto__": {"polluted": true}}');
+	if (Object.getPrototypeOf(v) !== Object.prototype || v.polluted !== undefined) {
+		throw new Error("the prototype is replaced");
+	}
+	if (!Object.prototype.hasOwnProperty.call(v, "__proto__") || v["__proto__"].polluted !== true) {
+		throw new Error("__proto__ isn't an own property: " + Object.keys(v));
+	}`)
+	assert.NoError(t, err)
+
+	for _, text := range []string{`{`, `[1, 2`, `{"a": 1} x`, ``} {
+		rt.Set("text", text)
+		_, err := rt.RunString(ctx, `encoding.parseJSONBig(text)`)
+		assert.Error(t, err, text)
+	}
+}
@@ -225,7 +225,7 @@ func TestParseHTML(t *testing.T) {
 		t.Run("Invalid arg", func(t *testing.T) {
 			_, err := rt.RunString(ctx, `doc.find("#select_multi option").each("");`)
 			if assert.Error(t, err) {
-				assert.IsType(t, &goja.Exception{}, err)
+				assert.IsType(t, &gojs.ScriptError{}, err)
 				assert.Contains(t, err.Error(), "must be a function")
 			}
 		})
@@ -324,7 +324,7 @@ func TestParseHTML(t *testing.T) {
 		t.Run("Invalid arg", func(t *testing.T) {
 			_, err := rt.RunString(ctx, `doc.find("#select_multi option").map("");`)
 			if assert.Error(t, err) {
-				assert.IsType(t, &goja.Exception{}, err)
+				assert.IsType(t, &gojs.ScriptError{}, err)
 				assert.Contains(t, err.Error(), "must be a function")
 			}
 		})
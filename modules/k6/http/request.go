@@ -342,6 +342,8 @@ func (h *HTTP) parseRequest(
 				result.Timeout = t
 			case "throw":
 				result.Throw = params.Get(k).ToBoolean()
+			case "noConnectionReuse":
+				result.NoConnectionReuse = params.Get(k).ToBoolean()
 			case "responseType":
 				responseType, err := httpext.ResponseTypeString(params.Get(k).String())
 				if err != nil {
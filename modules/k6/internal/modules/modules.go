@@ -21,32 +21,17 @@
 package modules
 
 import (
-	"fmt"
-	"sync"
-)
-
-//nolint:gochecknoglobals
-var (
-	modules = make(map[string]interface{})
-	mx      sync.RWMutex
+	"github.com/runner-mei/gojs/modules"
 )
 
 // Get returns the module registered with name.
 func Get(name string) interface{} {
-	mx.RLock()
-	defer mx.RUnlock()
-	return modules[name]
+	return modules.Get(name)
 }
 
 // Register the given mod as a JavaScript module, available
 // for import from JS scripts by name.
 // This function panics if a module with the same name is already registered.
 func Register(name string, mod interface{}) {
-	mx.Lock()
-	defer mx.Unlock()
-
-	if _, ok := modules[name]; ok {
-		panic(fmt.Sprintf("module already registered: %s", name))
-	}
-	modules[name] = mod
+	modules.Register(name, mod)
 }
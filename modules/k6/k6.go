@@ -29,6 +29,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/runner-mei/gojs"
+	"github.com/runner-mei/gojs/lib/types"
 	"github.com/runner-mei/gojs/modules/k6/internal/modules"
 )
 
@@ -52,15 +53,44 @@ func (*K6) Fail(msg string) (goja.Value, error) {
 	return goja.Undefined(), errors.New(msg)
 }
 
-func (*K6) Sleep(ctx context.Context, secs float64) {
+// Sleep waits for secs seconds, it's interrupted with an error when the context
+// of the script is done.
+func (*K6) Sleep(ctx context.Context, secs float64) error {
 	timer := time.NewTimer(time.Duration(secs * float64(time.Second)))
 	select {
 	case <-timer.C:
+		return nil
 	case <-ctx.Done():
 		timer.Stop()
+		return errors.Wrap(ctx.Err(), "sleep interrupted")
 	}
 }
 
+//nolint:gochecknoglobals
+var monotonicStart = time.Now()
+
+// NowMillis returns the milliseconds elapsed on a monotonic clock since an arbitrary
+// point, unlike Date.now() it's unaffected by the changes of the system clock, so
+// it's meant to measure durations.
+func (*K6) NowMillis() float64 {
+	return float64(time.Since(monotonicStart)) / float64(time.Millisecond)
+}
+
+// ParseDuration parses a duration like the ones of the options, e.g. "1d2h" or
+// "500ms", and returns it in milliseconds. Numbers without units are milliseconds.
+func (*K6) ParseDuration(s string) (float64, error) {
+	d, err := types.ParseExtendedDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+// FormatDuration formats the duration of ms milliseconds like ParseDuration parses it.
+func (*K6) FormatDuration(ms float64) string {
+	return types.FormatExtendedDuration(time.Duration(ms * float64(time.Millisecond)))
+}
+
 func (*K6) RandomSeed(ctx context.Context, seed int64) {
 	randSource := rand.New(rand.NewSource(seed)).Float64
 
@@ -32,6 +32,55 @@ import (
 	"github.com/runner-mei/gojs"
 )
 
+func TestNowMillis(t *testing.T) {
+	rt := gojs.New()
+	rt.Bind("k6", New())
+	ctx := context.Background()
+	v, err := rt.RunString(ctx, `var start = k6.nowMillis(); k6.sleep(0.05); k6.nowMillis() - start`)
+	if assert.NoError(t, err) {
+		assert.True(t, v.ToFloat() >= 50, "elapsed %v", v)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	rt := gojs.New()
+	rt.Bind("k6", New())
+	ctx := context.Background()
+
+	testdata := map[string]float64{
+		"1d":     86400000,
+		"1d2h":   93600000,
+		"1h30m":  5400000,
+		"2m":     120000,
+		"1.5s":   1500,
+		"250ms":  250,
+		"100":    100,
+		"-1d12h": -129600000,
+	}
+	for name, ms := range testdata {
+		name, ms := name, ms
+		t.Run(name, func(t *testing.T) {
+			v, err := rt.RunString(ctx, `k6.parseDuration("`+name+`")`)
+			if assert.NoError(t, err) {
+				assert.Equal(t, ms, v.ToFloat())
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := rt.RunString(ctx, `k6.parseDuration("1x")`)
+		assert.Error(t, err)
+	})
+
+	t.Run("Format", func(t *testing.T) {
+		v, err := rt.RunString(ctx, `[k6.formatDuration(93600000), k6.formatDuration(1500),
+			k6.parseDuration(k6.formatDuration(129600000))].join(",")`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "1d2h0m0s,1.5s,129600000", v.String())
+		}
+	})
+}
+
 func TestFail(t *testing.T) {
 	rt := gojs.New()
 	rt.Bind("k6", New())
@@ -66,7 +115,9 @@ func TestSleep(t *testing.T) {
 			startTime := time.Now()
 			_, err := rt.RunString(ctx, `k6.sleep(10)`)
 			endTime := time.Now()
-			assert.NoError(t, err)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "sleep interrupted: context canceled")
+			}
 			dch <- endTime.Sub(startTime)
 		}()
 		runtime.Gosched()
@@ -25,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/dop251/goja"
@@ -32,6 +33,7 @@ import (
 	"github.com/runner-mei/gojs/lib"
 	"github.com/runner-mei/gojs/modules/k6/internal/modules"
 	"github.com/runner-mei/gojs/stats"
+	"github.com/runner-mei/log"
 )
 
 func init() {
@@ -47,29 +49,61 @@ func checkName(name string) bool {
 }
 
 type Metric struct {
-	metric *stats.Metric
+	metric  *stats.Metric
+	buckets []float64
+
+	warnOnSystemTags bool
 }
 
 // ErrMetricsAddInInitContext is error returned when adding to metric is done in the init context
 var ErrMetricsAddInInitContext = gojs.NewInitContextError("Adding to metrics in the init context is not supported")
 
-func newMetric(ctx context.Context, name string, t stats.MetricType, isTime []bool) (interface{}, error) {
+func checkMetric(ctx context.Context, name string, isTime []bool) (stats.ValueType, error) {
 	if lib.GetState(ctx) != nil {
-		return nil, errors.New("metrics must be declared in the init context")
+		return stats.Default, errors.New("metrics must be declared in the init context")
 	}
 
 	//TODO: move verification outside the JS
 	if !checkName(name) {
-		return nil, gojs.NewInitContextError(fmt.Sprintf("Invalid metric name: '%s'", name))
+		return stats.Default, gojs.NewInitContextError(fmt.Sprintf("Invalid metric name: '%s'", name))
 	}
 
-	valueType := stats.Default
 	if len(isTime) > 0 && isTime[0] {
-		valueType = stats.Time
+		return stats.Time, nil
+	}
+	return stats.Default, nil
+}
+
+func (mi *Metrics) newMetric(ctx context.Context, name string, t stats.MetricType, isTime []bool) (interface{}, error) {
+	valueType, err := checkMetric(ctx, name, isTime)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := gojs.GetRuntime(ctx)
+	return rt.ToBindObject(Metric{
+		metric:           stats.New(name, t, valueType),
+		warnOnSystemTags: mi.WarnOnSystemTags,
+	}), nil
+}
+
+func (mi *Metrics) newHistogram(ctx context.Context, name string, buckets []float64, isTime []bool) (interface{}, error) {
+	valueType, err := checkMetric(ctx, name, isTime)
+	if err != nil {
+		return nil, err
+	}
+
+	metric, err := stats.NewHistogram(name, buckets, valueType)
+	if err != nil {
+		return nil, gojs.NewInitContextError(err.Error())
 	}
 
 	rt := gojs.GetRuntime(ctx)
-	return rt.ToBindObject(Metric{stats.New(name, t, valueType)}), nil
+	return rt.ToBindObject(Metric{
+		metric:           metric,
+		buckets:          buckets,
+		warnOnSystemTags: mi.WarnOnSystemTags,
+	}), nil
 }
 
 func (m Metric) Add(ctx context.Context, v goja.Value, addTags ...map[string]string) (bool, error) {
@@ -81,38 +115,95 @@ func (m Metric) Add(ctx context.Context, v goja.Value, addTags ...map[string]str
 	tags := state.CloneTags()
 	for _, ts := range addTags {
 		for k, v := range ts {
+			if stats.IsSystemTag(k) {
+				if !m.warnOnSystemTags {
+					return false, fmt.Errorf("'%s' is a reserved system tag name and can't be used in metric tags", k)
+				}
+				if state.Logger != nil {
+					state.Logger.Warn("metric tag overrides a system tag",
+						log.String("metric", m.metric.Name), log.String("tag", k))
+				}
+			}
 			tags[k] = v
 		}
 	}
 
-	vfloat := v.ToFloat()
-	if vfloat == 0 && v.ToBoolean() {
-		vfloat = 1.0
+	now := time.Now()
+	if obj, ok := v.(*goja.Object); ok && obj.ClassName() == "Array" {
+		stats.PushIfNotDone(ctx, state.Samples, m.batch(now, obj, tags))
+		return true, nil
+	}
+
+	vfloat := toFloat(v)
+	if m.metric.Type == stats.Histogram {
+		tags[stats.HistogramBucketTag] = stats.HistogramBucket(m.buckets, vfloat)
 	}
 
-	sample := stats.Sample{Time: time.Now(), Metric: m.metric, Value: vfloat, Tags: stats.IntoSampleTags(&tags)}
+	sample := stats.Sample{Time: now, Metric: m.metric, Value: vfloat, Tags: stats.IntoSampleTags(&tags)}
 	stats.PushIfNotDone(ctx, state.Samples, sample)
 	return true, nil
 }
 
-type Metrics struct{}
+// batch creates a sample for every element of the array, all of them sharing
+// the same time and tags, except for the bucket tag of the histograms.
+func (m Metric) batch(now time.Time, array *goja.Object, tags map[string]string) stats.SampleContainer {
+	length := int(array.Get("length").ToInteger())
+	samples := make([]stats.Sample, length)
+
+	if m.metric.Type == stats.Histogram {
+		for i := range samples {
+			vfloat := toFloat(array.Get(strconv.Itoa(i)))
+			sampleTags := make(map[string]string, len(tags)+1)
+			for k, v := range tags {
+				sampleTags[k] = v
+			}
+			sampleTags[stats.HistogramBucketTag] = stats.HistogramBucket(m.buckets, vfloat)
+			samples[i] = stats.Sample{Time: now, Metric: m.metric, Value: vfloat, Tags: stats.IntoSampleTags(&sampleTags)}
+		}
+		return stats.Samples(samples)
+	}
+
+	sampleTags := stats.IntoSampleTags(&tags)
+	for i := range samples {
+		samples[i] = stats.Sample{Time: now, Metric: m.metric, Value: toFloat(array.Get(strconv.Itoa(i))), Tags: sampleTags}
+	}
+	return stats.ConnectedSamples{Samples: samples, Tags: sampleTags, Time: now}
+}
+
+func toFloat(v goja.Value) float64 {
+	vfloat := v.ToFloat()
+	if vfloat == 0 && v.ToBoolean() {
+		vfloat = 1.0
+	}
+	return vfloat
+}
+
+type Metrics struct {
+	// WarnOnSystemTags only logs a warning instead of failing when a tag
+	// passed to metric.add() has the name of a system tag.
+	WarnOnSystemTags bool `js:"-"`
+}
 
 func New() *Metrics {
 	return &Metrics{}
 }
 
-func (*Metrics) XCounter(ctx context.Context, name string, isTime ...bool) (interface{}, error) {
-	return newMetric(ctx, name, stats.Counter, isTime)
+func (mi *Metrics) XCounter(ctx context.Context, name string, isTime ...bool) (interface{}, error) {
+	return mi.newMetric(ctx, name, stats.Counter, isTime)
+}
+
+func (mi *Metrics) XGauge(ctx context.Context, name string, isTime ...bool) (interface{}, error) {
+	return mi.newMetric(ctx, name, stats.Gauge, isTime)
 }
 
-func (*Metrics) XGauge(ctx context.Context, name string, isTime ...bool) (interface{}, error) {
-	return newMetric(ctx, name, stats.Gauge, isTime)
+func (mi *Metrics) XTrend(ctx context.Context, name string, isTime ...bool) (interface{}, error) {
+	return mi.newMetric(ctx, name, stats.Trend, isTime)
 }
 
-func (*Metrics) XTrend(ctx context.Context, name string, isTime ...bool) (interface{}, error) {
-	return newMetric(ctx, name, stats.Trend, isTime)
+func (mi *Metrics) XRate(ctx context.Context, name string, isTime ...bool) (interface{}, error) {
+	return mi.newMetric(ctx, name, stats.Rate, isTime)
 }
 
-func (*Metrics) XRate(ctx context.Context, name string, isTime ...bool) (interface{}, error) {
-	return newMetric(ctx, name, stats.Rate, isTime)
+func (mi *Metrics) XHistogram(ctx context.Context, name string, buckets []float64, isTime ...bool) (interface{}, error) {
+	return mi.newHistogram(ctx, name, buckets, isTime)
 }
@@ -152,6 +152,103 @@ func TestMetrics(t *testing.T) {
 	}
 }
 
+func TestHistogram(t *testing.T) {
+	t.Parallel()
+	rt := gojs.New()
+	rt.SetFieldNameMapper(gojs.FieldNameMapper{})
+	rt.Bind("metrics", New())
+
+	ctx := context.Background()
+	_, err := rt.RunString(ctx, `var m = new metrics.Histogram("my_histogram", [0.1, 1, 5])`)
+	require.NoError(t, err)
+
+	_, err = rt.RunString(ctx, `new metrics.Histogram("my_histogram", [5, 1])`)
+	assert.Error(t, err)
+
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &lib.State{Samples: samples, Tags: map[string]string{}}
+	ctx = lib.WithState(ctx, state)
+	_, err = rt.RunString(ctx, `m.add(0.5, {a:1}); m.add(10)`)
+	require.NoError(t, err)
+
+	bufSamples := stats.GetBufferedSamples(samples)
+	if assert.Len(t, bufSamples, 2) {
+		sample := bufSamples[0].(stats.Sample)
+		assert.Equal(t, stats.Histogram, sample.Metric.Type)
+		assert.Equal(t, map[string]string{"a": "1", "le": "1"}, sample.Tags.CloneTags())
+
+		sample = bufSamples[1].(stats.Sample)
+		assert.Equal(t, map[string]string{"le": "+Inf"}, sample.Tags.CloneTags())
+	}
+}
+
+func TestMetricBatch(t *testing.T) {
+	t.Parallel()
+	rt := gojs.New()
+	rt.SetFieldNameMapper(gojs.FieldNameMapper{})
+	rt.Bind("metrics", New())
+
+	ctx := context.Background()
+	_, err := rt.RunString(ctx, `var m = new metrics.Trend("my_metric")`)
+	require.NoError(t, err)
+
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &lib.State{Samples: samples, Tags: map[string]string{}}
+	ctx = lib.WithState(ctx, state)
+	_, err = rt.RunString(ctx, `m.add([1, 2.5, true], {a: 1})`)
+	require.NoError(t, err)
+
+	bufSamples := stats.GetBufferedSamples(samples)
+	if assert.Len(t, bufSamples, 1) {
+		container, ok := bufSamples[0].(stats.ConnectedSamples)
+		require.True(t, ok)
+		require.Len(t, container.Samples, 3)
+		for i, value := range []float64{1, 2.5, 1} {
+			sample := container.Samples[i]
+			assert.Equal(t, value, sample.Value)
+			assert.Equal(t, container.Time, sample.Time)
+			assert.Equal(t, map[string]string{"a": "1"}, sample.Tags.CloneTags())
+		}
+	}
+}
+
+func TestMetricSystemTags(t *testing.T) {
+	t.Parallel()
+	for _, warnOnly := range []bool{false, true} {
+		warnOnly := warnOnly
+		t.Run(fmt.Sprintf("warnOnly=%v", warnOnly), func(t *testing.T) {
+			t.Parallel()
+			rt := gojs.New()
+			rt.SetFieldNameMapper(gojs.FieldNameMapper{})
+			rt.Bind("metrics", &Metrics{WarnOnSystemTags: warnOnly})
+
+			ctx := context.Background()
+			_, err := rt.RunString(ctx, `var m = new metrics.Counter("my_metric")`)
+			require.NoError(t, err)
+
+			samples := make(chan stats.SampleContainer, 1000)
+			state := &lib.State{Samples: samples, Tags: map[string]string{}}
+			ctx = lib.WithState(ctx, state)
+
+			t.Run("Clean", func(t *testing.T) {
+				_, err := rt.RunString(ctx, `m.add(1, {a: "1", b: "2"})`)
+				assert.NoError(t, err)
+				assert.Len(t, stats.GetBufferedSamples(samples), 1)
+			})
+			t.Run("Colliding", func(t *testing.T) {
+				_, err := rt.RunString(ctx, `m.add(1, {method: "GET"})`)
+				if warnOnly {
+					assert.NoError(t, err)
+					assert.Len(t, stats.GetBufferedSamples(samples), 1)
+				} else {
+					assert.Contains(t, err.Error(), "'method' is a reserved system tag name")
+					assert.Len(t, stats.GetBufferedSamples(samples), 0)
+				}
+			})
+		})
+	}
+}
+
 func TestMetricNames(t *testing.T) {
 	t.Parallel()
 	var testMap = map[string]bool{
@@ -0,0 +1,17 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	Register("test/register", struct{}{})
+	assert.Equal(t, struct{}{}, Get("test/register"))
+	assert.Contains(t, Enumerate(), "test/register")
+
+	assert.PanicsWithValue(t, "module already registered: test/register", func() {
+		Register("test/register", struct{}{})
+	})
+}
@@ -38,5 +38,27 @@ func NewRandSource() goja.RandSource {
 	if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
 		panic(fmt.Errorf("could not read random bytes: %v", err))
 	}
+	return NewRandSourceWithSeed(seed)
+}
+
+// NewRandSourceWithSeed returns a deterministic RandSource, two sources created
+// with the same seed produce the same sequence of numbers.
+// The returned RandSource is NOT safe for concurrent use.
+func NewRandSourceWithSeed(seed int64) goja.RandSource {
 	return rand.New(rand.NewSource(seed)).Float64
 }
+
+// NewCryptoRandSource returns a RandSource reading every number from crypto/rand,
+// for the scripts which generate security-sensitive values with Math.random().
+// It's safe for concurrent use, but each call costs a read of the system CSPRNG,
+// which is roughly 10 to 50 times slower than the RandSource of NewRandSource.
+func NewCryptoRandSource() goja.RandSource {
+	return func() float64 {
+		var b [8]byte
+		if _, err := crand.Read(b[:]); err != nil {
+			panic(fmt.Errorf("could not read random bytes: %v", err))
+		}
+		// The 53 high bits fill the mantissa, so the result is uniform in [0, 1).
+		return float64(binary.LittleEndian.Uint64(b[:])>>11) / (1 << 53)
+	}
+}
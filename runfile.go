@@ -0,0 +1,80 @@
+package gojs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// cachedProgram is a program compiled by RunFile, it's compiled again when
+// the file is modified.
+type cachedProgram struct {
+	pgm     *goja.Program
+	modTime time.Time
+}
+
+// RunFile reads, compiles in the CompatibilityMode of the runtime and runs the
+// script at path, the programs are cached by RunFile until the file changes.
+// A relative path is resolved against RuntimeOptions.FileRoot, and when
+// FileRoot is set, the paths outside of it are rejected. The symbolic links
+// are not resolved, so they must not point outside of FileRoot.
+func (r *Runtime) RunFile(ctx context.Context, path string) (goja.Value, error) {
+	filename, err := r.resolveFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, ok := r.programs[filename]
+	if !ok || !cached.modTime.Equal(info.ModTime()) {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		pgm, _, err := r.Compile(string(src), filename, "", "", false)
+		if err != nil {
+			return nil, err
+		}
+		cached = cachedProgram{pgm: pgm, modTime: info.ModTime()}
+		if r.programs == nil {
+			r.programs = map[string]cachedProgram{}
+		}
+		r.programs[filename] = cached
+	}
+	return r.RunProgram(ctx, cached.pgm)
+}
+
+// resolveFile returns the absolute path of path, which must be inside of
+// RuntimeOptions.FileRoot when it's set.
+func (r *Runtime) resolveFile(path string) (string, error) {
+	root := r.opts.FileRoot
+	if root == "" {
+		return filepath.Abs(path)
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	filename := path
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(root, filename)
+	}
+	filename = filepath.Clean(filename)
+
+	rel, err := filepath.Rel(root, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file '%s' is outside of the root '%s'", path, r.opts.FileRoot)
+	}
+	return filename, nil
+}
@@ -0,0 +1,56 @@
+package gojs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "gojs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	filename := filepath.Join(root, "a.js")
+	if err := ioutil.WriteFile(filename, []byte("1 + 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vm, err := NewWith(&RuntimeOptions{FileRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := vm.RunFile(context.Background(), "a.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.ToInteger() != 2 {
+		t.Fatal(ret)
+	}
+
+	// the program is compiled again once the file is modified
+	if err := ioutil.WriteFile(filename, []byte("var a = 1;\nthrow new Error('boom');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Second)
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	_, err = vm.RunFile(context.Background(), filename)
+	if err == nil || !strings.Contains(err.Error(), "a.js:2:") {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"../a.js", "/etc/passwd", "b/../../a.js"} {
+		if _, err := vm.RunFile(context.Background(), path); err == nil || !strings.Contains(err.Error(), "outside of the root") {
+			t.Fatal(path, err)
+		}
+	}
+}
@@ -22,6 +22,7 @@ package gojs
 
 import (
 	"github.com/runner-mei/gojs/js/compiler"
+	"github.com/runner-mei/gojs/lib/types"
 )
 
 // CompatibilityMode specifies the JS compatibility mode
@@ -47,8 +48,10 @@ func NewCompiler() *Compiler {
 
 // RuntimeOptions are settings passed onto the goja JS runtime
 type RuntimeOptions struct {
-	// Whether to pass the actual system environment variables to the JS runtime
-	IncludeSystemEnvVars bool `json:"includeSystemEnvVars,omitempty"`
+	// Whether to pass the actual system environment variables to the JS runtime,
+	// they are merged into __ENV, with Env taking precedence on conflicts.
+	// Note that any secret in the process environment becomes visible to the scripts.
+	IncludeSystemEnvVars bool `json:"includeSystemEnvVars,omitempty" envconfig:"K6_INCLUDE_SYSTEM_ENV_VARS"`
 
 	// JS compatibility mode: "extended" (Goja+Babel+core.js) or "base" (plain Goja)
 	//
@@ -56,8 +59,69 @@ type RuntimeOptions struct {
 	// should use the CompatibilityMode type directly... but by then, we'd need to have
 	// some way of knowing if the value has been set by the user or if we're using the
 	// default one, so we can handle `k6 run --compatibility-mode=base es6_extended_archive.tar`
-	CompatibilityMode string `json:"compatibilityMode,omitempty"`
+	CompatibilityMode string `json:"compatibilityMode,omitempty" envconfig:"K6_COMPATIBILITY_MODE"`
 
 	// Environment variables passed onto the runner
-	Env map[string]string `json:"env,omitempty"`
+	Env map[string]string `json:"env,omitempty" ignored:"true"`
+
+	// Approximate number of bytes a script can allocate before it's interrupted with
+	// a MemoryLimitError, zero means no limit
+	MemoryLimitBytes uint64 `json:"memoryLimitBytes,omitempty" envconfig:"K6_MEMORY_LIMIT_BYTES"`
+
+	// Whether to make eval() and the Function constructor throw, see Runtime.DisableDynamicCode
+	DisableDynamicCode bool `json:"disableDynamicCode,omitempty" envconfig:"K6_DISABLE_DYNAMIC_CODE"`
+
+	// Number of steps (of StepInterval each) a single run of a script can take before
+	// it's interrupted with a StepLimitError, zero means no limit. The steps are the
+	// ticks counted by a background sampler, the ticks it misses under load aren't
+	// counted, so the budget only grows on a busy machine
+	MaxSteps uint64 `json:"maxSteps,omitempty" envconfig:"K6_MAX_STEPS"`
+
+	// Seed of the Math.random() source, a random seed is used when it's nil. The
+	// clones made by Runtime.Clone get a random seed of their own, unless
+	// KeepSeedInClones is set
+	Seed *int64 `json:"seed,omitempty" envconfig:"K6_SEED"`
+
+	// Whether the clones of a runtime with a Seed keep it, so they all produce the
+	// same sequence of Math.random() numbers
+	KeepSeedInClones bool `json:"keepSeedInClones,omitempty" envconfig:"K6_KEEP_SEED_IN_CLONES"`
+
+	// Whether Math.random() reads from crypto/rand instead of a seeded PRNG, see
+	// NewCryptoRandSource for the performance cost, it can't be set with Seed
+	CryptoRandom bool `json:"cryptoRandom,omitempty" envconfig:"K6_CRYPTO_RANDOM"`
+
+	// Whether Set and Bind should panic instead of silently overwriting an
+	// already defined global
+	StrictGlobals bool `json:"strictGlobals,omitempty" envconfig:"K6_STRICT_GLOBALS"`
+
+	// Directory the scripts run with RunFile are resolved against and confined to,
+	// the paths aren't confined when it's empty
+	FileRoot string `json:"fileRoot,omitempty" envconfig:"K6_FILE_ROOT"`
+
+	// Whether a zero time.Time is converted to null instead of the JS Date of
+	// the Unix epoch
+	ZeroTimeAsNull bool `json:"zeroTimeAsNull,omitempty" envconfig:"K6_ZERO_TIME_AS_NULL"`
+
+	// Whether the runs of the scripts started on several goroutines at once wait
+	// for each other instead of panicking with ErrConcurrentRun, a nested run must
+	// be given the context of the Go function starting it or it waits forever
+	SerializeRuns bool `json:"serializeRuns,omitempty" envconfig:"K6_SERIALIZE_RUNS"`
+
+	// Whether the check of the concurrent runs is turned off, e.g. in production
+	// once the callers are known to never share a runtime between goroutines
+	DisableRunCheck bool `json:"disableRunCheck,omitempty" envconfig:"K6_DISABLE_RUN_CHECK"`
+
+	// Names of the modules registered with modules.Register to bind when the runtime
+	// is created, under the last element of their names, e.g. "data" for "k6/data"
+	Modules []string `json:"modules,omitempty" envconfig:"K6_MODULES"`
+}
+
+// RuntimeOptionsFromEnv returns the options set by the K6_* environment variables named
+// by the `envconfig` tags of RuntimeOptions, getenv is usually os.Getenv.
+func RuntimeOptionsFromEnv(getenv func(string) string) (RuntimeOptions, error) {
+	var opts RuntimeOptions
+	if err := types.LoadEnv(&opts, getenv); err != nil {
+		return RuntimeOptions{}, err
+	}
+	return opts, nil
 }
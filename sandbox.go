@@ -0,0 +1,130 @@
+package gojs
+
+import (
+	"github.com/dop251/goja"
+)
+
+// disableDynamicCode replaces eval and the Function constructor, including
+// the one reachable through the constructor property of any function, with
+// functions throwing an EvalError. The GeneratorFunction, AsyncFunction and
+// AsyncGeneratorFunction constructors are replaced the same way when goja
+// supports them, they're probed with the original Function constructor since
+// their syntax doesn't compile otherwise.
+var disableDynamicCode = goja.MustCompile(
+	"__disable_dynamic_code__",
+	`(function(global) {
+	function disabled(name) {
+		return function() { throw new EvalError(name + " is disabled"); };
+	}
+	function replace(proto, name) {
+		var fn = disabled(name);
+		fn.prototype = proto;
+		Object.defineProperty(proto, "constructor", {value: fn});
+		return fn;
+	}
+	var probe = Function;
+	[
+		["the GeneratorFunction constructor", "function*() {}"],
+		["the AsyncFunction constructor", "async function() {}"],
+		["the AsyncGeneratorFunction constructor", "async function*() {}"]
+	].forEach(function(c) {
+		var proto;
+		try {
+			proto = Object.getPrototypeOf(probe("return " + c[1])());
+		} catch (e) {
+			return; // not supported
+		}
+		replace(proto, c[0]);
+	});
+	var fn = replace(Function.prototype, "the Function constructor");
+	Object.defineProperty(global, "Function", {value: fn});
+	Object.defineProperty(global, "eval", {value: disabled("eval")});
+})(this)`,
+	true,
+)
+
+// freezeGlobals is a function deep freezing the values of the globals and making
+// the globals read-only, except the ones named by skip. The global object itself
+// stays extensible so the scripts can still declare their own globals. Objects
+// which can't be frozen, like some of the Go objects bound to the runtime, are
+// skipped.
+var freezeGlobals = goja.MustCompile(
+	"__freeze_globals__",
+	`(function(global, skip) {
+	function deepFreeze(o) {
+		if (o === null || (typeof o !== "object" && typeof o !== "function") || Object.isFrozen(o)) {
+			return;
+		}
+		try {
+			Object.freeze(o);
+		} catch (e) {
+			return;
+		}
+		Object.getOwnPropertyNames(o).forEach(function(name) {
+			var desc = Object.getOwnPropertyDescriptor(o, name);
+			if (desc && "value" in desc) {
+				deepFreeze(desc.value);
+			}
+		});
+		deepFreeze(Object.getPrototypeOf(o));
+	}
+
+	Object.getOwnPropertyNames(global).forEach(function(name) {
+		var desc = Object.getOwnPropertyDescriptor(global, name);
+		if (!desc || !("value" in desc) || skip.indexOf(name) >= 0) {
+			return;
+		}
+		deepFreeze(desc.value);
+		if (desc.configurable) {
+			try {
+				Object.defineProperty(global, name, {writable: false, configurable: false});
+			} catch (e) {
+			}
+		}
+	});
+})`,
+	true,
+)
+
+// managedGlobals are the globals updated by the runtime itself, they are kept
+// out of FreezeGlobals.
+var managedGlobals = []string{"__ENV", "__VU", "__ITER"}
+
+// FreezeGlobals deep freezes the standard built-ins and the globals bound to
+// the runtime, so the scripts can't monkey-patch them, e.g. Array.prototype.
+// It should be called once the runtime is set up, before running user code.
+// Modifications then throw in strict mode and are silently ignored otherwise.
+//
+// Scripts which extend the built-in prototypes don't work in a frozen runtime.
+// The frozen globals can't be set or bound again, Set and Bind log a warning
+// then. __ENV, __VU and __ITER aren't frozen, they keep being updated by the
+// runtime.
+func (r *Runtime) FreezeGlobals() error {
+	v, err := r.Runtime.RunProgram(freezeGlobals)
+	if err != nil {
+		return err
+	}
+	freeze, _ := goja.AssertFunction(v)
+	if _, err = freeze(goja.Undefined(), r.Runtime.GlobalObject(), r.Runtime.ToValue(managedGlobals)); err != nil {
+		return err
+	}
+	r.frozen = true
+	return nil
+}
+
+// DisableDynamicCode prevents the scripts from generating code at runtime, by
+// making eval() and the Function constructor throw, and the GeneratorFunction,
+// AsyncFunction and AsyncGeneratorFunction constructors too where goja supports
+// them. Function declarations and expressions keep working.
+//
+// This is not a complete sandbox: it doesn't limit what the scripts can do
+// with the globals and modules bound to the runtime, and any global bound
+// before the call that compiles code (e.g. a Go function calling RunString)
+// is still able to do so.
+func (r *Runtime) DisableDynamicCode() error {
+	if _, err := r.Runtime.RunProgram(disableDynamicCode); err != nil {
+		return err
+	}
+	r.dynamicCodeDisabled = true
+	return nil
+}
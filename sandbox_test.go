@@ -0,0 +1,133 @@
+package gojs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/runner-mei/log/logtest"
+)
+
+func TestDisableDynamicCode(t *testing.T) {
+	vm, err := NewWith(&RuntimeOptions{DisableDynamicCode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, src := range []string{
+		`eval("1+1")`,
+		`new Function("return 1")`,
+		`Function("return 1")`,
+		`(function() {}).constructor("return 1")`,
+		// a syntax error where goja doesn't support them, an EvalError otherwise
+		`Object.getPrototypeOf(function*() {}).constructor("yield 1")`,
+		`Object.getPrototypeOf(async function() {}).constructor("return 1")`,
+		`Object.getPrototypeOf(async function*() {}).constructor("yield 1")`,
+	} {
+		if _, err := vm.RunString(ctx, src); err == nil {
+			t.Error("excepted error for", src)
+		}
+	}
+
+	ret, err := vm.RunString(ctx, `function add(a, b) { return a + b; } var sub = function(a, b) { return a - b; }; add(1, sub(3, 2))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.ToInteger() != 2 {
+		t.Error("excepted 2 got", ret)
+	}
+}
+
+func TestFreezeGlobals(t *testing.T) {
+	vm := New()
+	vm.Set("a", map[string]interface{}{"b": 1})
+	if err := vm.FreezeGlobals(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ret, err := vm.RunString(ctx, `
+		Array.prototype.first = function() { return this[0]; };
+		JSON = null;
+		var c = 1;
+		[typeof Array.prototype.first, typeof JSON.stringify, c].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if excepted := "undefined,function,1"; ret.String() != excepted {
+		t.Error("excepted", excepted, "got", ret.String())
+	}
+
+	if _, err := vm.RunString(ctx, `"use strict"; Array.prototype.first = function() {}`); err == nil {
+		t.Error("excepted error in strict mode")
+	}
+}
+
+func TestFreezeGlobalsManaged(t *testing.T) {
+	logger, logEntries := logtest.NewObservedLogger()
+	vm := New()
+	vm.SetLogger(logger)
+	vm.Set("a", 1)
+	if err := vm.FreezeGlobals(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithVUID(context.Background(), 3)
+	for iter := int64(0); iter < 2; iter++ {
+		vm.SetEnv(map[string]string{"ITER": strconv.FormatInt(iter, 10)})
+		ret, err := vm.RunString(WithIteration(ctx, iter), `[__VU, __ITER, __ENV.ITER].join(",")`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if excepted := "3," + strconv.FormatInt(iter, 10) + "," + strconv.FormatInt(iter, 10); ret.String() != excepted {
+			t.Error("excepted", excepted, "got", ret.String())
+		}
+	}
+	if entries := logEntries.All(); len(entries) != 0 {
+		t.Error(entries)
+	}
+
+	// The frozen globals can't be set again, which is logged.
+	vm.Set("a", 2)
+	exists, entry := logtest.LastEntry(logEntries)
+	if !exists || entry.ContextMap()["global"] != "a" {
+		t.Error(exists, entry)
+	}
+	if ret, err := vm.RunString(ctx, `a`); err != nil || ret.ToInteger() != 1 {
+		t.Error(ret, err)
+	}
+}
+
+func TestCloneSandboxed(t *testing.T) {
+	logger, _ := logtest.NewObservedLogger()
+	vm := New()
+	vm.SetLogger(logger)
+	vm.Set("a", map[string]interface{}{"b": 1})
+	if err := vm.DisableDynamicCode(); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.FreezeGlobals(); err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := vm.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.logger != logger {
+		t.Error("the logger isn't cloned")
+	}
+	ret, err := clone.RunString(context.Background(), `
+		Array.prototype.first = function() {};
+		a.b = 2;
+		var dynamic;
+		try { eval("1"); dynamic = "enabled"; } catch (e) { dynamic = e.name; }
+		[typeof Array.prototype.first, a.b, dynamic].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if excepted := "undefined,1,EvalError"; ret.String() != excepted {
+		t.Error("excepted", excepted, "got", ret.String())
+	}
+}
@@ -0,0 +1,45 @@
+package gojs
+
+import (
+	"github.com/dop251/goja"
+)
+
+// ScriptError is returned by RunString, RunScript and RunProgram when the
+// script throws, it wraps the goja.Exception so it can still be retrieved
+// with errors.As.
+type ScriptError struct {
+	Exception *goja.Exception
+}
+
+func (e *ScriptError) Error() string {
+	return e.Exception.Error()
+}
+
+// StackTrace returns the exception with the JS stack trace of the script.
+func (e *ScriptError) StackTrace() string {
+	return e.Exception.String()
+}
+
+// Unwrap returns the goja.Exception thrown by the script.
+func (e *ScriptError) Unwrap() error {
+	return e.Exception
+}
+
+// wrapException wraps err in a ScriptError when it's a goja.Exception.
+func wrapException(v goja.Value, err error) (goja.Value, error) {
+	if e, ok := err.(*goja.Exception); ok {
+		return v, &ScriptError{Exception: e}
+	}
+	return v, err
+}
+
+// mustValue returns v, or panics with err and, for a ScriptError, its JS stack trace.
+func mustValue(v goja.Value, err error) goja.Value {
+	if err == nil {
+		return v
+	}
+	if e, ok := err.(*ScriptError); ok {
+		panic("gojs: " + e.StackTrace())
+	}
+	panic("gojs: " + err.Error())
+}
@@ -22,8 +22,10 @@ package stats
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"time"
 )
 
@@ -32,6 +34,7 @@ var (
 	_ Sink = &GaugeSink{}
 	_ Sink = &TrendSink{}
 	_ Sink = &RateSink{}
+	_ Sink = &HistogramSink{}
 	_ Sink = &DummySink{}
 )
 
@@ -130,6 +133,29 @@ func (t *TrendSink) P(pct float64) float64 {
 	}
 }
 
+// PercentileValue calculates the pct-th percentile, with pct between 0 and 100,
+// e.g. 99.9. The values between two samples are linearly interpolated, the p-th
+// percentile of n sorted values is v[i] + (v[i+1]-v[i])*f, where i and f are the
+// integer and fractional parts of p/100*(n-1). It returns an error when pct is
+// out of range or NaN.
+func (t *TrendSink) PercentileValue(pct float64) (float64, error) {
+	if math.IsNaN(pct) || pct < 0 || pct > 100 {
+		return 0, fmt.Errorf("invalid percentile %v, provide a number between 0 and 100", pct)
+	}
+	return t.P(pct / 100), nil
+}
+
+// TrendStat returns the value of a trend stat of SummaryTrendStats, e.g. "med" or
+// "p(99.9)", see GetResolversForTrendColumns.
+func (t *TrendSink) TrendStat(stat string) (float64, error) {
+	resolvers, err := GetResolversForTrendColumns([]string{stat})
+	if err != nil {
+		return 0, err
+	}
+	t.Calc()
+	return resolvers[stat](t), nil
+}
+
 func (t *TrendSink) Calc() {
 	if !t.jumbled {
 		return
@@ -177,6 +203,56 @@ func (r RateSink) Format(t time.Duration) map[string]float64 {
 	return map[string]float64{"rate": float64(r.Trues) / float64(r.Total)}
 }
 
+// HistogramBucketTag is the name of the tag holding the bucket of a histogram sample.
+const HistogramBucketTag = "le"
+
+// HistogramBucket returns the label of the bucket the value falls into, that
+// is the upper bound of the first bucket it fits in, or "+Inf" if there is none.
+func HistogramBucket(buckets []float64, v float64) string {
+	idx := sort.SearchFloat64s(buckets, v)
+	if idx == len(buckets) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(buckets[idx], 'f', -1, 64)
+}
+
+type HistogramSink struct {
+	Buckets []float64
+	Counts  []uint64 // Counts[len(Buckets)] is the +Inf bucket.
+
+	Count uint64
+	Sum   float64
+}
+
+func (h *HistogramSink) Add(s Sample) {
+	if h.Counts == nil {
+		h.Counts = make([]uint64, len(h.Buckets)+1)
+	}
+	h.Counts[sort.SearchFloat64s(h.Buckets, s.Value)]++
+	h.Count++
+	h.Sum += s.Value
+}
+
+func (h *HistogramSink) Calc() {}
+
+func (h *HistogramSink) Format(t time.Duration) map[string]float64 {
+	result := map[string]float64{
+		"count": float64(h.Count),
+		"sum":   h.Sum,
+	}
+
+	// The buckets are cumulative, like the prometheus ones.
+	var cumulative uint64
+	for i, bucket := range h.Buckets {
+		if h.Counts != nil {
+			cumulative += h.Counts[i]
+		}
+		result["le("+strconv.FormatFloat(bucket, 'f', -1, 64)+")"] = float64(cumulative)
+	}
+	result["le(+Inf)"] = float64(h.Count)
+	return result
+}
+
 type DummySink map[string]float64
 
 func (d DummySink) Add(s Sample) {
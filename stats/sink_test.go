@@ -21,6 +21,7 @@
 package stats
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -198,6 +199,44 @@ func TestTrendSink(t *testing.T) {
 			assert.Equal(t, 100.0, sink.P(1.0))
 		})
 	})
+	t.Run("trend stats", func(t *testing.T) {
+		// 1, 2, ..., 1000
+		sink := TrendSink{}
+		for i := 1000; i >= 1; i-- {
+			sink.Add(Sample{Metric: &Metric{}, Value: float64(i)})
+		}
+		expected := map[string]float64{
+			"avg":     500.5,
+			"min":     1,
+			"med":     500.5,
+			"max":     1000,
+			"count":   1000,
+			"p(0)":    1,
+			"p(50)":   500.5,
+			"p(90)":   900.1,
+			"p(99.9)": 999.001,
+			"p(100)":  1000,
+		}
+		for stat, value := range expected {
+			v, err := sink.TrendStat(stat)
+			if assert.NoError(t, err, stat) {
+				assert.InDelta(t, value, v, 1e-9, stat)
+			}
+		}
+		v, err := sink.PercentileValue(95)
+		if assert.NoError(t, err) {
+			assert.InDelta(t, 950.05, v, 1e-9)
+		}
+		for _, pct := range []float64{-1, 100.5, math.NaN(), math.Inf(1)} {
+			_, err := sink.PercentileValue(pct)
+			assert.Error(t, err, pct)
+		}
+
+		for _, stat := range []string{"", "p90", "p(x)", "p(101)", "p(-1)", "p(NaN)", "mean"} {
+			_, err := sink.TrendStat(stat)
+			assert.Error(t, err, stat)
+		}
+	})
 	t.Run("format", func(t *testing.T) {
 		sink := TrendSink{}
 		for _, s := range unsortedSamples10 {
@@ -254,6 +293,39 @@ func TestRateSink(t *testing.T) {
 	})
 }
 
+func TestHistogramSink(t *testing.T) {
+	samples := []float64{0.5, 1.0, 2.0, 3.0, 10.0}
+
+	t.Run("add", func(t *testing.T) {
+		sink := HistogramSink{Buckets: []float64{1, 5}}
+		for _, s := range samples {
+			sink.Add(Sample{Metric: &Metric{}, Value: s})
+		}
+		assert.Equal(t, []uint64{2, 2, 1}, sink.Counts)
+		assert.Equal(t, uint64(5), sink.Count)
+		assert.Equal(t, 16.5, sink.Sum)
+	})
+	t.Run("format", func(t *testing.T) {
+		sink := HistogramSink{Buckets: []float64{1, 5}}
+		for _, s := range samples {
+			sink.Add(Sample{Metric: &Metric{}, Value: s})
+		}
+		assert.Equal(t, map[string]float64{
+			"count":    5,
+			"sum":      16.5,
+			"le(1)":    2,
+			"le(5)":    4,
+			"le(+Inf)": 5,
+		}, sink.Format(0))
+	})
+	t.Run("bucket", func(t *testing.T) {
+		buckets := []float64{0.1, 1, 5}
+		assert.Equal(t, "0.1", HistogramBucket(buckets, 0.1))
+		assert.Equal(t, "1", HistogramBucket(buckets, 0.5))
+		assert.Equal(t, "+Inf", HistogramBucket(buckets, 6))
+	})
+}
+
 func TestDummySinkAddPanics(t *testing.T) {
 	assert.Panics(t, func() {
 		DummySink{}.Add(Sample{})
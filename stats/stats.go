@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -35,10 +36,11 @@ import (
 )
 
 const (
-	counterString = "counter"
-	gaugeString   = "gauge"
-	trendString   = "trend"
-	rateString    = "rate"
+	counterString   = "counter"
+	gaugeString     = "gauge"
+	trendString     = "trend"
+	rateString      = "rate"
+	histogramString = "histogram"
 
 	defaultString = "default"
 	timeString    = "time"
@@ -47,10 +49,11 @@ const (
 
 // Possible values for MetricType.
 const (
-	Counter = MetricType(iota) // A counter that sums its data points
-	Gauge                      // A gauge that displays the latest value
-	Trend                      // A trend, min/max/avg/med are interesting
-	Rate                       // A rate, displays % of values that aren't 0
+	Counter   = MetricType(iota) // A counter that sums its data points
+	Gauge                        // A gauge that displays the latest value
+	Trend                        // A trend, min/max/avg/med are interesting
+	Rate                         // A rate, displays % of values that aren't 0
+	Histogram                    // A histogram, counts the values that fall into each bucket
 )
 
 // Possible values for ValueType.
@@ -89,6 +92,8 @@ func (t MetricType) MarshalText() ([]byte, error) {
 		return []byte(trendString), nil
 	case Rate:
 		return []byte(rateString), nil
+	case Histogram:
+		return []byte(histogramString), nil
 	default:
 		return nil, ErrInvalidMetricType
 	}
@@ -105,6 +110,8 @@ func (t *MetricType) UnmarshalText(data []byte) error {
 		*t = Trend
 	case rateString:
 		*t = Rate
+	case histogramString:
+		*t = Histogram
 	default:
 		return ErrInvalidMetricType
 	}
@@ -122,6 +129,8 @@ func (t MetricType) String() string {
 		return trendString
 	case Rate:
 		return rateString
+	case Histogram:
+		return histogramString
 	default:
 		return "[INVALID]"
 	}
@@ -242,6 +251,17 @@ func (st *SampleTags) Contains(other *SampleTags) bool {
 	return true
 }
 
+// Matches returns true if all the tags of query are set to the same values, an
+// empty query matches all the tag sets, see ParseTagQuery.
+func (st *SampleTags) Matches(query map[string]string) bool {
+	for k, v := range query {
+		if tv, ok := st.Get(k); !ok || tv != v {
+			return false
+		}
+	}
+	return true
+}
+
 // MarshalJSON serializes SampleTags to a JSON string and caches
 // the result. It is not thread safe in the sense that the Go race
 // detector will complain if it's used concurrently, but no data
@@ -462,12 +482,28 @@ func New(name string, typ MetricType, t ...ValueType) *Metric {
 		sink = &TrendSink{}
 	case Rate:
 		sink = &RateSink{}
+	case Histogram:
+		sink = &HistogramSink{}
 	default:
 		return nil
 	}
 	return &Metric{Name: name, Type: typ, Contains: vt, Sink: sink}
 }
 
+// NewHistogram creates a histogram metric with the given bucket boundaries,
+// the boundaries must be sorted in increasing order.
+func NewHistogram(name string, buckets []float64, t ...ValueType) (*Metric, error) {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i-1] >= buckets[i] {
+			return nil, fmt.Errorf("histogram buckets must be in increasing order, got %v", buckets)
+		}
+	}
+
+	m := New(name, Histogram, t...)
+	m.Sink.(*HistogramSink).Buckets = buckets
+	return m, nil
+}
+
 var unitMap = map[string][]interface{}{
 	"s":  {"s", time.Second},
 	"ms": {"ms", time.Millisecond},
@@ -523,7 +559,15 @@ func NewSubmetric(name string) (parentName string, sm *Submetric) {
 		return parts[0], &Submetric{Name: name}
 	}
 
-	kvs := strings.Split(parts[1], ",")
+	tags := ParseTagQuery(parts[1])
+	return parts[0], &Submetric{Name: name, Parent: parts[0], Suffix: parts[1], Tags: IntoSampleTags(&tags)}
+}
+
+// ParseTagQuery parses the tag query of a submetric, the part between the braces of
+// `metric{tagA:valueA,tagB:valueB}`. The keys and values may be quoted, and a key
+// without a value matches the empty value.
+func ParseTagQuery(query string) map[string]string {
+	kvs := strings.Split(query, ",")
 	tags := make(map[string]string, len(kvs))
 	for _, kv := range kvs {
 		if kv == "" {
@@ -540,7 +584,7 @@ func NewSubmetric(name string) (parentName string, sm *Submetric) {
 		value := strings.TrimSpace(strings.Trim(parts[1], `"'`))
 		tags[key] = value
 	}
-	return parts[0], &Submetric{Name: name, Parent: parts[0], Suffix: parts[1], Tags: IntoSampleTags(&tags)}
+	return tags
 }
 
 // parsePercentile is a helper function to parse and validate percentile notations
@@ -551,7 +595,7 @@ func parsePercentile(stat string) (float64, error) {
 
 	percentile, err := strconv.ParseFloat(stat[2:len(stat)-1], 64)
 
-	if err != nil || (percentile < 0) || (percentile > 100) {
+	if err != nil || math.IsNaN(percentile) || (percentile < 0) || (percentile > 100) {
 		return 0, fmt.Errorf("invalid percentile trend stat value '%s', provide a number between 0 and 100", stat)
 	}
 
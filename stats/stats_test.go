@@ -257,6 +257,36 @@ func TestSampleTags(t *testing.T) {
 	assert.Equal(t, tagMap, tagsUnmarshaled.CloneTags())
 }
 
+func TestSampleTagsMatches(t *testing.T) {
+	t.Parallel()
+	tags := NewSampleTags(map[string]string{"method": "GET", "status": "200", "empty": ""})
+	testdata := []struct {
+		query   string
+		tags    *SampleTags
+		matches bool
+	}{
+		{"", tags, true},
+		{"", nil, true},
+		{"method:GET", tags, true},
+		{"method:GET,status:200", tags, true},
+		{` "method" : 'GET' , status:200 `, tags, true},
+		{"method:POST", tags, false},
+		{"method:GET,status:404", tags, false},
+		{"name:foo", tags, false},
+		{"empty", tags, true},
+		{"name", tags, false},
+		{"method:GET", nil, false},
+	}
+
+	for _, data := range testdata {
+		data := data
+		t.Run(data.query, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, data.matches, data.tags.Matches(ParseTagQuery(data.query)))
+		})
+	}
+}
+
 func TestSampleImplementations(t *testing.T) {
 	tagMap := map[string]string{"key1": "val1", "key2": "val2"}
 	now := time.Now()
@@ -191,6 +191,8 @@ func (c *Collector) dispatch(entry *Sample) error {
 		return c.client.TimeInMilliseconds(entry.Metric, entry.Value, tagList, 1)
 	case stats.Gauge:
 		return c.client.Gauge(entry.Metric, entry.Value, tagList, 1)
+	case stats.Histogram:
+		return c.client.Histogram(entry.Metric, entry.Value, tagList, 1)
 	case stats.Rate:
 		if check := entry.Tags["check"]; check != "" {
 			return c.client.Count(
@@ -23,6 +23,8 @@ package stats
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"math"
 	"sort"
 	"strings"
 )
@@ -161,6 +163,12 @@ func ToSystemTagSet(tags []string) *SystemTagSet {
 	return ts
 }
 
+// IsSystemTag checks if the name is the name of one of the system tags.
+func IsSystemTag(name string) bool {
+	_, err := SystemTagSetString(name)
+	return err == nil
+}
+
 // NewSystemTagSet returns a SystemTagSet from input.
 func NewSystemTagSet(tags ...SystemTagSet) *SystemTagSet {
 	ts := new(SystemTagSet)
@@ -190,24 +198,51 @@ func (i *SystemTagSet) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	if len(tags) != 0 {
-		*i = *ToSystemTagSet(tags)
+		ts, err := parseSystemTagSet(tags)
+		if err != nil {
+			return err
+		}
+		*i = ts
 	}
 
 	return nil
 }
 
-// UnmarshalText converts the tag list to SystemTagSet.
+// UnmarshalText converts the comma separated tag list, e.g. "method,status,url", to
+// SystemTagSet, it returns an error for the unknown tag names.
 func (i *SystemTagSet) UnmarshalText(data []byte) error {
-	list := bytes.Split(data, []byte(","))
-
-	for _, key := range list {
+	var tags []string
+	for _, key := range bytes.Split(data, []byte(",")) {
 		key := strings.TrimSpace(string(key))
 		if key == "" {
 			continue
 		}
-		if v, err := SystemTagSetString(key); err == nil {
-			i.Add(v)
-		}
+		tags = append(tags, key)
 	}
+	ts, err := parseSystemTagSet(tags)
+	if err != nil {
+		return err
+	}
+	*i = ts
 	return nil
 }
+
+// MarshalText converts the SystemTagSet to a comma separated tag list, see SetString.
+func (i SystemTagSet) MarshalText() ([]byte, error) {
+	return []byte(i.SetString()), nil
+}
+
+// parseSystemTagSet is like ToSystemTagSet, but it returns an error listing the valid
+// tag names when one of the tags is unknown.
+func parseSystemTagSet(tags []string) (SystemTagSet, error) {
+	var ts SystemTagSet
+	for _, tag := range tags {
+		v, err := SystemTagSetString(tag)
+		if err != nil {
+			return 0, fmt.Errorf("unknown system tag '%s', the valid tags are: %s",
+				tag, SystemTagSet(math.MaxUint32).SetString())
+		}
+		ts.Add(v)
+	}
+	return ts, nil
+}
@@ -82,6 +82,38 @@ func TestSystemTagSetTextUnmarshal(t *testing.T) {
 	}
 }
 
+func TestSystemTagSetText(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, expected := range []SystemTagSet{0, TagIP, TagMethod | TagStatus | TagURL, DefaultSystemTagSet} {
+			text, err := expected.MarshalText()
+			require.NoError(t, err)
+			set := new(SystemTagSet)
+			require.NoError(t, set.UnmarshalText(text))
+			require.Equal(t, expected, *set, string(text))
+		}
+
+		text, err := (TagMethod | TagStatus | TagURL).MarshalText()
+		require.NoError(t, err)
+		assert.Equal(t, "status,method,url", string(text))
+	})
+	t.Run("Replaces", func(t *testing.T) {
+		set := NewSystemTagSet(TagIP)
+		require.NoError(t, set.UnmarshalText([]byte("method")))
+		assert.Equal(t, TagMethod, *set)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		set := NewSystemTagSet(TagIP)
+		err := set.UnmarshalText([]byte("method,foo"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown system tag 'foo', the valid tags are: proto,subproto,status,method,url,")
+		assert.Equal(t, TagIP, *set)
+
+		err = json.Unmarshal([]byte(`["method", "foo"]`), set)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown system tag 'foo'")
+	})
+}
+
 func TestTagSetMarshalJSON(t *testing.T) {
 	tests := []struct {
 		tagset   TagSet
@@ -135,3 +167,9 @@ func TestTagSetTextUnmarshal(t *testing.T) {
 		require.Equal(t, expected, *set)
 	}
 }
+
+func TestIsSystemTag(t *testing.T) {
+	assert.True(t, IsSystemTag("method"))
+	assert.True(t, IsSystemTag("ocsp_status"))
+	assert.False(t, IsSystemTag("my_tag"))
+}
@@ -23,6 +23,7 @@ package gojs
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 
@@ -35,11 +36,58 @@ func RunString(ctx context.Context, rt *Runtime, src string) (goja.Value, error)
 }
 
 // Throws a JS error; avoids re-wrapping GoErrors.
+//
+// Throw unwinds the JS stack with a panic, so it must only be called from the Go
+// functions called by a running script, see ThrowOrReturn for the code which may
+// run elsewhere, e.g. on a background goroutine of a module.
+//
+// The JS error has the message of err, and when err, or an error it wraps, has a
+// Code() string method, a code property, so the scripts can check the kind of
+// error. A wrapped error is also described by a cause property with its message.
 func Throw(rt *Runtime, err error) {
 	if e, ok := err.(*goja.Exception); ok {
 		panic(e)
 	}
-	panic(rt.NewGoError(err))
+	if e, ok := err.(*ScriptError); ok {
+		panic(e.Exception)
+	}
+	panic(newGoError(rt, err))
+}
+
+// ThrowOrReturn throws err like Throw when ctx is the context of the running
+// script of rt, i.e. the one its Go functions are called with, and returns it
+// otherwise, e.g. on a background goroutine of a module where nothing would
+// recover the panic. The background goroutines must not pass the context of
+// the function starting them while the script may still be running. The callers
+// must handle the returned error, usually by returning it:
+//
+//	if err := gojs.ThrowOrReturn(ctx, rt, err); err != nil {
+//		return err
+//	}
+func ThrowOrReturn(ctx context.Context, rt *Runtime, err error) error {
+	if !rt.inRun(ctx) {
+		return err
+	}
+	Throw(rt, err)
+	return nil // unreachable
+}
+
+// errorCoder is implemented by the errors telling their kind to the scripts.
+type errorCoder interface {
+	Code() string
+}
+
+// newGoError returns the JS error thrown by Throw for err.
+func newGoError(rt *Runtime, err error) *goja.Object {
+	obj := rt.NewGoError(err)
+	var coder errorCoder
+	if errors.As(err, &coder) {
+		_ = obj.Set("code", coder.Code())
+	}
+	if cause := errors.Unwrap(err); cause != nil {
+		_ = obj.Set("cause", cause.Error())
+	}
+	return obj
 }
 
 // GetReader tries to return an io.Reader value from an exported goja value.
@@ -21,7 +21,9 @@
 package gojs
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/dop251/goja"
@@ -42,3 +44,73 @@ func TestThrow(t *testing.T) {
 		}
 	}
 }
+
+type codedTestError struct {
+	code string
+}
+
+func (e *codedTestError) Error() string { return "coded " + e.code }
+func (e *codedTestError) Code() string  { return e.code }
+
+func TestThrowErrorShape(t *testing.T) {
+	rt := New()
+	rt.Set("fail", func(kind string) {
+		switch kind {
+		case "coded":
+			Throw(rt, &codedTestError{code: "timeout"})
+		case "wrapped":
+			Throw(rt, fmt.Errorf("request failed: %w", &codedTestError{code: "timeout"}))
+		default:
+			Throw(rt, errors.New("plain"))
+		}
+	})
+
+	check := `(function(kind) {
+		try {
+			fail(kind);
+		} catch (e) {
+			return [e.message, e.code, e.cause].join("|");
+		}
+	})`
+	for kind, want := range map[string]string{
+		"coded":   "coded timeout|timeout|",
+		"wrapped": "request failed: coded timeout|timeout|coded timeout",
+		"plain":   "plain||",
+	} {
+		ret, err := rt.RunString(context.Background(), check+`("`+kind+`")`)
+		if assert.NoError(t, err, kind) {
+			assert.Equal(t, want, ret.String(), kind)
+		}
+	}
+}
+
+func TestThrowOrReturn(t *testing.T) {
+	rt := New()
+	errc := make(chan error, 1)
+	var runCtx context.Context
+	rt.Set("background", func(ctx context.Context, _ goja.FunctionCall) goja.Value {
+		runCtx = ctx
+		go func() {
+			errc <- ThrowOrReturn(context.Background(), rt, errors.New("background"))
+		}()
+		return goja.Undefined()
+	})
+	_, err := rt.RunString(context.Background(), `background()`)
+	assert.NoError(t, err)
+	assert.EqualError(t, <-errc, "background")
+
+	// the run is over, even with its context
+	assert.EqualError(t, ThrowOrReturn(runCtx, rt, errors.New("idle")), "idle")
+
+	// with the context of the running script, it throws a JS error
+	rt.Set("foreground", func(ctx context.Context, _ goja.FunctionCall) goja.Value {
+		if err := ThrowOrReturn(ctx, rt, errors.New("foreground")); err != nil {
+			t.Error("ThrowOrReturn must throw", err)
+		}
+		return goja.Undefined()
+	})
+	_, err = rt.RunString(context.Background(), `foreground()`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "GoError: foreground")
+	}
+}
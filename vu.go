@@ -0,0 +1,48 @@
+package gojs
+
+import "context"
+
+type vuCtxKey int
+
+const (
+	ctxKeyVUID vuCtxKey = iota
+	ctxKeyIteration
+)
+
+// WithVUID attaches the ID of the virtual user running the scripts to the context.
+//
+// The host sets it, together with the iteration, on the context passed to the Run
+// methods, and the scripts read them from the read-only __VU and __ITER globals:
+//
+//	ctx = gojs.WithVUID(ctx, vuID)
+//	for iter := int64(0); ; iter++ {
+//		_, err := rt.RunProgram(gojs.WithIteration(ctx, iter), program)
+//		...
+//	}
+//
+// Both globals are 0 when the context has no value, e.g. in the init context.
+func WithVUID(ctx context.Context, id uint64) context.Context {
+	return context.WithValue(ctx, ctxKeyVUID, id)
+}
+
+// GetVUID retrieves the attached virtual user ID from the given context, or 0.
+func GetVUID(ctx context.Context) uint64 {
+	v, _ := ctx.Value(ctxKeyVUID).(uint64)
+	return v
+}
+
+// WithIteration attaches the iteration of the virtual user to the context, see WithVUID.
+func WithIteration(ctx context.Context, iteration int64) context.Context {
+	return context.WithValue(ctx, ctxKeyIteration, iteration)
+}
+
+// GetIteration retrieves the attached iteration from the given context, or 0.
+func GetIteration(ctx context.Context) int64 {
+	v, _ := ctx.Value(ctxKeyIteration).(int64)
+	return v
+}
+
+// setVU updates the read-only __VU and __ITER globals from the values on ctx.
+func (r *Runtime) setVU(ctx context.Context) {
+	r.vuID, r.iter = GetVUID(ctx), GetIteration(ctx)
+}
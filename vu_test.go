@@ -0,0 +1,43 @@
+package gojs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVUGlobals(t *testing.T) {
+	vm := New()
+	ret, err := vm.RunString(context.Background(), `__VU + "," + __ITER`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "0,0" {
+		t.Fatal(ret)
+	}
+
+	ctx := WithVUID(context.Background(), 3)
+	if GetVUID(ctx) != 3 || GetIteration(ctx) != 0 {
+		t.Fatal(GetVUID(ctx), GetIteration(ctx))
+	}
+	for iter := int64(0); iter < 2; iter++ {
+		ret, err = vm.RunString(WithIteration(ctx, iter), `__VU = 10; __ITER = 10; __VU + "," + __ITER`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "3," + string(rune('0'+iter)); ret.String() != want {
+			t.Fatal(ret, "want", want)
+		}
+	}
+
+	// A script can't replace them with its own properties.
+	ret, err = vm.RunString(WithIteration(ctx, 5), `
+		var redefined = true;
+		try { Object.defineProperty(this, "__ITER", {value: 10}); } catch (e) { redefined = false; }
+		[redefined, delete this.__VU, __VU, __ITER].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.String() != "false,false,3,5" {
+		t.Fatal(ret)
+	}
+}